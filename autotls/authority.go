@@ -0,0 +1,75 @@
+package autotls
+
+import (
+	"fmt"
+	"sync"
+)
+
+// CertAuthority issues server and client certificates signed by a single CA. Generating a CA and
+// discovering the network addresses of the host is slow, so a CertAuthority is typically created
+// once and shared by every server in a test suite, either by calling the Issue methods directly
+// or by setting Config.CertAuthority. It is safe for concurrent use.
+type CertAuthority struct {
+	ca      *KeyPair
+	mutex   sync.Mutex
+	network map[bool]netInfo
+}
+
+// NewCertAuthority generates a new self-signed CA
+func NewCertAuthority(opts CAOptions) (*CertAuthority, error) {
+	ca, err := GenerateCA(opts)
+	if err != nil {
+		return nil, err
+	}
+	return &CertAuthority{ca: ca, network: make(map[bool]netInfo)}, nil
+}
+
+// LoadCertAuthority returns a CertAuthority which issues certificates signed by the provided CA.
+// If the CA is an intermediate, the rest of the bundle is included in the issued certificates.
+func LoadCertAuthority(caPEM, caKeyPEM []byte) (*CertAuthority, error) {
+	ca, err := ParseKeyPair(caPEM, caKeyPEM)
+	if err != nil {
+		return nil, fmt.Errorf("while parsing CA: %w", err)
+	}
+	return &CertAuthority{ca: ca, network: make(map[bool]netInfo)}, nil
+}
+
+// KeyPair returns the certificate and private key of the CA
+func (a *CertAuthority) KeyPair() *KeyPair {
+	return a.ca
+}
+
+// IssueServerCert issues a certificate suitable for both server and client authentication. See
+// GenerateCert() for details. The network addresses of the host are discovered during the first
+// call and reused by later calls.
+func (a *CertAuthority) IssueServerCert(opts CertOptions) (*KeyPair, error) {
+	var network netInfo
+	if !opts.DisableDiscovery {
+		var err error
+		if network, err = a.discover(!opts.DisableIPv6Discovery); err != nil {
+			return nil, fmt.Errorf("while detecting ip and host names: %w", err)
+		}
+	}
+	return generateCert(a.ca, opts, network)
+}
+
+// IssueClientCert issues a certificate which is only suitable for client authentication.
+// See GenerateClientCert() for details.
+func (a *CertAuthority) IssueClientCert(opts ClientCertOptions) (*KeyPair, error) {
+	return GenerateClientCert(a.ca, opts)
+}
+
+// discover returns the network addresses of the host, discovering them only once
+func (a *CertAuthority) discover(ipv6 bool) (netInfo, error) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	if network, ok := a.network[ipv6]; ok {
+		return network, nil
+	}
+	network, err := discoverNetwork(ipv6)
+	if err != nil {
+		return netInfo{}, err
+	}
+	a.network[ipv6] = network
+	return network, nil
+}
@@ -0,0 +1,71 @@
+package autotls_test
+
+import (
+	"os"
+	"testing"
+
+	"github.com/kapetan-io/tackle/autotls"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCertAuthority(t *testing.T) {
+	ca, err := autotls.NewCertAuthority(autotls.CAOptions{
+		OrgName:      "Shared CA",
+		KeyAlgorithm: autotls.KeyAlgorithmECDSAP256,
+	})
+	require.NoError(t, err)
+	assert.True(t, ca.KeyPair().Cert.IsCA)
+
+	first, err := ca.IssueServerCert(autotls.CertOptions{DNSNames: []string{"api.example.com"}})
+	require.NoError(t, err)
+	second, err := ca.IssueServerCert(autotls.CertOptions{})
+	require.NoError(t, err)
+	require.NoError(t, first.Cert.CheckSignatureFrom(ca.KeyPair().Cert))
+	require.NoError(t, second.Cert.CheckSignatureFrom(ca.KeyPair().Cert))
+	assert.Contains(t, first.Cert.DNSNames, "api.example.com")
+	assert.NotEqual(t, first.Cert.SerialNumber, second.Cert.SerialNumber)
+
+	// The discovered addresses are reused
+	assert.Equal(t, first.Cert.IPAddresses, second.Cert.IPAddresses)
+
+	client, err := ca.IssueClientCert(autotls.ClientCertOptions{OrgName: "Client"})
+	require.NoError(t, err)
+	require.NoError(t, client.Cert.CheckSignatureFrom(ca.KeyPair().Cert))
+
+	caPEM, err := os.ReadFile("certs/ca.cert")
+	require.NoError(t, err)
+	caKeyPEM, err := os.ReadFile("certs/ca.key")
+	require.NoError(t, err)
+	loaded, err := autotls.LoadCertAuthority(caPEM, caKeyPEM)
+	require.NoError(t, err)
+	kp, err := loaded.IssueServerCert(autotls.CertOptions{DisableDiscovery: true})
+	require.NoError(t, err)
+	require.NoError(t, kp.Cert.CheckSignatureFrom(loaded.KeyPair().Cert))
+
+	_, err = autotls.LoadCertAuthority(caPEM, nil)
+	require.ErrorContains(t, err, "while parsing CA")
+}
+
+func TestSetupCertAuthority(t *testing.T) {
+	ca, err := autotls.NewCertAuthority(autotls.CAOptions{KeyAlgorithm: autotls.KeyAlgorithmECDSAP256})
+	require.NoError(t, err)
+
+	// Servers built from the same CertAuthority trust each other
+	var results []*autotls.Result
+	for i := 0; i < 3; i++ {
+		r, err := autotls.New(autotls.Config{
+			CaFile:           "certs/ca.cert",
+			CaKeyFile:        "certs/ca.key",
+			CertAuthority:    ca,
+			AutoTLS:          true,
+			DisableDiscovery: true,
+		})
+		require.NoError(t, err)
+		assert.Equal(t, ca.KeyPair().CertPEM, r.CaPEM)
+		results = append(results, r)
+	}
+
+	ln := serveTLS(t, results[0].ServerTLS)
+	assert.Equal(t, "Self Signed Org", peerOrg(t, ln.Addr(), results[2].ClientTLS))
+}
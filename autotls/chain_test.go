@@ -0,0 +1,99 @@
+package autotls_test
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/kapetan-io/tackle/autotls"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSetupIntermediateCA(t *testing.T) {
+	dir := t.TempDir()
+
+	// Given a root CA and an intermediate CA signed by the root
+	rootKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	root := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "Test Root"},
+		NotBefore:             time.Now().Add(-time.Minute),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	rootDER, err := x509.CreateCertificate(rand.Reader, root, root, rootKey.Public(), rootKey)
+	require.NoError(t, err)
+	root, err = x509.ParseCertificate(rootDER)
+	require.NoError(t, err)
+
+	interKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	inter := &x509.Certificate{
+		SerialNumber:          big.NewInt(2),
+		Subject:               pkix.Name{CommonName: "Test Intermediate"},
+		NotBefore:             time.Now().Add(-time.Minute),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	interDER, err := x509.CreateCertificate(rand.Reader, inter, root, interKey.Public(), rootKey)
+	require.NoError(t, err)
+
+	// The CA bundle lists the issuing intermediate first, followed by the root
+	var bundle bytes.Buffer
+	require.NoError(t, pem.Encode(&bundle, &pem.Block{Type: "CERTIFICATE", Bytes: interDER}))
+	require.NoError(t, pem.Encode(&bundle, &pem.Block{Type: "CERTIFICATE", Bytes: rootDER}))
+	keyDER, err := x509.MarshalECPrivateKey(interKey)
+	require.NoError(t, err)
+
+	caFile := filepath.Join(dir, "ca-bundle.pem")
+	caKeyFile := filepath.Join(dir, "intermediate.key")
+	require.NoError(t, os.WriteFile(caFile, bundle.Bytes(), 0600))
+	require.NoError(t, os.WriteFile(caKeyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600))
+
+	conf := autotls.Config{
+		CaFile:    caFile,
+		CaKeyFile: caKeyFile,
+		AutoTLS:   true,
+	}
+	require.NoError(t, autotls.Setup(&conf))
+
+	// Server should present the leaf and the intermediate, but not the root
+	chain := conf.ServerTLS.Certificates[0].Certificate
+	require.Len(t, chain, 2)
+	assert.Equal(t, interDER, chain[1])
+
+	ln, err := tls.Listen("tcp", "localhost:0", conf.ServerTLS)
+	require.NoError(t, err)
+	defer func() { _ = ln.Close() }()
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		_ = conn.(*tls.Conn).Handshake()
+		_ = conn.Close()
+	}()
+
+	// A client which only trusts the root should verify the server
+	pool := x509.NewCertPool()
+	pool.AddCert(root)
+	conn, err := tls.Dial("tcp", ln.Addr().String(), &tls.Config{RootCAs: pool, ServerName: "localhost"})
+	require.NoError(t, err)
+	_ = conn.Close()
+}
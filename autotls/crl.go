@@ -0,0 +1,156 @@
+package autotls
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/kapetan-io/tackle/clock"
+	"github.com/kapetan-io/tackle/set"
+)
+
+const blockTypeCRL = "X509 CRL"
+
+// CRLOptions are the options used by GenerateCRL()
+type CRLOptions struct {
+	// (Optional) The serial numbers of the revoked certificates
+	Revoked []*big.Int
+
+	// (Optional) The sequence number of the CRL, which should increase with each CRL issued. Defaults to 1
+	Number *big.Int
+
+	// (Optional) How long until the next CRL is expected to be issued. Defaults to 7 days
+	Duration clock.Duration
+}
+
+// GenerateCRL generates a PEM encoded certificate revocation list signed by the provided CA
+func GenerateCRL(ca *KeyPair, opts CRLOptions) ([]byte, error) {
+	set.Default(&opts.Number, big.NewInt(1))
+	set.Default(&opts.Duration, 7*24*clock.Hour)
+
+	if ca == nil || ca.Cert == nil || ca.Key == nil {
+		return nil, errors.New("unable to generate a CRL without a signing CA")
+	}
+
+	now := clock.Now()
+	tmpl := x509.RevocationList{
+		Number:     opts.Number,
+		ThisUpdate: now,
+		NextUpdate: now.Add(opts.Duration),
+	}
+	for _, serial := range opts.Revoked {
+		tmpl.RevokedCertificateEntries = append(tmpl.RevokedCertificateEntries, x509.RevocationListEntry{
+			SerialNumber:   serial,
+			RevocationTime: now,
+		})
+	}
+
+	b, err := x509.CreateRevocationList(rand.Reader, &tmpl, ca.Cert, ca.Key)
+	if err != nil {
+		return nil, fmt.Errorf("while creating CRL: %w", err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: blockTypeCRL, Bytes: b}), nil
+}
+
+// parseCRL parses a PEM or DER encoded CRL and verifies it was signed by one of the provided CAs
+func parseCRL(b []byte, caPEMs ...*bytes.Buffer) (*x509.RevocationList, error) {
+	if block, _ := pem.Decode(b); block != nil {
+		b = block.Bytes
+	}
+
+	crl, err := x509.ParseRevocationList(b)
+	if err != nil {
+		return nil, fmt.Errorf("while parsing CRL: %w", err)
+	}
+
+	for _, caPEM := range caPEMs {
+		if caPEM == nil {
+			continue
+		}
+		for _, der := range derOf(caPEM.Bytes()) {
+			ca, err := x509.ParseCertificate(der)
+			if err != nil || !bytes.Equal(ca.RawSubject, crl.RawIssuer) {
+				continue
+			}
+			// CAs may share a subject, so keep looking if the signature doesn't match
+			if err := crl.CheckSignatureFrom(ca); err != nil {
+				continue
+			}
+			return crl, nil
+		}
+	}
+	return nil, errors.New("CRL was not issued by any of the provided CAs")
+}
+
+// verifyNotRevoked returns a VerifyPeerCertificate callback which rejects any certificate presented by
+// the peer that has been revoked by the CRL. If the CRL is past its NextUpdate time it can no longer be
+// trusted to include recent revocations, so all peer certificates are rejected.
+func verifyNotRevoked(crl *x509.RevocationList) func([][]byte, [][]*x509.Certificate) error {
+	revoked := make(map[string]struct{}, len(crl.RevokedCertificateEntries))
+	for _, e := range crl.RevokedCertificateEntries {
+		revoked[e.SerialNumber.String()] = struct{}{}
+	}
+
+	return func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+		if crlExpired(crl) {
+			return fmt.Errorf("CRL expired at %s; unable to verify the peer certificate has not been revoked",
+				crl.NextUpdate)
+		}
+		for _, raw := range rawCerts {
+			cert, err := x509.ParseCertificate(raw)
+			if err != nil {
+				return fmt.Errorf("while parsing peer certificate: %w", err)
+			}
+			if !bytes.Equal(cert.RawIssuer, crl.RawIssuer) {
+				continue
+			}
+			if _, ok := revoked[cert.SerialNumber.String()]; ok {
+				return fmt.Errorf("certificate with serial '%s' has been revoked", cert.SerialNumber)
+			}
+		}
+		return nil
+	}
+}
+
+// setupCRL installs the CRL on the ServerTLS, to reject revoked client certificates, and on
+// the ClientTLS to reject revoked server certificates.
+func setupCRL(conf *Config) error {
+	crl, err := parseCRL(conf.CRLPEM.Bytes(), conf.ClientAuthCaPEM, conf.CaPEM)
+	if err != nil {
+		return err
+	}
+	if crlExpired(crl) {
+		conf.Logger.Warn("CRL has expired; all peer certificates will be rejected until a newer CRL is provided",
+			"next-update", crl.NextUpdate)
+	}
+	verify := verifyNotRevoked(crl)
+	for _, c := range []*tls.Config{conf.ServerTLS, conf.ClientTLS} {
+		addVerifyPeer(c, verify)
+	}
+	return nil
+}
+
+// crlExpired returns true if the NextUpdate time of the CRL has passed
+func crlExpired(crl *x509.RevocationList) bool {
+	return !crl.NextUpdate.IsZero() && clock.Now().After(crl.NextUpdate)
+}
+
+// addVerifyPeer adds the verify function to the config, preserving any existing VerifyPeerCertificate
+func addVerifyPeer(c *tls.Config, verify func([][]byte, [][]*x509.Certificate) error) {
+	prev := c.VerifyPeerCertificate
+	if prev == nil {
+		c.VerifyPeerCertificate = verify
+		return
+	}
+	c.VerifyPeerCertificate = func(rawCerts [][]byte, chains [][]*x509.Certificate) error {
+		if err := prev(rawCerts, chains); err != nil {
+			return err
+		}
+		return verify(rawCerts, chains)
+	}
+}
@@ -0,0 +1,102 @@
+package autotls_test
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"io"
+	"math/big"
+	"testing"
+
+	"github.com/kapetan-io/tackle/autotls"
+	"github.com/kapetan-io/tackle/clock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSetupCRL(t *testing.T) {
+	defer clock.Freeze(clock.Now()).UnFreeze()
+
+	ca, err := autotls.GenerateCA(autotls.CAOptions{KeyAlgorithm: autotls.KeyAlgorithmECDSAP256})
+	require.NoError(t, err)
+
+	opts := autotls.CertOptions{DisableDiscovery: true, KeyAlgorithm: autotls.KeyAlgorithmECDSAP256}
+	server, err := autotls.GenerateCert(ca, opts)
+	require.NoError(t, err)
+	good, err := autotls.GenerateCert(ca, opts)
+	require.NoError(t, err)
+	revoked, err := autotls.GenerateCert(ca, opts)
+	require.NoError(t, err)
+
+	crl, err := autotls.GenerateCRL(ca, autotls.CRLOptions{Revoked: []*big.Int{revoked.Cert.SerialNumber}})
+	require.NoError(t, err)
+
+	r, err := autotls.New(autotls.Config{
+		CaPEM:      bytes.NewBuffer(ca.CertPEM),
+		CertPEM:    bytes.NewBuffer(server.CertPEM),
+		KeyPEM:     bytes.NewBuffer(server.KeyPEM),
+		CRLPEM:     bytes.NewBuffer(crl),
+		ClientAuth: tls.RequireAndVerifyClientCert,
+	})
+	require.NoError(t, err)
+
+	ln, err := tls.Listen("tcp", "localhost:0", r.ServerTLS)
+	require.NoError(t, err)
+	defer func() { _ = ln.Close() }()
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			if err := conn.(*tls.Conn).Handshake(); err == nil {
+				_, _ = conn.Write([]byte("ok"))
+			}
+			_ = conn.Close()
+		}
+	}()
+
+	pool := x509.NewCertPool()
+	pool.AddCert(ca.Cert)
+	dial := func(kp *autotls.KeyPair) (string, error) {
+		cert, err := kp.TLSCertificate()
+		require.NoError(t, err)
+		conn, err := tls.Dial("tcp", ln.Addr().String(), &tls.Config{
+			Certificates: []tls.Certificate{cert},
+			ServerName:   "localhost",
+			RootCAs:      pool,
+		})
+		if err != nil {
+			return "", err
+		}
+		defer func() { _ = conn.Close() }()
+		b, err := io.ReadAll(conn)
+		return string(b), err
+	}
+
+	resp, err := dial(good)
+	require.NoError(t, err)
+	assert.Equal(t, "ok", resp)
+
+	resp, err = dial(revoked)
+	assert.Error(t, err)
+	assert.Empty(t, resp)
+
+	// Once the CRL is stale, all peer certificates are rejected
+	clock.Advance(8 * 24 * clock.Hour)
+	resp, err = dial(good)
+	assert.Error(t, err)
+	assert.Empty(t, resp)
+
+	// A CRL not signed by the CA should be rejected
+	other, err := autotls.GenerateCA(autotls.CAOptions{KeyAlgorithm: autotls.KeyAlgorithmECDSAP256})
+	require.NoError(t, err)
+	crl, err = autotls.GenerateCRL(other, autotls.CRLOptions{})
+	require.NoError(t, err)
+	_, err = autotls.New(autotls.Config{
+		CaPEM:  bytes.NewBuffer(ca.CertPEM),
+		CRLPEM: bytes.NewBuffer(crl),
+	})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "CRL was not issued by any of the provided CAs")
+}
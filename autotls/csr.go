@@ -0,0 +1,150 @@
+package autotls
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net"
+
+	"github.com/kapetan-io/tackle/clock"
+	"github.com/kapetan-io/tackle/set"
+)
+
+const blockTypeCSR = "CERTIFICATE REQUEST"
+
+// CSROptions are the options used by CreateCSR()
+type CSROptions struct {
+	// (Optional) The organization name of the requested certificate
+	OrgName string
+
+	// (Optional) The common name of the requested certificate
+	CommonName string
+
+	// (Optional) DNS names included in the requested certificate
+	DNSNames []string
+
+	// (Optional) IP addresses included in the requested certificate
+	IPAddresses []string
+
+	// (Optional) URI SANs included in the requested certificate, such as a SPIFFE ID
+	URIs []string
+
+	// (Optional) The algorithm of the generated private key. Defaults to KeyAlgorithmECDSAP521
+	KeyAlgorithm KeyAlgorithm
+}
+
+// CreateCSR generates a private key and a certificate signing request for it, such that the key
+// never leaves the host. The PEM encoded CSR can be sent to a CA service which signs it with
+// SignCSR(), and the returned certificate used as CertPEM along with the key as KeyPEM.
+func CreateCSR(opts CSROptions) (csrPEM, keyPEM []byte, err error) {
+	tmpl := x509.CertificateRequest{
+		Subject:  pkix.Name{CommonName: opts.CommonName},
+		DNSNames: opts.DNSNames,
+	}
+	if opts.OrgName != "" {
+		tmpl.Subject.Organization = []string{opts.OrgName}
+	}
+
+	for _, ipStr := range opts.IPAddresses {
+		ip := net.ParseIP(ipStr)
+		if ip == nil {
+			return nil, nil, fmt.Errorf("invalid IP address '%s' in IPAddresses", ipStr)
+		}
+		tmpl.IPAddresses = append(tmpl.IPAddresses, ip)
+	}
+
+	if tmpl.URIs, err = parseURIs(opts.URIs); err != nil {
+		return nil, nil, err
+	}
+
+	key, err := generateKey(opts.KeyAlgorithm)
+	if err != nil {
+		return nil, nil, fmt.Errorf("while generating pubic/private key pair: %w", err)
+	}
+
+	der, err := x509.CreateCertificateRequest(rand.Reader, &tmpl, key)
+	if err != nil {
+		return nil, nil, fmt.Errorf("while creating certificate request: %w", err)
+	}
+
+	keyBuf, err := encodeKey(key)
+	if err != nil {
+		return nil, nil, err
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: blockTypeCSR, Bytes: der}), keyBuf.Bytes(), nil
+}
+
+// SignOptions are the options used by SignCSR()
+type SignOptions struct {
+	// (Optional) The extended key usages of the certificate. Defaults to both
+	// x509.ExtKeyUsageServerAuth and x509.ExtKeyUsageClientAuth
+	ExtKeyUsage []x509.ExtKeyUsage
+
+	// (Optional) How long the certificate is valid for. Defaults to 365 days
+	Duration clock.Duration
+
+	// (Optional) How far in the past the NotBefore of the certificate is set. Defaults to 0
+	NotBeforeSkew clock.Duration
+}
+
+// SignCSR signs the PEM encoded certificate signing request with the CA and returns the PEM
+// encoded certificate. The subject and SANs of the certificate are those requested in the CSR.
+// If the CA is an intermediate, the intermediate certificates in the CA bundle are included
+// in the returned PEM.
+func SignCSR(caPEM, caKeyPEM, csrPEM []byte, opts SignOptions) ([]byte, error) {
+	set.Default(&opts.ExtKeyUsage, []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth})
+	set.Default(&opts.Duration, 365*24*clock.Hour)
+
+	ca, err := ParseKeyPair(caPEM, caKeyPEM)
+	if err != nil {
+		return nil, fmt.Errorf("while parsing CA: %w", err)
+	}
+
+	block, _ := pem.Decode(csrPEM)
+	if block == nil || block.Type != blockTypeCSR {
+		return nil, errors.New("no CERTIFICATE REQUEST found in PEM")
+	}
+	csr, err := x509.ParseCertificateRequest(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("while parsing certificate request: %w", err)
+	}
+	if err := csr.CheckSignature(); err != nil {
+		return nil, fmt.Errorf("while verifying certificate request signature: %w", err)
+	}
+
+	serial, err := newSerial()
+	if err != nil {
+		return nil, err
+	}
+
+	now := clock.Now()
+	cert := x509.Certificate{
+		ExtKeyUsage:           opts.ExtKeyUsage,
+		KeyUsage:              publicKeyUsage(csr.PublicKey),
+		Subject:               csr.Subject,
+		DNSNames:              csr.DNSNames,
+		IPAddresses:           csr.IPAddresses,
+		URIs:                  csr.URIs,
+		SerialNumber:          serial,
+		NotBefore:             now.Add(-opts.NotBeforeSkew),
+		NotAfter:              now.Add(opts.Duration),
+		BasicConstraintsValid: true,
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, &cert, ca.Cert, csr.PublicKey, ca.Key)
+	if err != nil {
+		return nil, fmt.Errorf("while signing cert: %w", err)
+	}
+
+	var buf bytes.Buffer
+	for _, b := range append([][]byte{der}, chainOf(derOf(caPEM))...) {
+		if err := pem.Encode(&buf, &pem.Block{Type: blockTypeCert, Bytes: b}); err != nil {
+			return nil, fmt.Errorf("while encoding CERTIFICATE PEM: %w", err)
+		}
+	}
+	return buf.Bytes(), nil
+}
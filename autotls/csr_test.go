@@ -0,0 +1,82 @@
+package autotls_test
+
+import (
+	"bytes"
+	"crypto/x509"
+	"os"
+	"testing"
+
+	"github.com/kapetan-io/tackle/autotls"
+	"github.com/kapetan-io/tackle/clock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCSR(t *testing.T) {
+	caPEM, err := os.ReadFile("certs/ca.cert")
+	require.NoError(t, err)
+	caKeyPEM, err := os.ReadFile("certs/ca.key")
+	require.NoError(t, err)
+
+	csrPEM, keyPEM, err := autotls.CreateCSR(autotls.CSROptions{
+		OrgName:      "CSR Org",
+		CommonName:   "api",
+		DNSNames:     []string{"localhost", "api.example.com"},
+		IPAddresses:  []string{"127.0.0.1"},
+		URIs:         []string{"spiffe://example.org/api"},
+		KeyAlgorithm: autotls.KeyAlgorithmRSA2048,
+	})
+	require.NoError(t, err)
+	assert.Contains(t, string(csrPEM), "CERTIFICATE REQUEST")
+
+	// The CA service signs the CSR without ever seeing the private key
+	certPEM, err := autotls.SignCSR(caPEM, caKeyPEM, csrPEM, autotls.SignOptions{Duration: 24 * clock.Hour})
+	require.NoError(t, err)
+
+	kp, err := autotls.ParseKeyPair(certPEM, keyPEM)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"CSR Org"}, kp.Cert.Subject.Organization)
+	assert.Equal(t, "api", kp.Cert.Subject.CommonName)
+	assert.Equal(t, []string{"localhost", "api.example.com"}, kp.Cert.DNSNames)
+	assert.Equal(t, "spiffe://example.org/api", kp.Cert.URIs[0].String())
+	assert.Equal(t, x509.KeyUsageKeyEncipherment|x509.KeyUsageDigitalSignature, kp.Cert.KeyUsage)
+	assert.ElementsMatch(t, []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		kp.Cert.ExtKeyUsage)
+	assert.InDelta(t, 1, autotls.CertExpiry{NotAfter: kp.Cert.NotAfter}.Days(), 1)
+
+	// The signed certificate is installed through the usual Config plumbing
+	r, err := autotls.New(autotls.Config{
+		CaPEM:   bytes.NewBuffer(caPEM),
+		CertPEM: bytes.NewBuffer(certPEM),
+		KeyPEM:  bytes.NewBuffer(keyPEM),
+	})
+	require.NoError(t, err)
+	ln := serveTLS(t, r.ServerTLS)
+	assert.Equal(t, "CSR Org", peerOrg(t, ln.Addr(), r.ClientTLS))
+
+	clientCert, err := autotls.SignCSR(caPEM, caKeyPEM, csrPEM, autotls.SignOptions{
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	})
+	require.NoError(t, err)
+	kp, err = autotls.ParseKeyPair(clientCert, keyPEM)
+	require.NoError(t, err)
+	assert.Equal(t, []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth}, kp.Cert.ExtKeyUsage)
+}
+
+func TestSignCSRErrors(t *testing.T) {
+	caPEM, err := os.ReadFile("certs/ca.cert")
+	require.NoError(t, err)
+	caKeyPEM, err := os.ReadFile("certs/ca.key")
+	require.NoError(t, err)
+
+	_, err = autotls.SignCSR(caPEM, caKeyPEM, caPEM, autotls.SignOptions{})
+	require.ErrorContains(t, err, "no CERTIFICATE REQUEST found in PEM")
+
+	csrPEM, _, err := autotls.CreateCSR(autotls.CSROptions{})
+	require.NoError(t, err)
+	_, err = autotls.SignCSR(caPEM, nil, csrPEM, autotls.SignOptions{})
+	require.ErrorContains(t, err, "while parsing CA")
+
+	_, _, err = autotls.CreateCSR(autotls.CSROptions{IPAddresses: []string{"not-an-ip"}})
+	require.ErrorContains(t, err, "invalid IP address 'not-an-ip' in IPAddresses")
+}
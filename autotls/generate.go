@@ -0,0 +1,374 @@
+package autotls
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"net"
+	"net/url"
+
+	"github.com/kapetan-io/tackle/clock"
+	"github.com/kapetan-io/tackle/set"
+)
+
+// KeyPair is a certificate and its private key
+type KeyPair struct {
+	// The parsed certificate
+	Cert *x509.Certificate
+	// The private key of the certificate
+	Key crypto.Signer
+	// The PEM encoded certificate, followed by any intermediate certificates in the chain
+	CertPEM []byte
+	// The PEM encoded private key
+	KeyPEM []byte
+}
+
+// TLSCertificate returns the key pair as a tls.Certificate suitable for use in a tls.Config
+func (kp *KeyPair) TLSCertificate() (tls.Certificate, error) {
+	return tls.X509KeyPair(kp.CertPEM, kp.KeyPEM)
+}
+
+// ParseKeyPair parses the PEM encoded certificate and private key into a KeyPair. The certificate
+// PEM may contain a bundle, in which case the first certificate must match the private key.
+func ParseKeyPair(certPEM, keyPEM []byte) (*KeyPair, error) {
+	pair, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(pair.Certificate) == 0 {
+		return nil, errors.New("no certificates found in PEM")
+	}
+
+	cert, err := x509.ParseCertificate(pair.Certificate[0])
+	if err != nil {
+		return nil, fmt.Errorf("while parsing certificate: %w", err)
+	}
+
+	key, ok := pair.PrivateKey.(crypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("unsupported private key type '%T'", pair.PrivateKey)
+	}
+
+	return &KeyPair{
+		CertPEM: bytes.Clone(certPEM),
+		KeyPEM:  bytes.Clone(keyPEM),
+		Cert:    cert,
+		Key:     key,
+	}, nil
+}
+
+// CAOptions are the options used by GenerateCA()
+type CAOptions struct {
+	// (Optional) The organization name of the CA. Defaults to "Self Signed Org"
+	OrgName string
+
+	// (Optional) The algorithm of the generated private key. Defaults to KeyAlgorithmECDSAP521
+	KeyAlgorithm KeyAlgorithm
+
+	// (Optional) How long the CA is valid for. Defaults to 10 years
+	Duration clock.Duration
+
+	// (Optional) How far in the past the NotBefore of the CA is set. Defaults to 0
+	NotBeforeSkew clock.Duration
+}
+
+// GenerateCA generates a self-signed certificate authority
+func GenerateCA(opts CAOptions) (*KeyPair, error) {
+	set.Default(&opts.OrgName, "Self Signed Org")
+	set.Default(&opts.Duration, 10*365*24*clock.Hour)
+
+	serial, err := newSerial()
+	if err != nil {
+		return nil, err
+	}
+
+	now := clock.Now()
+	ca := x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{Organization: []string{opts.OrgName}},
+		NotBefore:             now.Add(-opts.NotBeforeSkew),
+		NotAfter:              now.Add(opts.Duration),
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth, x509.ExtKeyUsageServerAuth},
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+
+	privKey, err := generateKey(opts.KeyAlgorithm)
+	if err != nil {
+		return nil, fmt.Errorf("while generating pubic/private key pair: %w", err)
+	}
+
+	b, err := x509.CreateCertificate(rand.Reader, &ca, &ca, privKey.Public(), privKey)
+	if err != nil {
+		return nil, fmt.Errorf("while self signing CA certificate: %w", err)
+	}
+	return newKeyPair(b, nil, privKey)
+}
+
+// CertOptions are the options used by GenerateCert()
+type CertOptions struct {
+	// (Optional) The organization name of the certificate. Defaults to "Self Signed Org"
+	OrgName string
+
+	// (Optional) DNS names included in the certificate in addition to 'localhost'
+	DNSNames []string
+
+	// (Optional) IP addresses included in the certificate in addition to '127.0.0.1'
+	IPAddresses []string
+
+	// (Optional) URI SANs included in the certificate, such as a SPIFFE ID
+	URIs []string
+
+	// (Optional) If true, the ip addresses and names of the current host are not discovered
+	// and included in the certificate.
+	DisableDiscovery bool
+
+	// (Optional) If true, only IPv4 addresses are discovered; global unicast IPv6 addresses are
+	// not included in the certificate.
+	DisableIPv6Discovery bool
+
+	// (Optional) The algorithm of the generated private key. Defaults to KeyAlgorithmECDSAP521
+	KeyAlgorithm KeyAlgorithm
+
+	// (Optional) How long the certificate is valid for. Defaults to 365 days
+	Duration clock.Duration
+
+	// (Optional) How far in the past the NotBefore of the certificate is set. Defaults to 0
+	NotBeforeSkew clock.Duration
+}
+
+// GenerateCert generates a certificate suitable for both server and client authentication
+// which is signed by the provided CA. If the CA is an intermediate, the intermediate
+// certificates in the CA bundle are included in the CertPEM of the returned KeyPair.
+func GenerateCert(ca *KeyPair, opts CertOptions) (*KeyPair, error) {
+	if ca == nil || ca.Cert == nil || ca.Key == nil {
+		return nil, errors.New("unable to generate certs without a signing CA")
+	}
+
+	var network netInfo
+	if !opts.DisableDiscovery {
+		var err error
+		network, err = discoverNetwork(!opts.DisableIPv6Discovery)
+		if err != nil {
+			return nil, fmt.Errorf("while detecting ip and host names: %w", err)
+		}
+	}
+	return generateCert(ca, opts, network)
+}
+
+// generateCert generates the certificate including the discovered network names and addresses
+func generateCert(ca *KeyPair, opts CertOptions, network netInfo) (*KeyPair, error) {
+	set.Default(&opts.OrgName, "Self Signed Org")
+	set.Default(&opts.Duration, 365*24*clock.Hour)
+
+	serial, err := newSerial()
+	if err != nil {
+		return nil, err
+	}
+
+	now := clock.Now()
+	cert := x509.Certificate{
+		ExtKeyUsage: []x509.ExtKeyUsage{
+			x509.ExtKeyUsageClientAuth,
+			x509.ExtKeyUsageServerAuth,
+		},
+		Subject:               pkix.Name{Organization: []string{opts.OrgName}},
+		NotAfter:              now.Add(opts.Duration),
+		DNSNames:              []string{"localhost"},
+		IPAddresses:           []net.IP{net.ParseIP("127.0.0.1")},
+		SerialNumber:          serial,
+		NotBefore:             now.Add(-opts.NotBeforeSkew),
+		BasicConstraintsValid: true,
+	}
+
+	// Include any explicitly configured names and ip addresses
+	cert.DNSNames = append(cert.DNSNames, opts.DNSNames...)
+	for _, ipStr := range opts.IPAddresses {
+		ip := net.ParseIP(ipStr)
+		if ip == nil {
+			return nil, fmt.Errorf("invalid IP address '%s' in IPAddresses", ipStr)
+		}
+		cert.IPAddresses = append(cert.IPAddresses, ip)
+	}
+
+	if cert.URIs, err = parseURIs(opts.URIs); err != nil {
+		return nil, err
+	}
+
+	// Ensure all our names and ip addresses are included in the Certificate
+	cert.DNSNames = append(cert.DNSNames, network.DNSNames...)
+	for _, ipStr := range network.IPAddresses {
+		if ip := net.ParseIP(ipStr); ip != nil {
+			cert.IPAddresses = append(cert.IPAddresses, ip)
+		}
+	}
+
+	// Generate a public / private key
+	privKey, err := generateKey(opts.KeyAlgorithm)
+	if err != nil {
+		return nil, fmt.Errorf("while generating pubic/private key pair: %w", err)
+	}
+	cert.KeyUsage = keyUsage(privKey)
+
+	signedBytes, err := x509.CreateCertificate(rand.Reader, &cert, ca.Cert, privKey.Public(), ca.Key)
+	if err != nil {
+		return nil, fmt.Errorf("while signing cert: %w", err)
+	}
+
+	// If the signing CA is an intermediate, include the chain such that clients which
+	// only trust the root can verify the certificate.
+	return newKeyPair(signedBytes, chainOf(derOf(ca.CertPEM)), privKey)
+}
+
+// ClientCertOptions are the options used by GenerateClientCert()
+type ClientCertOptions struct {
+	// (Optional) The organization name of the certificate. Defaults to 'Self Signed Org'
+	OrgName string
+
+	// (Optional) URI SANs included in the certificate, such as a SPIFFE ID
+	URIs []string
+
+	// (Optional) The algorithm of the generated private key. Defaults to KeyAlgorithmECDSAP521
+	KeyAlgorithm KeyAlgorithm
+
+	// (Optional) How long the certificate is valid for. Defaults to 365 days
+	Duration clock.Duration
+
+	// (Optional) How far in the past the NotBefore of the certificate is set. Defaults to 0
+	NotBeforeSkew clock.Duration
+}
+
+// GenerateClientCert generates a certificate which is only suitable for client authentication
+// and is signed by the provided CA. If the CA is an intermediate, the intermediate certificates
+// in the CA bundle are included in the CertPEM of the returned KeyPair.
+func GenerateClientCert(ca *KeyPair, opts ClientCertOptions) (*KeyPair, error) {
+	set.Default(&opts.OrgName, "Self Signed Org")
+	set.Default(&opts.Duration, 365*24*clock.Hour)
+
+	if ca == nil || ca.Cert == nil || ca.Key == nil {
+		return nil, errors.New("unable to generate certs without a signing CA")
+	}
+
+	serial, err := newSerial()
+	if err != nil {
+		return nil, err
+	}
+
+	now := clock.Now()
+	cert := x509.Certificate{
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+		Subject:               pkix.Name{Organization: []string{opts.OrgName}},
+		NotAfter:              now.Add(opts.Duration),
+		SerialNumber:          serial,
+		NotBefore:             now.Add(-opts.NotBeforeSkew),
+		BasicConstraintsValid: true,
+	}
+
+	if cert.URIs, err = parseURIs(opts.URIs); err != nil {
+		return nil, err
+	}
+
+	privKey, err := generateKey(opts.KeyAlgorithm)
+	if err != nil {
+		return nil, fmt.Errorf("while generating pubic/private key pair: %w", err)
+	}
+	cert.KeyUsage = keyUsage(privKey)
+
+	signedBytes, err := x509.CreateCertificate(rand.Reader, &cert, ca.Cert, privKey.Public(), ca.Key)
+	if err != nil {
+		return nil, fmt.Errorf("while signing cert: %w", err)
+	}
+	return newKeyPair(signedBytes, chainOf(derOf(ca.CertPEM)), privKey)
+}
+
+func parseURIs(uris []string) ([]*url.URL, error) {
+	var results []*url.URL
+	for _, uri := range uris {
+		u, err := url.Parse(uri)
+		if err != nil {
+			return nil, fmt.Errorf("invalid URI '%s' in URIs: %w", uri, err)
+		}
+		results = append(results, u)
+	}
+	return results, nil
+}
+
+// newKeyPair encodes the DER certificate, followed by the chain and the private key into a KeyPair
+func newKeyPair(der []byte, chain [][]byte, key crypto.Signer) (*KeyPair, error) {
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, fmt.Errorf("while parsing generated certificate: %w", err)
+	}
+
+	var buf bytes.Buffer
+	for _, b := range append([][]byte{der}, chain...) {
+		if err := pem.Encode(&buf, &pem.Block{
+			Type:  blockTypeCert,
+			Bytes: b,
+		}); err != nil {
+			return nil, fmt.Errorf("while encoding CERTIFICATE PEM: %w", err)
+		}
+	}
+
+	keyPEM, err := encodeKey(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return &KeyPair{
+		CertPEM: buf.Bytes(),
+		KeyPEM:  keyPEM.Bytes(),
+		Cert:    cert,
+		Key:     key,
+	}, nil
+}
+
+// newSerial returns a random 128 bit serial number
+func newSerial() (*big.Int, error) {
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, fmt.Errorf("while generating serial number: %w", err)
+	}
+	return serial, nil
+}
+
+// derOf returns the DER bytes of all the certificates in the PEM
+func derOf(b []byte) [][]byte {
+	var results [][]byte
+	for {
+		var block *pem.Block
+		block, b = pem.Decode(b)
+		if block == nil {
+			return results
+		}
+		if block.Type == blockTypeCert {
+			results = append(results, block.Bytes)
+		}
+	}
+}
+
+// chainOf returns the certificates in the CA bundle which should be presented along with a certificate
+// issued by the first CA in the bundle. Self-signed roots are excluded as clients must already trust them.
+func chainOf(bundle [][]byte) [][]byte {
+	var results [][]byte
+	for _, der := range bundle {
+		c, err := x509.ParseCertificate(der)
+		if err != nil {
+			continue
+		}
+		if bytes.Equal(c.RawIssuer, c.RawSubject) && c.CheckSignatureFrom(c) == nil {
+			continue
+		}
+		results = append(results, der)
+	}
+	return results
+}
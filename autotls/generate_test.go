@@ -0,0 +1,86 @@
+package autotls_test
+
+import (
+	"crypto/x509"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/kapetan-io/tackle/autotls"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGenerateCert(t *testing.T) {
+	ca, err := autotls.GenerateCA(autotls.CAOptions{
+		OrgName:      "Test CA",
+		KeyAlgorithm: autotls.KeyAlgorithmECDSAP256,
+	})
+	require.NoError(t, err)
+	assert.True(t, ca.Cert.IsCA)
+	assert.Equal(t, []string{"Test CA"}, ca.Cert.Subject.Organization)
+	assert.WithinDuration(t, time.Now().Add(10*365*24*time.Hour), ca.Cert.NotAfter, time.Minute)
+
+	cert, err := autotls.GenerateCert(ca, autotls.CertOptions{
+		DNSNames:         []string{"api.example.com"},
+		DisableDiscovery: true,
+		KeyAlgorithm:     autotls.KeyAlgorithmECDSAP256,
+		Duration:         time.Hour,
+	})
+	require.NoError(t, err)
+	assert.False(t, cert.Cert.IsCA)
+	assert.Equal(t, []string{"localhost", "api.example.com"}, cert.Cert.DNSNames)
+	assert.WithinDuration(t, time.Now().Add(time.Hour), cert.Cert.NotAfter, time.Minute)
+	assert.NotEqual(t, ca.Cert.SerialNumber, cert.Cert.SerialNumber)
+
+	// The certificate should verify against the CA
+	pool := x509.NewCertPool()
+	pool.AddCert(ca.Cert)
+	_, err = cert.Cert.Verify(x509.VerifyOptions{DNSName: "api.example.com", Roots: pool})
+	require.NoError(t, err)
+
+	// The key pair should be usable in a tls.Config
+	tlsCert, err := cert.TLSCertificate()
+	require.NoError(t, err)
+	assert.Len(t, tlsCert.Certificate, 1)
+
+	// The PEMs should round trip
+	parsed, err := autotls.ParseKeyPair(cert.CertPEM, cert.KeyPEM)
+	require.NoError(t, err)
+	assert.Equal(t, cert.Cert.Raw, parsed.Cert.Raw)
+
+	// Should be able to sign with a CA loaded from disk
+	caPEM, err := os.ReadFile("certs/ca.cert")
+	require.NoError(t, err)
+	caKeyPEM, err := os.ReadFile("certs/ca.key")
+	require.NoError(t, err)
+	loaded, err := autotls.ParseKeyPair(caPEM, caKeyPEM)
+	require.NoError(t, err)
+	_, err = autotls.GenerateCert(loaded, autotls.CertOptions{DisableDiscovery: true})
+	require.NoError(t, err)
+
+	_, err = autotls.GenerateCert(nil, autotls.CertOptions{})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "without a signing CA")
+}
+
+func TestGenerateClientCert(t *testing.T) {
+	ca, err := autotls.GenerateCA(autotls.CAOptions{KeyAlgorithm: autotls.KeyAlgorithmECDSAP256})
+	require.NoError(t, err)
+
+	kp, err := autotls.GenerateClientCert(ca, autotls.ClientCertOptions{
+		OrgName: "Client Org",
+		URIs:    []string{"spiffe://example.org/client"},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth}, kp.Cert.ExtKeyUsage)
+	assert.Equal(t, []string{"Client Org"}, kp.Cert.Subject.Organization)
+	assert.Empty(t, kp.Cert.DNSNames)
+	assert.Empty(t, kp.Cert.IPAddresses)
+	require.Len(t, kp.Cert.URIs, 1)
+	assert.Equal(t, "spiffe://example.org/client", kp.Cert.URIs[0].String())
+	require.NoError(t, kp.Cert.CheckSignatureFrom(ca.Cert))
+
+	_, err = autotls.GenerateClientCert(nil, autotls.ClientCertOptions{})
+	require.ErrorContains(t, err, "without a signing CA")
+}
@@ -0,0 +1,22 @@
+// Package grpccreds wraps the TLS configs built by autotls in gRPC transport credentials. It is a
+// separate module such that autotls, and the rest of tackle, do not depend upon gRPC.
+package grpccreds
+
+import (
+	"github.com/kapetan-io/tackle/autotls"
+	"google.golang.org/grpc/credentials"
+)
+
+// ServerCredentials returns gRPC transport credentials which serve TLS using the ServerTLS of the result
+//
+//	srv := grpc.NewServer(grpc.Creds(grpccreds.ServerCredentials(result)))
+func ServerCredentials(r *autotls.Result) credentials.TransportCredentials {
+	return credentials.NewTLS(r.ServerTLS)
+}
+
+// ClientCredentials returns gRPC transport credentials which connect using the ClientTLS of the result
+//
+//	conn, err := grpc.NewClient(addr, grpc.WithTransportCredentials(grpccreds.ClientCredentials(result)))
+func ClientCredentials(r *autotls.Result) credentials.TransportCredentials {
+	return credentials.NewTLS(r.ClientTLS)
+}
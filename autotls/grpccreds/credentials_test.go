@@ -0,0 +1,54 @@
+package grpccreds_test
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"testing"
+
+	"github.com/kapetan-io/tackle/autotls"
+	"github.com/kapetan-io/tackle/autotls/grpccreds"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	"google.golang.org/grpc/health/grpc_health_v1"
+)
+
+func TestCredentials(t *testing.T) {
+	r, err := autotls.New(autotls.Config{
+		AutoTLS:              true,
+		DisableDiscovery:     true,
+		ClientAuth:           tls.RequireAndVerifyClientCert,
+		ClientAuthServerName: "localhost",
+	})
+	require.NoError(t, err)
+
+	ln, err := net.Listen("tcp", "localhost:0")
+	require.NoError(t, err)
+	srv := grpc.NewServer(grpc.Creds(grpccreds.ServerCredentials(r)))
+	grpc_health_v1.RegisterHealthServer(srv, health.NewServer())
+	go func() { _ = srv.Serve(ln) }()
+	defer srv.Stop()
+
+	conn, err := grpc.NewClient(ln.Addr().String(),
+		grpc.WithTransportCredentials(grpccreds.ClientCredentials(r)))
+	require.NoError(t, err)
+	defer func() { _ = conn.Close() }()
+
+	resp, err := grpc_health_v1.NewHealthClient(conn).Check(context.Background(),
+		&grpc_health_v1.HealthCheckRequest{})
+	require.NoError(t, err)
+	assert.Equal(t, grpc_health_v1.HealthCheckResponse_SERVING, resp.Status)
+
+	// Clients which do not trust the generated CA are rejected
+	other, err := autotls.New(autotls.Config{AutoTLS: true, DisableDiscovery: true})
+	require.NoError(t, err)
+	conn, err = grpc.NewClient(ln.Addr().String(),
+		grpc.WithTransportCredentials(grpccreds.ClientCredentials(other)))
+	require.NoError(t, err)
+	defer func() { _ = conn.Close() }()
+	_, err = grpc_health_v1.NewHealthClient(conn).Check(context.Background(),
+		&grpc_health_v1.HealthCheckRequest{})
+	require.Error(t, err)
+}
@@ -0,0 +1,48 @@
+package autotls
+
+import (
+	"crypto/tls"
+	"net/http"
+
+	"github.com/kapetan-io/tackle/clock"
+)
+
+// NewHTTPServer returns an *http.Server which serves TLS using ServerTLS with sane timeouts.
+// Setup() must be called before calling this method. Since the TLS certificates are provided via
+// ServerTLS, call ListenAndServeTLS("", "") or ServeTLS(l, "", "") to start the server.
+func (c *Config) NewHTTPServer(addr string, handler http.Handler) *http.Server {
+	return newHTTPServer(c.ServerTLS, addr, handler)
+}
+
+// NewHTTPClient returns an *http.Client which uses ClientTLS. Setup() must be called before
+// calling this method.
+func (c *Config) NewHTTPClient() *http.Client {
+	return newHTTPClient(c.ClientTLS)
+}
+
+// NewHTTPServer returns an *http.Server which serves TLS using ServerTLS with sane timeouts.
+// See Config.NewHTTPServer() for details.
+func (r *Result) NewHTTPServer(addr string, handler http.Handler) *http.Server {
+	return newHTTPServer(r.ServerTLS, addr, handler)
+}
+
+// NewHTTPClient returns an *http.Client which uses ClientTLS
+func (r *Result) NewHTTPClient() *http.Client {
+	return newHTTPClient(r.ClientTLS)
+}
+
+func newHTTPServer(conf *tls.Config, addr string, handler http.Handler) *http.Server {
+	return &http.Server{
+		Addr:              addr,
+		Handler:           handler,
+		TLSConfig:         conf,
+		ReadHeaderTimeout: 10 * clock.Second,
+		IdleTimeout:       2 * clock.Minute,
+	}
+}
+
+func newHTTPClient(conf *tls.Config) *http.Client {
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	t.TLSClientConfig = conf
+	return &http.Client{Transport: t}
+}
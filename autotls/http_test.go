@@ -0,0 +1,43 @@
+package autotls_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/kapetan-io/tackle/autotls"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResultNewHTTPServerAndClient(t *testing.T) {
+	r, err := autotls.New(autotls.Config{AutoTLS: true, DisableDiscovery: true})
+	require.NoError(t, err)
+
+	srv := r.NewHTTPServer("localhost:0", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = fmt.Fprintln(w, "Hello, client")
+	}))
+	assert.Equal(t, r.ServerTLS, srv.TLSConfig)
+	assert.Equal(t, 10*time.Second, srv.ReadHeaderTimeout)
+
+	ln, err := net.Listen("tcp", srv.Addr)
+	require.NoError(t, err)
+	done := make(chan error)
+	go func() { done <- srv.ServeTLS(ln, "", "") }()
+
+	resp, err := r.NewHTTPClient().Get("https://" + ln.Addr().String())
+	require.NoError(t, err)
+	b, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	_ = resp.Body.Close()
+	assert.Equal(t, "Hello, client\n", string(b))
+	assert.Equal(t, 2, resp.ProtoMajor)
+
+	require.NoError(t, srv.Shutdown(context.Background()))
+	assert.True(t, errors.Is(<-done, http.ErrServerClosed))
+}
@@ -0,0 +1,86 @@
+package autotls
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+)
+
+// KeyAlgorithm is the algorithm used when generating private keys
+type KeyAlgorithm string
+
+const (
+	KeyAlgorithmECDSAP256 KeyAlgorithm = "ecdsa-p256"
+	KeyAlgorithmECDSAP384 KeyAlgorithm = "ecdsa-p384"
+	KeyAlgorithmECDSAP521 KeyAlgorithm = "ecdsa-p521"
+	KeyAlgorithmRSA2048   KeyAlgorithm = "rsa-2048"
+	KeyAlgorithmRSA4096   KeyAlgorithm = "rsa-4096"
+	KeyAlgorithmEd25519   KeyAlgorithm = "ed25519"
+)
+
+// generateKey generates a new private key using the provided algorithm
+func generateKey(alg KeyAlgorithm) (crypto.Signer, error) {
+	switch alg {
+	case KeyAlgorithmECDSAP256:
+		return ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	case KeyAlgorithmECDSAP384:
+		return ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	case KeyAlgorithmECDSAP521, "":
+		return ecdsa.GenerateKey(elliptic.P521(), rand.Reader)
+	case KeyAlgorithmRSA2048:
+		return rsa.GenerateKey(rand.Reader, 2048)
+	case KeyAlgorithmRSA4096:
+		return rsa.GenerateKey(rand.Reader, 4096)
+	case KeyAlgorithmEd25519:
+		_, key, err := ed25519.GenerateKey(rand.Reader)
+		return key, err
+	}
+	return nil, fmt.Errorf("unknown key algorithm '%s'", alg)
+}
+
+// encodeKey encodes the private key into PEM. ECDSA keys are encoded as 'EC PRIVATE KEY'
+// all other keys are encoded as PKCS #8 'PRIVATE KEY'
+func encodeKey(key crypto.Signer) (*bytes.Buffer, error) {
+	var block pem.Block
+	switch k := key.(type) {
+	case *ecdsa.PrivateKey:
+		b, err := x509.MarshalECPrivateKey(k)
+		if err != nil {
+			return nil, fmt.Errorf("while marshalling EC private key: %w", err)
+		}
+		block = pem.Block{Type: blockTypeEC, Bytes: b}
+	default:
+		b, err := x509.MarshalPKCS8PrivateKey(k)
+		if err != nil {
+			return nil, fmt.Errorf("while marshalling private key: %w", err)
+		}
+		block = pem.Block{Type: blockTypePriv, Bytes: b}
+	}
+
+	buf := new(bytes.Buffer)
+	if err := pem.Encode(buf, &block); err != nil {
+		return nil, fmt.Errorf("while encoding private key into PEM: %w", err)
+	}
+	return buf, nil
+}
+
+// keyUsage returns the appropriate key usage for a certificate with the provided key. Only
+// RSA keys are used for key encipherment.
+func keyUsage(key crypto.Signer) x509.KeyUsage {
+	return publicKeyUsage(key.Public())
+}
+
+// publicKeyUsage returns the key usage appropriate for the type of public key
+func publicKeyUsage(pub crypto.PublicKey) x509.KeyUsage {
+	if _, ok := pub.(*rsa.PublicKey); ok {
+		return x509.KeyUsageKeyEncipherment | x509.KeyUsageDigitalSignature
+	}
+	return x509.KeyUsageDigitalSignature
+}
@@ -0,0 +1,88 @@
+package autotls_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"testing"
+
+	"github.com/kapetan-io/tackle/autotls"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSetupKeyAlgorithm(t *testing.T) {
+	for _, tt := range []struct {
+		alg    autotls.KeyAlgorithm
+		assert func(t *testing.T, key any)
+	}{
+		{
+			alg: "",
+			assert: func(t *testing.T, key any) {
+				require.IsType(t, &ecdsa.PublicKey{}, key)
+				assert.Equal(t, elliptic.P521(), key.(*ecdsa.PublicKey).Curve)
+			},
+		},
+		{
+			alg: autotls.KeyAlgorithmECDSAP256,
+			assert: func(t *testing.T, key any) {
+				require.IsType(t, &ecdsa.PublicKey{}, key)
+				assert.Equal(t, elliptic.P256(), key.(*ecdsa.PublicKey).Curve)
+			},
+		},
+		{
+			alg: autotls.KeyAlgorithmECDSAP384,
+			assert: func(t *testing.T, key any) {
+				require.IsType(t, &ecdsa.PublicKey{}, key)
+				assert.Equal(t, elliptic.P384(), key.(*ecdsa.PublicKey).Curve)
+			},
+		},
+		{
+			alg: autotls.KeyAlgorithmRSA2048,
+			assert: func(t *testing.T, key any) {
+				require.IsType(t, &rsa.PublicKey{}, key)
+				assert.Equal(t, 2048, key.(*rsa.PublicKey).N.BitLen())
+			},
+		},
+		{
+			alg: autotls.KeyAlgorithmEd25519,
+			assert: func(t *testing.T, key any) {
+				assert.IsType(t, ed25519.PublicKey{}, key)
+			},
+		},
+	} {
+		t.Run(string(tt.alg), func(t *testing.T) {
+			conf := autotls.Config{AutoTLS: true, KeyAlgorithm: tt.alg}
+			require.NoError(t, autotls.Setup(&conf))
+			leaf, err := x509.ParseCertificate(conf.ServerTLS.Certificates[0].Certificate[0])
+			require.NoError(t, err)
+			tt.assert(t, leaf.PublicKey)
+
+			// The generated certificate should be usable for a TLS handshake
+			ln, err := tls.Listen("tcp", "localhost:0", conf.ServerTLS)
+			require.NoError(t, err)
+			defer func() { _ = ln.Close() }()
+			go func() {
+				conn, err := ln.Accept()
+				if err != nil {
+					return
+				}
+				_ = conn.(*tls.Conn).Handshake()
+				_ = conn.Close()
+			}()
+			client := conf.ClientTLS.Clone()
+			client.ServerName = "localhost"
+			conn, err := tls.Dial("tcp", ln.Addr().String(), client)
+			require.NoError(t, err)
+			_ = conn.Close()
+		})
+	}
+
+	conf := autotls.Config{AutoTLS: true, KeyAlgorithm: "dsa"}
+	err := autotls.Setup(&conf)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "unknown key algorithm 'dsa'")
+}
@@ -0,0 +1,43 @@
+package autotls
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// The names of the files in a mounted kubernetes.io/tls secret
+const (
+	KubernetesCertFile = "tls.crt"
+	KubernetesKeyFile  = "tls.key"
+	KubernetesCaFile   = "ca.crt"
+)
+
+// FromKubernetesSecret returns a Config which loads the certificate, private key and CA (if present)
+// from the directory a kubernetes.io/tls secret is mounted at. Reload is enabled, such that when
+// the secret is updated and the kubelet rotates the projected files, the new certificate is used
+// without restarting. Further fields may be set on the returned Config before calling Setup().
+func FromKubernetesSecret(dir string) (Config, error) {
+	conf := Config{
+		CertFile: filepath.Join(dir, KubernetesCertFile),
+		KeyFile:  filepath.Join(dir, KubernetesKeyFile),
+		Reload:   true,
+	}
+
+	for _, name := range []string{conf.CertFile, conf.KeyFile} {
+		if _, err := os.Stat(name); err != nil {
+			return Config{}, fmt.Errorf("while reading kubernetes secret '%s': %w", dir, err)
+		}
+	}
+
+	// The CA is optional, and only present if the issuer provided one
+	caFile := filepath.Join(dir, KubernetesCaFile)
+	if _, err := os.Stat(caFile); err == nil {
+		conf.CaFile = caFile
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		return Config{}, fmt.Errorf("while reading kubernetes secret '%s': %w", dir, err)
+	}
+	return conf, nil
+}
@@ -0,0 +1,86 @@
+package autotls_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/kapetan-io/tackle/autotls"
+	"github.com/kapetan-io/tackle/clock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeSecret writes the files the same way the kubelet projects a secret into a volume; the files
+// are written to a new directory and the '..data' symlink is atomically swapped to point at it.
+func writeSecret(t *testing.T, dir, version, org string) {
+	t.Helper()
+	conf := autotls.Config{
+		CaFile:           "certs/ca.cert",
+		CaKeyFile:        "certs/ca.key",
+		ServerOrgName:    org,
+		AutoTLS:          true,
+		DisableDiscovery: true,
+	}
+	require.NoError(t, autotls.Setup(&conf))
+
+	data := filepath.Join(dir, version)
+	require.NoError(t, os.Mkdir(data, 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(data, autotls.KubernetesCertFile), conf.CertPEM.Bytes(), 0600))
+	require.NoError(t, os.WriteFile(filepath.Join(data, autotls.KubernetesKeyFile), conf.KeyPEM.Bytes(), 0600))
+	require.NoError(t, os.WriteFile(filepath.Join(data, autotls.KubernetesCaFile), conf.CaPEM.Bytes(), 0600))
+
+	tmp := filepath.Join(dir, "..data_tmp")
+	require.NoError(t, os.Symlink(version, tmp))
+	require.NoError(t, os.Rename(tmp, filepath.Join(dir, "..data")))
+
+	for _, name := range []string{autotls.KubernetesCertFile, autotls.KubernetesKeyFile, autotls.KubernetesCaFile} {
+		link := filepath.Join(dir, name)
+		if _, err := os.Lstat(link); err == nil {
+			continue
+		}
+		require.NoError(t, os.Symlink(filepath.Join("..data", name), link))
+	}
+}
+
+func TestFromKubernetesSecret(t *testing.T) {
+	defer clock.Freeze(clock.Now()).UnFreeze()
+
+	dir := t.TempDir()
+	writeSecret(t, dir, "..2024_01_01", "First")
+
+	conf, err := autotls.FromKubernetesSecret(dir)
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(dir, autotls.KubernetesCaFile), conf.CaFile)
+	assert.True(t, conf.Reload)
+
+	r, err := autotls.New(conf)
+	require.NoError(t, err)
+
+	ln := serveTLS(t, r.ServerTLS)
+	assert.Equal(t, "First", peerOrg(t, ln.Addr(), r.ClientTLS))
+
+	writeSecret(t, dir, "..2024_02_01", "Second")
+	clock.Advance(10 * clock.Second)
+	assert.Equal(t, "Second", peerOrg(t, ln.Addr(), r.ClientTLS))
+}
+
+func TestFromKubernetesSecretErrors(t *testing.T) {
+	dir := t.TempDir()
+	_, err := autotls.FromKubernetesSecret(dir)
+	require.ErrorContains(t, err, "while reading kubernetes secret")
+
+	// The CA is optional
+	require.NoError(t, os.Symlink(mustAbs(t, "certs/auto.pem"), filepath.Join(dir, "tls.crt")))
+	require.NoError(t, os.Symlink(mustAbs(t, "certs/auto.key"), filepath.Join(dir, "tls.key")))
+	conf, err := autotls.FromKubernetesSecret(dir)
+	require.NoError(t, err)
+	assert.Empty(t, conf.CaFile)
+}
+
+func mustAbs(t *testing.T, name string) string {
+	t.Helper()
+	abs, err := filepath.Abs(name)
+	require.NoError(t, err)
+	return abs
+}
@@ -1,5 +1,9 @@
 package autotls
 
+import "log/slog"
+
+// StandardLogger is the logger interface used by autotls. *slog.Logger implements this interface,
+// such that autotls logging integrates with the color package without an adapter.
 type StandardLogger interface {
 	Error(msg string, args ...any)
 	Info(msg string, args ...any)
@@ -7,6 +11,8 @@ type StandardLogger interface {
 	Warn(msg string, args ...any)
 }
 
+var _ StandardLogger = (*slog.Logger)(nil)
+
 type NoOpLogger struct{}
 
 func (NoOpLogger) Error(msg string, args ...any) {}
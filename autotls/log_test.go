@@ -0,0 +1,34 @@
+package autotls_test
+
+import (
+	"bytes"
+	"log/slog"
+	"testing"
+
+	"github.com/kapetan-io/tackle/autotls"
+	"github.com/kapetan-io/tackle/color"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSetupSlogLogger(t *testing.T) {
+	var buf bytes.Buffer
+	log := slog.New(color.NewLog(&color.LogOptions{
+		HandlerOptions: slog.HandlerOptions{
+			ReplaceAttr: color.SuppressAttrs(slog.TimeKey),
+		},
+		ColorFunc: color.NoColor,
+		Writer:    &buf,
+	}))
+
+	conf := autotls.Config{
+		AutoTLS:          true,
+		DisableDiscovery: true,
+		Logger:           log,
+	}
+	require.NoError(t, autotls.Setup(&conf))
+	assert.Equal(t, "INFO: AutoTLS Enabled \n"+
+		"INFO: Generating CA Certificates.... \n"+
+		"INFO: Generating Server Private Key and Certificate.... dns-names=localhost cert-ips=127.0.0.1\n",
+		buf.String())
+}
@@ -0,0 +1,201 @@
+package autotls
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/kapetan-io/tackle/clock"
+)
+
+// OCSPFetcher fetches and verifies the OCSP response for the leaf certificate which is stapled
+// to the server certificate during the TLS handshake. The separate autotls/ocsp module provides
+// a fetcher which requests responses from an OCSP responder via HTTP.
+type OCSPFetcher interface {
+	FetchOCSP(ctx context.Context, leaf, issuer *x509.Certificate) (*OCSPResponse, error)
+}
+
+// OCSPStatus is the revocation status of a certificate reported by an OCSP response
+type OCSPStatus int
+
+const (
+	OCSPGood OCSPStatus = iota
+	OCSPRevoked
+	OCSPUnknown
+)
+
+func (s OCSPStatus) String() string {
+	switch s {
+	case OCSPGood:
+		return "good"
+	case OCSPRevoked:
+		return "revoked"
+	default:
+		return "unknown"
+	}
+}
+
+// OCSPResponse is an OCSP response for the leaf certificate, which the OCSPFetcher has verified
+// is signed by the issuer of the leaf certificate or by a responder the issuer delegated to.
+type OCSPResponse struct {
+	// The DER encoded response which is stapled to the server certificate
+	Staple []byte
+	// The status of the leaf certificate. Only responses reporting the certificate as good are stapled
+	Status     OCSPStatus
+	ThisUpdate clock.Time
+	// Zero if the responder did not indicate when a newer response will be available
+	NextUpdate clock.Time
+}
+
+// checkOCSPResponse returns an error if the response does not report the leaf certificate as
+// good, or if the response is not yet valid or has expired.
+func checkOCSPResponse(r *OCSPResponse, leaf *x509.Certificate) error {
+	if len(r.Staple) == 0 {
+		return errors.New("OCSP response is empty")
+	}
+	if r.Status != OCSPGood {
+		return fmt.Errorf("OCSP response reports certificate with serial '%s' as %s",
+			leaf.SerialNumber, r.Status)
+	}
+	now := clock.Now()
+	if now.Before(r.ThisUpdate) {
+		return fmt.Errorf("OCSP response is not valid until %s", r.ThisUpdate)
+	}
+	if !r.NextUpdate.IsZero() && now.After(r.NextUpdate) {
+		return fmt.Errorf("OCSP response expired at %s", r.NextUpdate)
+	}
+	return nil
+}
+
+// How soon a failed OCSP fetch is retried
+const ocspRetryInterval = clock.Minute
+
+// ocspStapler provides the server certificate with the most recently fetched OCSP response stapled
+type ocspStapler struct {
+	mutex    sync.Mutex
+	fetcher  OCSPFetcher
+	leaf     *x509.Certificate
+	issuer   *x509.Certificate
+	interval clock.Duration
+	cert     *tls.Certificate
+	// When the stapled response expires, zero if it has no NextUpdate
+	expires   clock.Time
+	next      clock.Time
+	refresher *refresher
+	log       StandardLogger
+}
+
+// get returns the certificate along with the current staple. Once the staple is due for a
+// refresh, a new OCSP response is fetched in the background. An expired staple is removed.
+func (s *ocspStapler) get() *tls.Certificate {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	now := clock.Now()
+	if s.cert.OCSPStaple != nil && !s.expires.IsZero() && now.After(s.expires) {
+		s.log.Warn("stapled OCSP response has expired; no longer stapling", "next-update", s.expires)
+		s.staple(nil, clock.Time{})
+	}
+	if !now.Before(s.next) {
+		s.refresher.start(s.refresh)
+	}
+	return s.cert
+}
+
+func (s *ocspStapler) refresh(ctx context.Context) {
+	result, err := s.fetcher.FetchOCSP(ctx, s.leaf, s.issuer)
+	if err == nil {
+		err = checkOCSPResponse(result, s.leaf)
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	now := clock.Now()
+	if err != nil {
+		s.log.Warn("while fetching OCSP response; continuing to use the previous response", "err", err)
+		s.next = now.Add(min(ocspRetryInterval, s.interval))
+		return
+	}
+
+	s.staple(result.Staple, result.NextUpdate)
+	if result.NextUpdate.IsZero() {
+		s.next = now.Add(s.interval)
+		return
+	}
+	// Refresh half way through the validity of the response, leaving time to retry before it expires
+	s.next = result.ThisUpdate.Add(result.NextUpdate.Sub(result.ThisUpdate) / 2)
+	if s.next.Before(now.Add(ocspRetryInterval)) {
+		s.next = now.Add(ocspRetryInterval)
+	}
+}
+
+// staple replaces the certificate, as handshakes in flight may still be using the previous one
+func (s *ocspStapler) staple(staple []byte, expires clock.Time) {
+	cert := *s.cert
+	cert.OCSPStaple = staple
+	s.cert = &cert
+	s.expires = expires
+}
+
+// setupOCSP fetches the initial OCSP response and installs a GetCertificate callback which staples
+// the response to the server certificate, refreshing it before the response expires.
+func setupOCSP(conf *Config) error {
+	if conf.Reload || conf.AutoCert != nil {
+		return errors.New("OCSP stapling is not supported along with Reload or AutoCert")
+	}
+	if len(conf.ServerTLS.Certificates) == 0 {
+		return errors.New("OCSP stapling requires a server certificate")
+	}
+
+	cert := conf.ServerTLS.Certificates[0]
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		return fmt.Errorf("while parsing server certificate: %w", err)
+	}
+
+	issuer, err := findIssuer(leaf, cert.Certificate[1:], conf.CaPEM)
+	if err != nil {
+		return err
+	}
+
+	s := &ocspStapler{
+		refresher: conf.newRefresher(30 * clock.Second),
+		interval:  conf.OCSPRefreshInterval,
+		log:       conf.Logger,
+		fetcher:   conf.OCSPFetcher,
+		cert:      &cert,
+		issuer:    issuer,
+		leaf:      leaf,
+	}
+
+	// Fetch the initial response, failing to do so should not prevent the server from starting
+	s.refresher.run(s.refresh)
+
+	// GetCertificate is only consulted if Certificates is empty
+	conf.ServerTLS.Certificates = nil
+	conf.ServerTLS.GetCertificate = func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+		return s.get(), nil
+	}
+	return nil
+}
+
+// findIssuer finds the certificate which issued the leaf in the chain or the CA bundle
+func findIssuer(leaf *x509.Certificate, chain [][]byte, caPEM *bytes.Buffer) (*x509.Certificate, error) {
+	candidates := append([][]byte{}, chain...)
+	if caPEM != nil {
+		candidates = append(candidates, derOf(caPEM.Bytes())...)
+	}
+	for _, der := range candidates {
+		c, err := x509.ParseCertificate(der)
+		if err != nil {
+			continue
+		}
+		if leaf.CheckSignatureFrom(c) == nil {
+			return c, nil
+		}
+	}
+	return nil, errors.New("unable to find the issuer of the server certificate in the chain or CA")
+}
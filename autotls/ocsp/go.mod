@@ -0,0 +1,17 @@
+module github.com/kapetan-io/tackle/autotls/ocsp
+
+go 1.22.9
+
+replace github.com/kapetan-io/tackle => ../..
+
+require (
+	github.com/kapetan-io/tackle v0.15.0
+	github.com/stretchr/testify v1.9.0
+	golang.org/x/crypto v0.31.0
+)
+
+require (
+	github.com/davecgh/go-spew v1.1.1 // indirect
+	github.com/pmezard/go-difflib v1.0.0 // indirect
+	gopkg.in/yaml.v3 v3.0.1 // indirect
+)
@@ -0,0 +1,116 @@
+// Package ocsp fetches and verifies OCSP responses for stapling by autotls. It is a separate module
+// such that autotls, and the rest of tackle, do not depend upon golang.org/x/crypto.
+package ocsp
+
+import (
+	"bytes"
+	"context"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+
+	"github.com/kapetan-io/tackle/autotls"
+	xocsp "golang.org/x/crypto/ocsp"
+)
+
+const maxResponseSize = 1 << 20
+
+// HTTPFetcher is an autotls.OCSPFetcher which fetches OCSP responses from an OCSP responder via
+// HTTP POST as described in RFC 6960
+//
+//	result, err := autotls.New(autotls.Config{
+//		CertFile:    "server.pem",
+//		KeyFile:     "server.key",
+//		CaFile:      "ca.pem",
+//		OCSPFetcher: &ocsp.HTTPFetcher{},
+//	})
+type HTTPFetcher struct {
+	// (Optional) The URL of the OCSP responder. If empty, the first OCSP server listed in the leaf
+	// certificate is used.
+	URL string
+	// (Optional) The client used to make requests. Defaults to http.DefaultClient
+	Client *http.Client
+}
+
+func (f *HTTPFetcher) FetchOCSP(ctx context.Context, leaf, issuer *x509.Certificate) (*autotls.OCSPResponse, error) {
+	url := f.URL
+	if url == "" {
+		if len(leaf.OCSPServer) == 0 {
+			return nil, errors.New("no OCSP responder URL provided and the certificate lists no OCSP servers")
+		}
+		url = leaf.OCSPServer[0]
+	}
+
+	body, err := xocsp.CreateRequest(leaf, issuer, nil)
+	if err != nil {
+		return nil, fmt.Errorf("while creating OCSP request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("while creating OCSP request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/ocsp-request")
+	req.Header.Set("Accept", "application/ocsp-response")
+
+	client := f.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("while requesting OCSP response from '%s': %w", url, err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("OCSP responder '%s' returned HTTP status %d", url, resp.StatusCode)
+	}
+
+	b, err := io.ReadAll(io.LimitReader(resp.Body, maxResponseSize))
+	if err != nil {
+		return nil, fmt.Errorf("while reading OCSP response from '%s': %w", url, err)
+	}
+
+	r, err := Verify(b, leaf, issuer)
+	if err != nil {
+		return nil, fmt.Errorf("while verifying OCSP response from '%s': %w", url, err)
+	}
+	return r, nil
+}
+
+// Verify parses the DER encoded OCSP response for the leaf certificate and verifies it is signed by the
+// issuer, or by a responder certificate included in the response which the issuer signed for OCSP signing.
+// Useful when implementing an autotls.OCSPFetcher which fetches responses by other means.
+func Verify(b []byte, leaf, issuer *x509.Certificate) (*autotls.OCSPResponse, error) {
+	r, err := xocsp.ParseResponseForCert(b, leaf, issuer)
+	if err != nil {
+		return nil, fmt.Errorf("while parsing OCSP response: %w", err)
+	}
+
+	// ParseResponseForCert() verifies the responder certificate was signed by the issuer, but not
+	// that the issuer delegated OCSP signing to it as required by RFC 6960 section 4.2.2.2
+	if r.Certificate != nil && !r.Certificate.Equal(issuer) &&
+		!slices.Contains(r.Certificate.ExtKeyUsage, x509.ExtKeyUsageOCSPSigning) {
+		return nil, errors.New("OCSP responder certificate is not authorized for OCSP signing")
+	}
+
+	result := &autotls.OCSPResponse{
+		Staple:     b,
+		ThisUpdate: r.ThisUpdate,
+		NextUpdate: r.NextUpdate,
+	}
+	switch r.Status {
+	case xocsp.Good:
+		result.Status = autotls.OCSPGood
+	case xocsp.Revoked:
+		result.Status = autotls.OCSPRevoked
+	default:
+		result.Status = autotls.OCSPUnknown
+	}
+	return result, nil
+}
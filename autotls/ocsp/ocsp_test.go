@@ -0,0 +1,157 @@
+package ocsp_test
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/kapetan-io/tackle/autotls"
+	"github.com/kapetan-io/tackle/autotls/ocsp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	xocsp "golang.org/x/crypto/ocsp"
+)
+
+// responder serves OCSP responses created by the respond function for each request it receives
+func responder(t *testing.T, respond func(req *xocsp.Request) []byte) *httptest.Server {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "application/ocsp-request", r.Header.Get("Content-Type"))
+		b, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+		req, err := xocsp.ParseRequest(b)
+		require.NoError(t, err)
+		_, _ = w.Write(respond(req))
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// createResponse creates an OCSP response for the serial signed by the signer on behalf of the issuer
+func createResponse(t *testing.T, issuer, signer *autotls.KeyPair, serial *big.Int, status int) []byte {
+	now := time.Now()
+	b, err := xocsp.CreateResponse(issuer.Cert, signer.Cert, xocsp.Response{
+		Status:       status,
+		SerialNumber: serial,
+		ThisUpdate:   now.Add(-time.Minute),
+		NextUpdate:   now.Add(time.Hour),
+		RevokedAt:    now.Add(-time.Minute),
+		Certificate:  signer.Cert,
+	}, signer.Key)
+	require.NoError(t, err)
+	return b
+}
+
+// createResponder creates a certificate issued by the CA which the CA delegates OCSP signing to
+func createResponder(t *testing.T, ca *autotls.KeyPair, usage []x509.ExtKeyUsage) *autotls.KeyPair {
+	kp, err := autotls.GenerateCA(autotls.CAOptions{KeyAlgorithm: autotls.KeyAlgorithmECDSAP256})
+	require.NoError(t, err)
+	der, err := x509.CreateCertificate(rand.Reader, &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: "OCSP Responder"},
+		NotBefore:    time.Now().Add(-time.Minute),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  usage,
+	}, ca.Cert, kp.Key.Public(), ca.Key)
+	require.NoError(t, err)
+	kp.Cert, err = x509.ParseCertificate(der)
+	require.NoError(t, err)
+	return kp
+}
+
+func TestHTTPFetcher(t *testing.T) {
+	ca, err := autotls.GenerateCA(autotls.CAOptions{KeyAlgorithm: autotls.KeyAlgorithmECDSAP256})
+	require.NoError(t, err)
+	leaf, err := autotls.GenerateCert(ca, autotls.CertOptions{DisableDiscovery: true})
+	require.NoError(t, err)
+
+	var response []byte
+	srv := responder(t, func(req *xocsp.Request) []byte {
+		assert.Equal(t, leaf.Cert.SerialNumber, req.SerialNumber)
+		return response
+	})
+
+	f := &ocsp.HTTPFetcher{URL: srv.URL}
+	response = createResponse(t, ca, ca, leaf.Cert.SerialNumber, xocsp.Good)
+	r, err := f.FetchOCSP(context.Background(), leaf.Cert, ca.Cert)
+	require.NoError(t, err)
+	assert.Equal(t, response, r.Staple)
+	assert.Equal(t, autotls.OCSPGood, r.Status)
+	assert.False(t, r.NextUpdate.IsZero())
+
+	// The status is reported, autotls refuses to staple responses which are not good
+	response = createResponse(t, ca, ca, leaf.Cert.SerialNumber, xocsp.Revoked)
+	r, err = f.FetchOCSP(context.Background(), leaf.Cert, ca.Cert)
+	require.NoError(t, err)
+	assert.Equal(t, autotls.OCSPRevoked, r.Status)
+
+	other, err := autotls.GenerateCA(autotls.CAOptions{KeyAlgorithm: autotls.KeyAlgorithmECDSAP256})
+	require.NoError(t, err)
+	otherLeaf, err := autotls.GenerateCert(ca, autotls.CertOptions{DisableDiscovery: true})
+	require.NoError(t, err)
+
+	for _, tt := range []struct {
+		name     string
+		response []byte
+		err      string
+	}{
+		{name: "unsuccessful", response: xocsp.TryLaterErrorResponse, err: "error from server"},
+		{name: "malformed", response: []byte("garbage"), err: "while parsing OCSP response"},
+		{name: "mismatched", response: createResponse(t, ca, ca, otherLeaf.Cert.SerialNumber, xocsp.Good),
+			err: "no response matching"},
+		{name: "signed by other CA", response: createResponse(t, ca, other, leaf.Cert.SerialNumber, xocsp.Good),
+			err: "bad OCSP signature"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			response = tt.response
+			_, err := f.FetchOCSP(context.Background(), leaf.Cert, ca.Cert)
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.err)
+		})
+	}
+}
+
+func TestVerifyDelegatedResponder(t *testing.T) {
+	ca, err := autotls.GenerateCA(autotls.CAOptions{KeyAlgorithm: autotls.KeyAlgorithmECDSAP256})
+	require.NoError(t, err)
+	leaf, err := autotls.GenerateCert(ca, autotls.CertOptions{DisableDiscovery: true})
+	require.NoError(t, err)
+
+	delegate := createResponder(t, ca, []x509.ExtKeyUsage{x509.ExtKeyUsageOCSPSigning})
+	r, err := ocsp.Verify(createResponse(t, ca, delegate, leaf.Cert.SerialNumber, xocsp.Good), leaf.Cert, ca.Cert)
+	require.NoError(t, err)
+	assert.Equal(t, autotls.OCSPGood, r.Status)
+
+	// The issuer must delegate OCSP signing to the responder
+	unauthorized := createResponder(t, ca, []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth})
+	_, err = ocsp.Verify(createResponse(t, ca, unauthorized, leaf.Cert.SerialNumber, xocsp.Good), leaf.Cert, ca.Cert)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "not authorized for OCSP signing")
+}
+
+func TestStapling(t *testing.T) {
+	ca, err := autotls.NewCertAuthority(autotls.CAOptions{KeyAlgorithm: autotls.KeyAlgorithmECDSAP256})
+	require.NoError(t, err)
+	srv := responder(t, func(req *xocsp.Request) []byte {
+		return createResponse(t, ca.KeyPair(), ca.KeyPair(), req.SerialNumber, xocsp.Good)
+	})
+
+	r, err := autotls.New(autotls.Config{
+		AutoTLS:          true,
+		DisableDiscovery: true,
+		CertAuthority:    ca,
+		OCSPFetcher:      &ocsp.HTTPFetcher{URL: srv.URL},
+	})
+	require.NoError(t, err)
+	cert, err := r.ServerTLS.GetCertificate(&tls.ClientHelloInfo{})
+	require.NoError(t, err)
+	assert.NotEmpty(t, cert.OCSPStaple)
+}
@@ -0,0 +1,169 @@
+package autotls_test
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/kapetan-io/tackle/autotls"
+	"github.com/kapetan-io/tackle/clock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeOCSPFetcher struct {
+	mutex  sync.Mutex
+	status autotls.OCSPStatus
+	count  int
+}
+
+func (f *fakeOCSPFetcher) FetchOCSP(_ context.Context, leaf, _ *x509.Certificate) (*autotls.OCSPResponse, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.count++
+	now := clock.Now()
+	return &autotls.OCSPResponse{
+		// Clients do not parse the staple, so any unique value will do
+		Staple:     []byte(fmt.Sprintf("%s-%d", leaf.SerialNumber, f.count)),
+		Status:     f.status,
+		ThisUpdate: now,
+		NextUpdate: now.Add(2 * clock.Hour),
+	}, nil
+}
+
+func (f *fakeOCSPFetcher) Count() int {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	return f.count
+}
+
+func (f *fakeOCSPFetcher) SetStatus(status autotls.OCSPStatus) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.status = status
+}
+
+func TestSetupOCSPStapling(t *testing.T) {
+	defer clock.Freeze(clock.Now()).UnFreeze()
+
+	ca, err := autotls.NewCertAuthority(autotls.CAOptions{KeyAlgorithm: autotls.KeyAlgorithmECDSAP256})
+	require.NoError(t, err)
+	fetcher := &fakeOCSPFetcher{}
+	r, err := autotls.New(autotls.Config{
+		AutoTLS:          true,
+		DisableDiscovery: true,
+		CertAuthority:    ca,
+		OCSPFetcher:      fetcher,
+	})
+	require.NoError(t, err)
+	require.Equal(t, 1, fetcher.Count())
+
+	ln, err := tls.Listen("tcp", "localhost:0", r.ServerTLS)
+	require.NoError(t, err)
+	defer func() { _ = ln.Close() }()
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			_ = conn.(*tls.Conn).Handshake()
+			_ = conn.Close()
+		}
+	}()
+
+	staple := func() []byte {
+		client := r.ClientTLS.Clone()
+		client.ServerName = "localhost"
+		conn, err := tls.Dial("tcp", ln.Addr().String(), client)
+		require.NoError(t, err)
+		defer func() { _ = conn.Close() }()
+		return conn.ConnectionState().OCSPResponse
+	}
+
+	first := staple()
+	require.NotEmpty(t, first)
+
+	// Should not refresh before half way to the NextUpdate of the response
+	clock.Advance(30 * clock.Minute)
+	assert.Equal(t, first, staple())
+	assert.Equal(t, 1, fetcher.Count())
+
+	clock.Advance(30 * clock.Minute)
+	_ = staple()
+	require.Eventually(t, func() bool { return fetcher.Count() == 2 }, clock.Second, clock.Millisecond)
+	second := staple()
+	require.NotEmpty(t, second)
+	assert.NotEqual(t, first, second)
+
+	// A response reporting the certificate as revoked should never be stapled
+	fetcher.SetStatus(autotls.OCSPRevoked)
+	clock.Advance(clock.Hour)
+	_ = staple()
+	require.Eventually(t, func() bool { return fetcher.Count() == 3 }, clock.Second, clock.Millisecond)
+	assert.Equal(t, second, staple())
+
+	// Once the NextUpdate of the stapled response has passed, it is no longer stapled
+	clock.Advance(clock.Hour + clock.Minute)
+	assert.Empty(t, staple())
+}
+
+type staticOCSPFetcher struct {
+	resp *autotls.OCSPResponse
+	err  error
+}
+
+func (f *staticOCSPFetcher) FetchOCSP(context.Context, *x509.Certificate, *x509.Certificate) (*autotls.OCSPResponse, error) {
+	return f.resp, f.err
+}
+
+func TestOCSPRejected(t *testing.T) {
+	defer clock.Freeze(clock.Now()).UnFreeze()
+	now := clock.Now()
+
+	for _, tt := range []struct {
+		name  string
+		fetch staticOCSPFetcher
+	}{
+		{name: "fetch error", fetch: staticOCSPFetcher{err: errors.New("responder unavailable")}},
+		{name: "empty", fetch: staticOCSPFetcher{resp: &autotls.OCSPResponse{ThisUpdate: now}}},
+		{name: "revoked", fetch: staticOCSPFetcher{resp: &autotls.OCSPResponse{
+			Staple: []byte("staple"), Status: autotls.OCSPRevoked, ThisUpdate: now}}},
+		{name: "unknown", fetch: staticOCSPFetcher{resp: &autotls.OCSPResponse{
+			Staple: []byte("staple"), Status: autotls.OCSPUnknown, ThisUpdate: now}}},
+		{name: "not yet valid", fetch: staticOCSPFetcher{resp: &autotls.OCSPResponse{
+			Staple: []byte("staple"), ThisUpdate: now.Add(clock.Hour)}}},
+		{name: "expired", fetch: staticOCSPFetcher{resp: &autotls.OCSPResponse{
+			Staple: []byte("staple"), ThisUpdate: now.Add(-2 * clock.Hour), NextUpdate: now.Add(-clock.Hour)}}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			// Failing to fetch a response should not prevent the server from starting
+			r, err := autotls.New(autotls.Config{
+				AutoTLS:          true,
+				DisableDiscovery: true,
+				OCSPFetcher:      &tt.fetch,
+			})
+			require.NoError(t, err)
+			cert, err := r.ServerTLS.GetCertificate(&tls.ClientHelloInfo{})
+			require.NoError(t, err)
+			assert.Empty(t, cert.OCSPStaple)
+		})
+	}
+
+	ca, err := autotls.GenerateCA(autotls.CAOptions{})
+	require.NoError(t, err)
+	leaf, err := autotls.GenerateCert(ca, autotls.CertOptions{DisableDiscovery: true})
+	require.NoError(t, err)
+	_, err = autotls.New(autotls.Config{
+		OCSPFetcher: &fakeOCSPFetcher{},
+		Reload:      true,
+		CertPEM:     bytes.NewBuffer(leaf.CertPEM),
+		KeyPEM:      bytes.NewBuffer(leaf.KeyPEM),
+	})
+	require.Error(t, err)
+}
@@ -0,0 +1,71 @@
+package autotls
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"crypto/x509"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Fingerprint returns the hex encoded SHA-256 fingerprint of the certificate's public key (SPKI),
+// in the form accepted by Config.PinnedFingerprints. As the fingerprint is of the public key, it
+// remains the same when a certificate is re-issued using the same private key.
+func Fingerprint(cert *x509.Certificate) string {
+	sum := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+	return hex.EncodeToString(sum[:])
+}
+
+// parseFingerprint decodes a hex encoded SHA-256 fingerprint, which may be separated by colons
+func parseFingerprint(s string) ([]byte, error) {
+	b, err := hex.DecodeString(strings.ReplaceAll(s, ":", ""))
+	if err != nil || len(b) != sha256.Size {
+		return nil, fmt.Errorf("invalid fingerprint '%s' in PinnedFingerprints; expected a hex encoded "+
+			"SHA-256 hash", s)
+	}
+	return b, nil
+}
+
+// verifyPinned returns a VerifyPeerCertificate callback which rejects the peer unless the public
+// key of the leaf certificate matches one of the pins. Only the leaf is checked, as the rest of
+// the chain presented by the peer is not verified when pinning.
+func verifyPinned(pins [][]byte) func([][]byte, [][]*x509.Certificate) error {
+	return func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+		if len(rawCerts) == 0 {
+			return errors.New("peer did not present a certificate")
+		}
+		leaf, err := x509.ParseCertificate(rawCerts[0])
+		if err != nil {
+			return fmt.Errorf("while parsing peer certificate: %w", err)
+		}
+		sum := sha256.Sum256(leaf.RawSubjectPublicKeyInfo)
+		for _, pin := range pins {
+			if subtle.ConstantTimeCompare(sum[:], pin) == 1 {
+				return nil
+			}
+		}
+		return fmt.Errorf("peer certificate fingerprint '%s' does not match any of the pinned fingerprints",
+			hex.EncodeToString(sum[:]))
+	}
+}
+
+// setupPinning installs the pins on ClientTLS. The pins take the place of verifying the server
+// certificate against the CA, such that self-signed certificates are accepted only if pinned.
+func setupPinning(conf *Config) error {
+	var pins [][]byte
+	for _, s := range conf.PinnedFingerprints {
+		pin, err := parseFingerprint(s)
+		if err != nil {
+			return err
+		}
+		pins = append(pins, pin)
+	}
+
+	// The standard verification is skipped, as it would reject self-signed certificates before
+	// VerifyPeerCertificate is called.
+	conf.ClientTLS.InsecureSkipVerify = true
+	addVerifyPeer(conf.ClientTLS, verifyPinned(pins))
+	return nil
+}
@@ -0,0 +1,54 @@
+package autotls_test
+
+import (
+	"crypto/tls"
+	"strings"
+	"testing"
+
+	"github.com/kapetan-io/tackle/autotls"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSetupPinnedFingerprints(t *testing.T) {
+	server, err := autotls.New(autotls.Config{AutoTLS: true, DisableDiscovery: true})
+	require.NoError(t, err)
+	ln := serveTLS(t, server.ServerTLS)
+
+	kp, err := autotls.ParseKeyPair(server.CertPEM, server.KeyPEM)
+	require.NoError(t, err)
+	pin := autotls.Fingerprint(kp.Cert)
+	assert.Len(t, pin, 64)
+
+	other, err := autotls.New(autotls.Config{AutoTLS: true, DisableDiscovery: true})
+	require.NoError(t, err)
+	otherKP, err := autotls.ParseKeyPair(other.CertPEM, other.KeyPEM)
+	require.NoError(t, err)
+
+	dial := func(pins ...string) error {
+		// The client does not trust the CA which signed the server certificate
+		client, err := autotls.New(autotls.Config{PinnedFingerprints: pins})
+		require.NoError(t, err)
+		conn, err := tls.Dial("tcp", ln.Addr().String(), client.ClientTLS)
+		if err != nil {
+			return err
+		}
+		return conn.Close()
+	}
+
+	require.NoError(t, dial(pin))
+	require.NoError(t, dial(autotls.Fingerprint(otherKP.Cert), pin))
+
+	// Colon separated, upper case fingerprints as printed by openssl are accepted
+	var pairs []string
+	for i := 0; i < len(pin); i += 2 {
+		pairs = append(pairs, strings.ToUpper(pin[i:i+2]))
+	}
+	require.NoError(t, dial(strings.Join(pairs, ":")))
+
+	err = dial(autotls.Fingerprint(otherKP.Cert))
+	require.ErrorContains(t, err, "does not match any of the pinned fingerprints")
+
+	_, err = autotls.New(autotls.Config{PinnedFingerprints: []string{"not-a-fingerprint"}})
+	require.ErrorContains(t, err, "invalid fingerprint 'not-a-fingerprint' in PinnedFingerprints")
+}
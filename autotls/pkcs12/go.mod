@@ -0,0 +1,18 @@
+module github.com/kapetan-io/tackle/autotls/pkcs12
+
+go 1.22.9
+
+replace github.com/kapetan-io/tackle => ../..
+
+require (
+	github.com/kapetan-io/tackle v0.15.0
+	github.com/stretchr/testify v1.9.0
+	software.sslmate.com/src/go-pkcs12 v0.7.3
+)
+
+require (
+	github.com/davecgh/go-spew v1.1.1 // indirect
+	github.com/pmezard/go-difflib v1.0.0 // indirect
+	golang.org/x/crypto v0.11.0 // indirect
+	gopkg.in/yaml.v3 v3.0.1 // indirect
+)
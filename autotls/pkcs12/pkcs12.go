@@ -0,0 +1,83 @@
+// Package pkcs12 loads PKCS #12 (.p12/.pfx) bundles into an autotls.Config. It is a separate
+// module such that autotls, and the rest of tackle, do not depend upon a PKCS #12 decoder.
+package pkcs12
+
+import (
+	"bytes"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"os"
+
+	"github.com/kapetan-io/tackle/autotls"
+	gopkcs12 "software.sslmate.com/src/go-pkcs12"
+)
+
+// LoadFile reads the PKCS #12 bundle from the file provided and loads it into the config via Load()
+func LoadFile(conf *autotls.Config, name, password string) error {
+	b, err := os.ReadFile(name)
+	if err != nil {
+		return fmt.Errorf("while reading file '%s': %w", name, err)
+	}
+	if err := Load(conf, b, password); err != nil {
+		return fmt.Errorf("while loading PKCS #12 bundle '%s': %w", name, err)
+	}
+	return nil
+}
+
+// Load decodes the PKCS #12 bundle containing the server certificate, its private key and optionally
+// the CA chain, and sets CertPEM and KeyPEM on the config. If no CA is provided via CaFile or CaPEM,
+// the other certificates in the bundle are trusted as the CA.
+//
+//	conf := autotls.Config{}
+//	if err := pkcs12.LoadFile(&conf, "server.p12", password); err != nil {
+//		return err
+//	}
+//	result, err := autotls.New(conf)
+func Load(conf *autotls.Config, bundle []byte, password string) error {
+	key, leaf, cas, err := gopkcs12.DecodeChain(bundle, password)
+	if err != nil {
+		return fmt.Errorf("while decoding PKCS #12: %w", err)
+	}
+
+	keyDER, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return fmt.Errorf("while marshalling private key: %w", err)
+	}
+	keyPEM := new(bytes.Buffer)
+	if err := pem.Encode(keyPEM, &pem.Block{Type: "PRIVATE KEY", Bytes: keyDER}); err != nil {
+		return fmt.Errorf("while encoding private key into PEM: %w", err)
+	}
+
+	// Self-signed roots are not presented along with the certificate, as clients must already trust them
+	certs := []*x509.Certificate{leaf}
+	for _, c := range cas {
+		if bytes.Equal(c.RawIssuer, c.RawSubject) && c.CheckSignatureFrom(c) == nil {
+			continue
+		}
+		certs = append(certs, c)
+	}
+	certPEM, err := encodeCerts(certs)
+	if err != nil {
+		return err
+	}
+
+	if conf.CaFile == "" && conf.CaPEM == nil && len(cas) != 0 {
+		if conf.CaPEM, err = encodeCerts(cas); err != nil {
+			return err
+		}
+	}
+	conf.CertPEM = certPEM
+	conf.KeyPEM = keyPEM
+	return nil
+}
+
+func encodeCerts(certs []*x509.Certificate) (*bytes.Buffer, error) {
+	buf := new(bytes.Buffer)
+	for _, c := range certs {
+		if err := pem.Encode(buf, &pem.Block{Type: "CERTIFICATE", Bytes: c.Raw}); err != nil {
+			return nil, fmt.Errorf("while encoding CERTIFICATE PEM: %w", err)
+		}
+	}
+	return buf, nil
+}
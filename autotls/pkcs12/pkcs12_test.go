@@ -0,0 +1,81 @@
+package pkcs12_test
+
+import (
+	"bytes"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/kapetan-io/tackle/autotls"
+	"github.com/kapetan-io/tackle/autotls/pkcs12"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	gopkcs12 "software.sslmate.com/src/go-pkcs12"
+)
+
+func TestLoadFile(t *testing.T) {
+	b, err := os.ReadFile("../certs/auto.pem")
+	require.NoError(t, err)
+	leaf, _ := pem.Decode(b)
+	require.NotNil(t, leaf)
+
+	ca, err := os.ReadFile("../certs/ca.cert")
+	require.NoError(t, err)
+
+	for _, name := range []string{
+		// OpenSSL 3 defaults to PBES2 with AES-256-CBC, PBKDF2 and a SHA-256 MAC
+		"../certs/auto.p12",
+		// pbeWithSHA1And3-KeyTripleDES-CBC with a SHA-1 MAC
+		"../certs/auto_legacy.p12",
+	} {
+		t.Run(name, func(t *testing.T) {
+			var conf autotls.Config
+			require.NoError(t, pkcs12.LoadFile(&conf, name, "test"))
+
+			r, err := autotls.New(conf)
+			require.NoError(t, err)
+			require.Len(t, r.ServerTLS.Certificates, 1)
+			assert.Equal(t, leaf.Bytes, r.ServerTLS.Certificates[0].Certificate[0])
+			// The CA in the bundle is a self-signed root, and is not included in the chain
+			assert.Len(t, r.ServerTLS.Certificates[0].Certificate, 1)
+			assert.Equal(t, ca, r.CaPEM)
+
+			err = pkcs12.LoadFile(&autotls.Config{}, name, "wrong")
+			assert.ErrorIs(t, err, gopkcs12.ErrIncorrectPassword)
+		})
+	}
+}
+
+func TestLoadProvidedCA(t *testing.T) {
+	b, err := os.ReadFile("../certs/auto.p12")
+	require.NoError(t, err)
+
+	// The CA in the bundle is not used when a CA is already provided
+	conf := autotls.Config{CaPEM: bytes.NewBufferString("provided")}
+	require.NoError(t, pkcs12.Load(&conf, b, "test"))
+	assert.Equal(t, "provided", conf.CaPEM.String())
+}
+
+func TestLoadMalformed(t *testing.T) {
+	b, err := os.ReadFile("../certs/auto.p12")
+	require.NoError(t, err)
+
+	for _, tt := range []struct {
+		name   string
+		bundle []byte
+	}{
+		{name: "empty", bundle: []byte{}},
+		{name: "truncated", bundle: b[:len(b)/2]},
+		{name: "trailing data", bundle: append(bytes.Clone(b), 0)},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			name := filepath.Join(t.TempDir(), "bundle.p12")
+			require.NoError(t, os.WriteFile(name, tt.bundle, 0600))
+
+			err := pkcs12.LoadFile(&autotls.Config{}, name, "test")
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), "while decoding PKCS #12")
+		})
+	}
+}
@@ -0,0 +1,59 @@
+package autotls
+
+import (
+	"crypto/tls"
+	"fmt"
+)
+
+// TLSProfile selects the minimum TLS version and cipher suites used by ServerTLS. The profiles
+// follow the Mozilla Server Side TLS recommendations.
+type TLSProfile string
+
+const (
+	// TLSProfileModern supports only TLS 1.3, whose cipher suites are not configurable
+	TLSProfileModern TLSProfile = "modern"
+
+	// TLSProfileIntermediate supports TLS 1.2 and above with forward secret AEAD cipher suites
+	TLSProfileIntermediate TLSProfile = "intermediate"
+
+	// TLSProfileLegacy supports TLS 1.0 and above, including the CBC and RSA key exchange suites.
+	// Only use this profile for clients which can not be upgraded.
+	TLSProfileLegacy TLSProfile = "legacy"
+)
+
+var intermediateCipherSuites = []uint16{
+	tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256,
+	tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,
+	tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384,
+	tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384,
+	tls.TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305,
+	tls.TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305,
+}
+
+var legacyCipherSuites = append(append([]uint16{}, intermediateCipherSuites...),
+	tls.TLS_ECDHE_ECDSA_WITH_AES_128_CBC_SHA256,
+	tls.TLS_ECDHE_RSA_WITH_AES_128_CBC_SHA256,
+	tls.TLS_ECDHE_ECDSA_WITH_AES_128_CBC_SHA,
+	tls.TLS_ECDHE_RSA_WITH_AES_128_CBC_SHA,
+	tls.TLS_ECDHE_ECDSA_WITH_AES_256_CBC_SHA,
+	tls.TLS_ECDHE_RSA_WITH_AES_256_CBC_SHA,
+	tls.TLS_RSA_WITH_AES_128_GCM_SHA256,
+	tls.TLS_RSA_WITH_AES_256_GCM_SHA384,
+	tls.TLS_RSA_WITH_AES_128_CBC_SHA256,
+	tls.TLS_RSA_WITH_AES_128_CBC_SHA,
+	tls.TLS_RSA_WITH_AES_256_CBC_SHA,
+)
+
+// profileOf returns the minimum TLS version and cipher suites of the profile. The cipher suites
+// of the modern profile only apply if MinVersion lowers the minimum version below TLS 1.3
+func profileOf(p TLSProfile) (uint16, []uint16, error) {
+	switch p {
+	case TLSProfileModern, "":
+		return tls.VersionTLS13, intermediateCipherSuites, nil
+	case TLSProfileIntermediate:
+		return tls.VersionTLS12, intermediateCipherSuites, nil
+	case TLSProfileLegacy:
+		return tls.VersionTLS10, legacyCipherSuites, nil
+	}
+	return 0, nil, fmt.Errorf("unknown TLS profile '%s'", p)
+}
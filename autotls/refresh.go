@@ -0,0 +1,90 @@
+package autotls
+
+import (
+	"context"
+	"sync"
+
+	"github.com/kapetan-io/tackle/clock"
+	"github.com/kapetan-io/tackle/wait"
+)
+
+// refresher runs refreshes of certificates, OCSP responses and session ticket keys in the
+// background, such that TLS handshakes never wait on a refresh. At most one refresh runs at a
+// time, and each is cancelled once the timeout elapses or stop() is called.
+type refresher struct {
+	mutex   sync.Mutex
+	wg      wait.Group
+	ctx     context.Context
+	cancel  context.CancelFunc
+	timeout clock.Duration
+	running bool
+}
+
+// newRefresher returns a refresher which is stopped by Close(). The timeout applies if
+// RefreshTimeout is unset.
+func (c *Config) newRefresher(timeout clock.Duration) *refresher {
+	if c.RefreshTimeout != 0 {
+		timeout = c.RefreshTimeout
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	r := &refresher{ctx: ctx, cancel: cancel, timeout: timeout}
+	c.refreshers = append(c.refreshers, r)
+	return r
+}
+
+// start runs the refresh in the background, unless a refresh is already running or stop() was called
+func (r *refresher) start(refresh func(ctx context.Context)) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if r.running || r.ctx.Err() != nil {
+		return
+	}
+	r.running = true
+	r.wg.Go(func() {
+		r.run(refresh)
+		r.mutex.Lock()
+		r.running = false
+		r.mutex.Unlock()
+	})
+}
+
+// run runs the refresh in the calling goroutine
+func (r *refresher) run(refresh func(ctx context.Context)) {
+	ctx, cancel := context.WithTimeout(r.ctx, r.timeout)
+	defer cancel()
+	refresh(ctx)
+}
+
+// stop cancels any refresh in progress, waits for it to return and prevents any further refreshes
+func (r *refresher) stop() {
+	r.mutex.Lock()
+	r.cancel()
+	r.mutex.Unlock()
+	_ = r.wg.Wait()
+}
+
+// stopRefreshers stops all the refreshers, returning early with the context error if the
+// refreshes have not returned before the context is cancelled.
+func stopRefreshers(ctx context.Context, refreshers []*refresher) error {
+	done := make(chan struct{})
+	go func() {
+		for _, r := range refreshers {
+			r.stop()
+		}
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Close stops the background refreshes started by Setup() and waits for any refresh in progress to
+// return. Handshakes continue to use the most recently refreshed certificates, OCSP response and
+// session ticket keys.
+func (c *Config) Close(ctx context.Context) error {
+	return stopRefreshers(ctx, c.refreshers)
+}
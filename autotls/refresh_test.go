@@ -0,0 +1,111 @@
+package autotls_test
+
+import (
+	"context"
+	"crypto/tls"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/kapetan-io/tackle/autotls"
+	"github.com/kapetan-io/tackle/clock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// blockingTicketSource provides a key on the first fetch, then blocks every
+// fetch after that until the context is cancelled
+type blockingTicketSource struct {
+	mutex sync.Mutex
+	key   [32]byte
+	count int
+	errs  chan error
+}
+
+func newBlockingTicketSource(t *testing.T) *blockingTicketSource {
+	key, err := autotls.NewTicketKey()
+	require.NoError(t, err)
+	return &blockingTicketSource{key: key, errs: make(chan error, 10)}
+}
+
+func (s *blockingTicketSource) TicketKeys(ctx context.Context) ([][32]byte, error) {
+	s.mutex.Lock()
+	s.count++
+	first := s.count == 1
+	s.mutex.Unlock()
+	if first {
+		return [][32]byte{s.key}, nil
+	}
+	<-ctx.Done()
+	s.errs <- ctx.Err()
+	return nil, ctx.Err()
+}
+
+func (s *blockingTicketSource) Count() int {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.count
+}
+
+func TestClose(t *testing.T) {
+	defer clock.Freeze(clock.Now()).UnFreeze()
+
+	source := newBlockingTicketSource(t)
+	r, err := autotls.New(autotls.Config{
+		AutoTLS:          true,
+		DisableDiscovery: true,
+		TicketKeySource:  source,
+	})
+	require.NoError(t, err)
+	ln := serveTLS(t, r.ServerTLS)
+	// Keys are checked for rotation when the server issues a ticket, which requires a session cache
+	client := r.ClientTLS.Clone()
+	client.ClientSessionCache = tls.NewLRUClientSessionCache(1)
+
+	clock.Advance(clock.Hour)
+	_ = resumed(t, ln.Addr(), client)
+	require.Eventually(t, func() bool { return source.Count() == 2 }, clock.Second*5, clock.Millisecond*10)
+
+	// Close should cancel the refresh in progress and wait for it to return
+	ctx, cancel := context.WithTimeout(context.Background(), clock.Second*5)
+	defer cancel()
+	require.NoError(t, r.Close(ctx))
+	select {
+	case err := <-source.errs:
+		assert.ErrorIs(t, err, context.Canceled)
+	default:
+		t.Fatal("Close() returned before the refresh in progress")
+	}
+
+	// Handshakes continue to use the current keys, but no longer start a refresh
+	clock.Advance(clock.Hour)
+	_ = resumed(t, ln.Addr(), client)
+	assert.Never(t, func() bool { return source.Count() != 2 }, clock.Millisecond*100, clock.Millisecond*10)
+}
+
+func TestRefreshTimeout(t *testing.T) {
+	defer clock.Freeze(clock.Now()).UnFreeze()
+
+	source := newBlockingTicketSource(t)
+	r, err := autotls.New(autotls.Config{
+		AutoTLS:          true,
+		DisableDiscovery: true,
+		TicketKeySource:  source,
+		RefreshTimeout:   clock.Millisecond * 50,
+	})
+	require.NoError(t, err)
+	defer func() { _ = r.Close(context.Background()) }()
+	ln := serveTLS(t, r.ServerTLS)
+	// Keys are checked for rotation when the server issues a ticket, which requires a session cache
+	client := r.ClientTLS.Clone()
+	client.ClientSessionCache = tls.NewLRUClientSessionCache(1)
+
+	clock.Advance(clock.Hour)
+	_ = resumed(t, ln.Addr(), client)
+	select {
+	case err := <-source.errs:
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+	case <-time.After(time.Second * 5):
+		t.Fatal("refresh was not cancelled once RefreshTimeout elapsed")
+	}
+}
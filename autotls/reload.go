@@ -0,0 +1,248 @@
+package autotls
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+
+	"github.com/kapetan-io/tackle/clock"
+	"github.com/kapetan-io/tackle/metrics"
+)
+
+// fileWatcher reports if any of the watched files have changed. Files are checked at most once
+// per interval, such that it is cheap enough to call during every TLS handshake.
+type fileWatcher struct {
+	mutex    sync.Mutex
+	files    []string
+	interval clock.Duration
+	checked  clock.Time
+	stats    []fileStat
+}
+
+type fileStat struct {
+	modTime clock.Time
+	size    int64
+}
+
+func newFileWatcher(interval clock.Duration, files ...string) *fileWatcher {
+	w := &fileWatcher{
+		files:    files,
+		interval: interval,
+		checked:  clock.Now(),
+	}
+	w.stats = w.stat()
+	return w
+}
+
+func (w *fileWatcher) stat() []fileStat {
+	results := make([]fileStat, len(w.files))
+	for i, name := range w.files {
+		fi, err := os.Stat(name)
+		if err != nil {
+			continue
+		}
+		results[i] = fileStat{modTime: fi.ModTime(), size: fi.Size()}
+	}
+	return results
+}
+
+// changed returns true and the current stats if any of the files have changed since the stats were last
+// recorded by loaded(). Until loaded() is called, such as when the reload fails, changed() continues to
+// return true once per interval such that the reload is retried.
+func (w *fileWatcher) changed() ([]fileStat, bool) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	if clock.Since(w.checked) < w.interval {
+		return nil, false
+	}
+	w.checked = clock.Now()
+
+	stats := w.stat()
+	for i := range stats {
+		if !stats[i].modTime.Equal(w.stats[i].modTime) || stats[i].size != w.stats[i].size {
+			return stats, true
+		}
+	}
+	return nil, false
+}
+
+// loaded records the stats returned by changed() once the files have been successfully reloaded
+func (w *fileWatcher) loaded(stats []fileStat) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	w.stats = stats
+}
+
+// certProvider provides the most recent version of a certificate
+type certProvider interface {
+	get() *tls.Certificate
+}
+
+// keyPairReloader provides the most recent version of a certificate and key pair on disk
+type keyPairReloader struct {
+	mutex    sync.RWMutex
+	certFile string
+	keyFile  string
+	watcher  *fileWatcher
+	cert     *tls.Certificate
+	log      StandardLogger
+	metrics  metrics.Registry
+}
+
+func newKeyPairReloader(conf *Config, certFile, keyFile string) (*keyPairReloader, error) {
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("while loading certificate '%s' and key '%s': %w", certFile, keyFile, err)
+	}
+	return &keyPairReloader{
+		watcher:  newFileWatcher(conf.ReloadInterval, certFile, keyFile),
+		certFile: certFile,
+		keyFile:  keyFile,
+		log:      conf.Logger,
+		metrics:  conf.Metrics,
+		cert:     &cert,
+	}, nil
+}
+
+// get returns the current certificate, reloading it from disk if the files changed. If the reload
+// fails, the previous certificate continues to be used.
+func (r *keyPairReloader) get() *tls.Certificate {
+	if stats, ok := r.watcher.changed(); ok {
+		cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+		if err != nil {
+			r.log.Warn("while reloading certificate; continuing to use the previous certificate",
+				"cert-file", r.certFile, "key-file", r.keyFile, "err", err)
+		} else {
+			r.log.Info("Reloaded certificate", "cert-file", r.certFile, "key-file", r.keyFile)
+			r.mutex.Lock()
+			r.cert = &cert
+			r.mutex.Unlock()
+			r.watcher.loaded(stats)
+			recordExpiry(r.metrics, cert)
+		}
+	}
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	return r.cert
+}
+
+// poolReloader provides the most recent version of a CA certificate pool on disk
+type poolReloader struct {
+	mutex   sync.RWMutex
+	caFile  string
+	watcher *fileWatcher
+	pool    *x509.CertPool
+	log     StandardLogger
+}
+
+func newPoolReloader(conf *Config, caFile string, pool *x509.CertPool) *poolReloader {
+	return &poolReloader{
+		watcher: newFileWatcher(conf.ReloadInterval, caFile),
+		log:     conf.Logger,
+		caFile:  caFile,
+		pool:    pool,
+	}
+}
+
+// get returns the current pool and true if the pool was reloaded since the last call
+func (r *poolReloader) get() (*x509.CertPool, bool) {
+	var reloaded bool
+	if stats, ok := r.watcher.changed(); ok {
+		pool, err := loadPool(r.caFile)
+		if err != nil {
+			r.log.Warn("while reloading CA; continuing to use the previous CA", "ca-file", r.caFile, "err", err)
+		} else {
+			r.log.Info("Reloaded CA", "ca-file", r.caFile)
+			r.mutex.Lock()
+			r.pool = pool
+			r.mutex.Unlock()
+			r.watcher.loaded(stats)
+			reloaded = true
+		}
+	}
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	return r.pool, reloaded
+}
+
+func loadPool(caFile string) (*x509.CertPool, error) {
+	b, err := os.ReadFile(caFile)
+	if err != nil {
+		return nil, err
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(b) {
+		return nil, errors.New("no certificates found in PEM")
+	}
+	return pool, nil
+}
+
+// setupReload installs GetCertificate and GetClientCertificate callbacks which provide certificates
+// that are reloaded from disk when the files change, or from the CertSource if provided.
+func setupReload(conf *Config) error {
+	var server certProvider
+	var err error
+	if conf.CertSource != nil {
+		if server, err = newSourceReloader(conf); err != nil {
+			return err
+		}
+	} else if conf.CertFile != "" && conf.KeyFile != "" {
+		if server, err = newKeyPairReloader(conf, conf.CertFile, conf.KeyFile); err != nil {
+			return err
+		}
+	}
+
+	if server != nil {
+		// GetCertificate is only consulted if Certificates is empty
+		conf.ServerTLS.Certificates = nil
+		conf.ServerTLS.GetCertificate = func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+			return server.get(), nil
+		}
+
+		client := server
+		if conf.ClientAuthCertFile != "" && conf.ClientAuthKeyFile != "" {
+			if client, err = newKeyPairReloader(conf, conf.ClientAuthCertFile, conf.ClientAuthKeyFile); err != nil {
+				return err
+			}
+		}
+		conf.ClientTLS.Certificates = nil
+		conf.ClientTLS.GetClientCertificate = func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+			return client.get(), nil
+		}
+	}
+	return nil
+}
+
+// setupReloadCA installs a GetConfigForClient callback which provides the client auth CA reloaded
+// from disk when the file changes. It must be called once ServerTLS is otherwise complete, such
+// that configs cloned from ServerTLS include the GetCertificate and NextProtos of HostCerts and
+// AutoCert.
+func setupReloadCA(conf *Config) {
+	caFile := conf.ClientAuthCaFile
+	if caFile == "" {
+		caFile = conf.CaFile
+	}
+	if conf.ClientAuth == tls.NoClientCert || caFile == "" {
+		return
+	}
+
+	cas := newPoolReloader(conf, caFile, conf.ServerTLS.ClientCAs)
+	var mutex sync.Mutex
+	var current *tls.Config
+	base := conf.ServerTLS.Clone()
+	conf.ServerTLS.GetConfigForClient = func(*tls.ClientHelloInfo) (*tls.Config, error) {
+		pool, reloaded := cas.get()
+		mutex.Lock()
+		defer mutex.Unlock()
+		if reloaded {
+			current = base.Clone()
+			current.ClientCAs = pool
+		}
+		// A nil config means ServerTLS is used as is, which is the case until the CA changes
+		return current, nil
+	}
+}
@@ -0,0 +1,187 @@
+package autotls_test
+
+import (
+	"bytes"
+	"crypto/tls"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/kapetan-io/tackle/autotls"
+	"github.com/kapetan-io/tackle/clock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSetupReload(t *testing.T) {
+	defer clock.Freeze(clock.Now()).UnFreeze()
+	dir := t.TempDir()
+	certFile := filepath.Join(dir, "server.pem")
+	keyFile := filepath.Join(dir, "server.key")
+
+	// Given a certificate on disk
+	writeCert(t, "First Org", certFile, keyFile, time.Now())
+
+	conf := autotls.Config{
+		CaFile:         "certs/ca.cert",
+		CertFile:       certFile,
+		KeyFile:        keyFile,
+		Reload:         true,
+		ReloadInterval: clock.Minute,
+	}
+	require.NoError(t, autotls.Setup(&conf))
+
+	ln, err := tls.Listen("tcp", "localhost:0", conf.ServerTLS)
+	require.NoError(t, err)
+	defer func() { _ = ln.Close() }()
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			_ = conn.(*tls.Conn).Handshake()
+			_ = conn.Close()
+		}
+	}()
+
+	assert.Equal(t, "First Org", peerOrg(t, ln.Addr(), conf.ClientTLS))
+
+	// When the certificate is rotated on disk
+	writeCert(t, "Second Org", certFile, keyFile, time.Now().Add(time.Hour))
+
+	// Should continue to use the old certificate until the reload interval has elapsed
+	assert.Equal(t, "First Org", peerOrg(t, ln.Addr(), conf.ClientTLS))
+
+	clock.Advance(clock.Minute)
+	assert.Equal(t, "Second Org", peerOrg(t, ln.Addr(), conf.ClientTLS))
+
+	// When the rotated certificate is invalid
+	require.NoError(t, os.WriteFile(certFile, []byte("invalid"), 0600))
+	clock.Advance(clock.Minute)
+
+	// Should continue to use the previous certificate
+	assert.Equal(t, "Second Org", peerOrg(t, ln.Addr(), conf.ClientTLS))
+}
+
+func TestSetupReloadHostCerts(t *testing.T) {
+	defer clock.Freeze(clock.Now()).UnFreeze()
+	dir := t.TempDir()
+	certFile := filepath.Join(dir, "server.pem")
+	keyFile := filepath.Join(dir, "server.key")
+	caFile := filepath.Join(dir, "client-ca.pem")
+	writeCert(t, "Default Org", certFile, keyFile, time.Now())
+	caPEM, err := os.ReadFile("certs/ca.cert")
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(caFile, caPEM, 0600))
+
+	caKeyPEM, err := os.ReadFile("certs/ca.key")
+	require.NoError(t, err)
+	ca, err := autotls.ParseKeyPair(caPEM, caKeyPEM)
+	require.NoError(t, err)
+	host, err := autotls.GenerateCert(ca, autotls.CertOptions{
+		OrgName:          "Host A",
+		DNSNames:         []string{"a.example.com"},
+		DisableDiscovery: true,
+	})
+	require.NoError(t, err)
+
+	r, err := autotls.New(autotls.Config{
+		CaFile:           "certs/ca.cert",
+		CertFile:         certFile,
+		KeyFile:          keyFile,
+		ClientAuthCaFile: caFile,
+		ClientAuth:       tls.RequireAndVerifyClientCert,
+		Reload:           true,
+		ReloadInterval:   clock.Minute,
+		HostCerts: map[string]autotls.HostCert{
+			"a.example.com": {CertPEM: bytes.NewBuffer(host.CertPEM), KeyPEM: bytes.NewBuffer(host.KeyPEM)},
+		},
+	})
+	require.NoError(t, err)
+	ln := serveTLS(t, r.ServerTLS)
+
+	org := func(serverName string) string {
+		conf := r.ClientTLS.Clone()
+		conf.ServerName = serverName
+		conn, err := tls.Dial("tcp", ln.Addr().String(), conf)
+		require.NoError(t, err)
+		defer func() { _ = conn.Close() }()
+		return conn.ConnectionState().PeerCertificates[0].Subject.Organization[0]
+	}
+	assert.Equal(t, "Host A", org("a.example.com"))
+	assert.Equal(t, "Default Org", org("localhost"))
+
+	// When the client auth CA is reloaded, HostCerts should continue to be used
+	modTime := time.Now().Add(time.Hour)
+	require.NoError(t, os.Chtimes(caFile, modTime, modTime))
+	clock.Advance(clock.Minute)
+	assert.Equal(t, "Host A", org("a.example.com"))
+	assert.Equal(t, "Host A", org("a.example.com"))
+	assert.Equal(t, "Default Org", org("localhost"))
+}
+
+func TestSetupReloadRetry(t *testing.T) {
+	defer clock.Freeze(clock.Now()).UnFreeze()
+	dir := t.TempDir()
+	certFile := filepath.Join(dir, "server.pem")
+	keyFile := filepath.Join(dir, "server.key")
+	writeCert(t, "First Org", certFile, keyFile, time.Now())
+
+	r, err := autotls.New(autotls.Config{
+		CaFile:         "certs/ca.cert",
+		CertFile:       certFile,
+		KeyFile:        keyFile,
+		Reload:         true,
+		ReloadInterval: clock.Minute,
+	})
+	require.NoError(t, err)
+	ln := serveTLS(t, r.ServerTLS)
+
+	// When the reload fails, such as when the key has not been completely written
+	modTime := time.Now().Add(time.Hour)
+	writeCert(t, "Second Org", certFile, keyFile, modTime)
+	key, err := os.ReadFile(keyFile)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(keyFile, bytes.Repeat([]byte("x"), len(key)), 0600))
+	require.NoError(t, os.Chtimes(keyFile, modTime, modTime))
+	clock.Advance(clock.Minute)
+	assert.Equal(t, "First Org", peerOrg(t, ln.Addr(), r.ClientTLS))
+
+	// Should retry the reload after the interval, even though the size and modification time are unchanged
+	require.NoError(t, os.WriteFile(keyFile, key, 0600))
+	require.NoError(t, os.Chtimes(keyFile, modTime, modTime))
+	clock.Advance(clock.Minute)
+	assert.Equal(t, "Second Org", peerOrg(t, ln.Addr(), r.ClientTLS))
+}
+
+// writeCert generates a certificate signed by the test CA and writes it to disk
+func writeCert(t *testing.T, org, certFile, keyFile string, modTime time.Time) {
+	t.Helper()
+	gen := autotls.Config{
+		CaFile:        "certs/ca.cert",
+		CaKeyFile:     "certs/ca.key",
+		ServerOrgName: org,
+		AutoTLS:       true,
+	}
+	require.NoError(t, autotls.Setup(&gen))
+	require.NoError(t, os.WriteFile(certFile, gen.CertPEM.Bytes(), 0600))
+	require.NoError(t, os.WriteFile(keyFile, gen.KeyPEM.Bytes(), 0600))
+	require.NoError(t, os.Chtimes(certFile, modTime, modTime))
+	require.NoError(t, os.Chtimes(keyFile, modTime, modTime))
+}
+
+func peerOrg(t *testing.T, addr net.Addr, conf *tls.Config) string {
+	t.Helper()
+	conf = conf.Clone()
+	conf.ServerName = "localhost"
+	conn, err := tls.Dial("tcp", addr.String(), conf)
+	require.NoError(t, err)
+	defer func() { _ = conn.Close() }()
+	certs := conn.ConnectionState().PeerCertificates
+	require.NotEmpty(t, certs)
+	require.NotEmpty(t, certs[0].Subject.Organization)
+	return certs[0].Subject.Organization[0]
+}
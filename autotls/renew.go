@@ -0,0 +1,224 @@
+package autotls
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/kapetan-io/tackle/clock"
+	"github.com/kapetan-io/tackle/metrics"
+)
+
+// RenewFunc returns a new certificate to replace the current server certificate before it expires
+type RenewFunc func(ctx context.Context, current *x509.Certificate) (*KeyPair, error)
+
+// CertExpiry reports when a certificate expires
+type CertExpiry struct {
+	// The name of the certificate, one of 'ca', 'server' or 'client'
+	Name string
+	// The subject of the certificate
+	Subject string
+	// When the certificate expires
+	NotAfter time.Time
+}
+
+// Remaining returns the time remaining until the certificate expires
+func (e CertExpiry) Remaining() clock.Duration {
+	return clock.Until(e.NotAfter)
+}
+
+// Days returns the number of whole days remaining until the certificate expires
+func (e CertExpiry) Days() int {
+	return int(e.Remaining() / (24 * clock.Hour))
+}
+
+// Expiry reports when each of the CA, server and client certificates expires. Setup() must
+// be called before calling this method.
+func (c *Config) Expiry() []CertExpiry {
+	return expiryOf(bytesOf(c.CaPEM), bytesOf(c.CertPEM), bytesOf(c.ClientAuthCertPEM), c.renewer)
+}
+
+func expiryOf(caPEM, certPEM, clientPEM []byte, r *renewer) []CertExpiry {
+	var results []CertExpiry
+	for _, der := range derOf(caPEM) {
+		results = appendExpiry(results, "ca", der)
+	}
+
+	// The renewer holds the most recently renewed server certificate
+	if r != nil {
+		results = append(results, newExpiry("server", r.current()))
+	} else if der := derOf(certPEM); len(der) != 0 {
+		results = appendExpiry(results, "server", der[0])
+	}
+
+	if der := derOf(clientPEM); len(der) != 0 {
+		results = appendExpiry(results, "client", der[0])
+	}
+	return results
+}
+
+func appendExpiry(results []CertExpiry, name string, der []byte) []CertExpiry {
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		return results
+	}
+	return append(results, newExpiry(name, cert))
+}
+
+func newExpiry(name string, cert *x509.Certificate) CertExpiry {
+	return CertExpiry{Name: name, Subject: cert.Subject.String(), NotAfter: cert.NotAfter}
+}
+
+// renewer provides the server certificate, renewing it in the background when it
+// expires within the configured duration
+type renewer struct {
+	mutex     sync.Mutex
+	cert      *tls.Certificate
+	leaf      *x509.Certificate
+	before    clock.Duration
+	renew     RenewFunc
+	refresher *refresher
+	attempted clock.Time
+	log       StandardLogger
+	metrics   metrics.Registry
+}
+
+// get returns the current certificate, starting a renewal in the background if the
+// certificate is about to expire. Failed renewals are retried at most once a minute.
+func (r *renewer) get() *tls.Certificate {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if clock.Until(r.leaf.NotAfter) < r.before && clock.Since(r.attempted) >= clock.Minute {
+		r.attempted = clock.Now()
+		r.refresher.start(r.refresh)
+	}
+	return r.cert
+}
+
+func (r *renewer) current() *x509.Certificate {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return r.leaf
+}
+
+func (r *renewer) refresh(ctx context.Context) {
+	current := r.current()
+	r.log.Info("Renewing server certificate", "subject", current.Subject.String(),
+		"not-after", current.NotAfter.String())
+
+	cert, leaf, err := func() (*tls.Certificate, *x509.Certificate, error) {
+		kp, err := r.renew(ctx, current)
+		if err != nil {
+			return nil, nil, err
+		}
+		cert, err := kp.TLSCertificate()
+		if err != nil {
+			return nil, nil, err
+		}
+		return &cert, kp.Cert, nil
+	}()
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if err != nil {
+		r.log.Warn("while renewing server certificate; continuing to use the current certificate", "err", err)
+		return
+	}
+	r.log.Info("Renewed server certificate", "not-after", leaf.NotAfter.String())
+	r.cert = cert
+	r.leaf = leaf
+	recordExpiry(r.metrics, *cert)
+}
+
+// setupRenew installs GetCertificate and GetClientCertificate callbacks which provide the server
+// certificate, renewing it before it expires using RenewFunc or by generating a new certificate.
+func setupRenew(conf *Config) error {
+	if conf.Reload || conf.AutoCert != nil || conf.OCSPFetcher != nil {
+		return errors.New("RenewBefore is not supported along with Reload, AutoCert or OCSP stapling")
+	}
+	if len(conf.ServerTLS.Certificates) == 0 {
+		return errors.New("RenewBefore requires a server certificate")
+	}
+
+	renew := conf.RenewFunc
+	if renew == nil {
+		if !conf.AutoTLS || conf.CaPEM == nil || conf.CaKeyPEM == nil {
+			return errors.New("RenewFunc is required to renew certificates not generated by AutoTLS")
+		}
+		ca, err := ParseKeyPair(conf.CaPEM.Bytes(), conf.CaKeyPEM.Bytes())
+		if err != nil {
+			return err
+		}
+		renew = regenerate(conf, ca)
+	}
+
+	cert := conf.ServerTLS.Certificates[0]
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		return fmt.Errorf("while parsing server certificate: %w", err)
+	}
+
+	r := &renewer{
+		refresher: conf.newRefresher(5 * clock.Minute),
+		before:    conf.RenewBefore,
+		log:       conf.Logger,
+		metrics:   conf.Metrics,
+		renew:     renew,
+		cert:      &cert,
+		leaf:      leaf,
+	}
+	conf.renewer = r
+
+	// GetCertificate is only consulted if Certificates is empty
+	conf.ServerTLS.Certificates = nil
+	conf.ServerTLS.GetCertificate = func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+		return r.get(), nil
+	}
+
+	// If the client is using the server certificate, it should also use the renewed certificate
+	if len(conf.ClientTLS.Certificates) != 0 && bytes.Equal(conf.ClientTLS.Certificates[0].Certificate[0], leaf.Raw) {
+		conf.ClientTLS.Certificates = nil
+		conf.ClientTLS.GetClientCertificate = func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+			return r.get(), nil
+		}
+	}
+	return nil
+}
+
+// regenerate returns a RenewFunc which generates a new server certificate signed by the CA
+func regenerate(conf *Config, ca *KeyPair) RenewFunc {
+	opts := CertOptions{
+		OrgName:              conf.ServerOrgName,
+		DNSNames:             conf.DNSNames,
+		IPAddresses:          conf.IPAddresses,
+		URIs:                 conf.URIs,
+		DisableDiscovery:     conf.DisableDiscovery,
+		DisableIPv6Discovery: conf.DisableIPv6Discovery,
+		KeyAlgorithm:         conf.KeyAlgorithm,
+		Duration:             conf.CertDuration,
+		NotBeforeSkew:        conf.NotBeforeSkew,
+	}
+	saveDir := conf.SaveDir
+
+	return func(context.Context, *x509.Certificate) (*KeyPair, error) {
+		kp, err := GenerateCert(ca, opts)
+		if err != nil {
+			return nil, err
+		}
+		if saveDir != "" {
+			if err := writeFile(filepath.Join(saveDir, SaveCertFile), kp.CertPEM, 0644); err != nil {
+				return nil, err
+			}
+			if err := writeFile(filepath.Join(saveDir, SaveKeyFile), kp.KeyPEM, 0600); err != nil {
+				return nil, err
+			}
+		}
+		return kp, nil
+	}
+}
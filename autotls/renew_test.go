@@ -0,0 +1,212 @@
+package autotls_test
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/kapetan-io/tackle/autotls"
+	"github.com/kapetan-io/tackle/clock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExpiry(t *testing.T) {
+	defer clock.Freeze(clock.Now()).UnFreeze()
+
+	r, err := autotls.New(autotls.Config{
+		ServerOrgName:    "Expiry Org",
+		AutoTLS:          true,
+		DisableDiscovery: true,
+		CertDuration:     30 * 24 * clock.Hour,
+		CaDuration:       90 * 24 * clock.Hour,
+	})
+	require.NoError(t, err)
+
+	expiry := r.Expiry()
+	require.Len(t, expiry, 2)
+	assert.Equal(t, "ca", expiry[0].Name)
+	assert.InDelta(t, 90, expiry[0].Days(), 1)
+	assert.Equal(t, "server", expiry[1].Name)
+	assert.InDelta(t, 30, expiry[1].Days(), 1)
+	assert.Contains(t, expiry[1].Subject, "Expiry Org")
+
+	conf := autotls.Config{
+		CaFile:   "certs/ca.cert",
+		CertFile: "certs/auto.pem",
+		KeyFile:  "certs/auto.key",
+	}
+	require.NoError(t, autotls.Setup(&conf))
+	expiry = conf.Expiry()
+	require.Len(t, expiry, 2)
+
+	cert, err := autotls.ParseKeyPair(conf.CertPEM.Bytes(), conf.KeyPEM.Bytes())
+	require.NoError(t, err)
+	assert.Equal(t, cert.Cert.NotAfter, expiry[1].NotAfter)
+	assert.Equal(t, clock.Until(cert.Cert.NotAfter), expiry[1].Remaining())
+}
+
+func TestSetupRenew(t *testing.T) {
+	defer clock.Freeze(clock.Now()).UnFreeze()
+
+	dir := t.TempDir()
+	r, err := autotls.New(autotls.Config{
+		AutoTLS:          true,
+		DisableDiscovery: true,
+		SaveDir:          dir,
+		CertDuration:     30 * 24 * clock.Hour,
+		RenewBefore:      7 * 24 * clock.Hour,
+	})
+	require.NoError(t, err)
+
+	ln := serveTLS(t, r.ServerTLS)
+	initial := peerSerial(t, ln.Addr().String(), r.ClientTLS)
+
+	// The certificate is not renewed until it expires within RenewBefore
+	clock.Advance(22 * 24 * clock.Hour)
+	assert.Equal(t, initial, peerSerial(t, ln.Addr().String(), r.ClientTLS))
+
+	// The current certificate is returned while the renewal happens in the background
+	clock.Advance(2 * 24 * clock.Hour)
+	assert.Equal(t, initial, peerSerial(t, ln.Addr().String(), r.ClientTLS))
+	require.Eventually(t, func() bool {
+		return peerSerial(t, ln.Addr().String(), r.ClientTLS) != initial
+	}, clock.Second*5, clock.Millisecond*10)
+	renewed := peerSerial(t, ln.Addr().String(), r.ClientTLS)
+
+	// The renewed certificate is saved to SaveDir
+	certPEM, err := os.ReadFile(filepath.Join(dir, autotls.SaveCertFile))
+	require.NoError(t, err)
+	keyPEM, err := os.ReadFile(filepath.Join(dir, autotls.SaveKeyFile))
+	require.NoError(t, err)
+	kp, err := autotls.ParseKeyPair(certPEM, keyPEM)
+	require.NoError(t, err)
+	assert.Equal(t, renewed, kp.Cert.SerialNumber.String())
+	assert.Equal(t, kp.Cert.NotAfter, r.Expiry()[1].NotAfter)
+}
+
+type fakeRenewer struct {
+	mutex sync.Mutex
+	ca    *autotls.KeyPair
+	count int
+	fail  bool
+}
+
+func (f *fakeRenewer) Renew(_ context.Context, current *x509.Certificate) (*autotls.KeyPair, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.count++
+	if f.fail {
+		return nil, errors.New("renewal failed")
+	}
+	return autotls.GenerateCert(f.ca, autotls.CertOptions{
+		OrgName:          "renewed",
+		DNSNames:         current.DNSNames,
+		DisableDiscovery: true,
+	})
+}
+
+func (f *fakeRenewer) Count() int {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	return f.count
+}
+
+func TestSetupRenewFunc(t *testing.T) {
+	defer clock.Freeze(clock.Now()).UnFreeze()
+
+	caPEM, err := os.ReadFile("certs/ca.cert")
+	require.NoError(t, err)
+	caKeyPEM, err := os.ReadFile("certs/ca.key")
+	require.NoError(t, err)
+	ca, err := autotls.ParseKeyPair(caPEM, caKeyPEM)
+	require.NoError(t, err)
+
+	renewer := &fakeRenewer{ca: ca, fail: true}
+	conf := autotls.Config{
+		CaFile:      "certs/ca.cert",
+		CertFile:    "certs/auto.pem",
+		KeyFile:     "certs/auto.key",
+		RenewBefore: 7 * 24 * clock.Hour,
+		RenewFunc:   renewer.Renew,
+	}
+	require.NoError(t, autotls.Setup(&conf))
+
+	ln := serveTLS(t, conf.ServerTLS)
+	initial := peerSerial(t, ln.Addr().String(), conf.ClientTLS)
+
+	// Move the clock to within RenewBefore of the expiry of the server certificate
+	clock.Advance(conf.Expiry()[1].Remaining() - 24*clock.Hour)
+
+	// A failed renewal continues to use the current certificate, and is retried after a minute
+	assert.Equal(t, initial, peerSerial(t, ln.Addr().String(), conf.ClientTLS))
+	require.Eventually(t, func() bool { return renewer.Count() == 1 }, clock.Second*5, clock.Millisecond*10)
+	assert.Equal(t, initial, peerSerial(t, ln.Addr().String(), conf.ClientTLS))
+	assert.Equal(t, 1, renewer.Count())
+
+	renewer.mutex.Lock()
+	renewer.fail = false
+	renewer.mutex.Unlock()
+	clock.Advance(clock.Minute)
+
+	_ = peerSerial(t, ln.Addr().String(), conf.ClientTLS)
+	require.Eventually(t, func() bool {
+		return peerSerial(t, ln.Addr().String(), conf.ClientTLS) != initial
+	}, clock.Second*5, clock.Millisecond*10)
+	assert.Equal(t, 2, renewer.Count())
+}
+
+func TestSetupRenewErrors(t *testing.T) {
+	err := autotls.Setup(&autotls.Config{
+		CaFile:      "certs/ca.cert",
+		CertFile:    "certs/auto.pem",
+		KeyFile:     "certs/auto.key",
+		RenewBefore: clock.Hour,
+	})
+	require.ErrorContains(t, err, "RenewFunc is required")
+
+	err = autotls.Setup(&autotls.Config{
+		AutoTLS:          true,
+		DisableDiscovery: true,
+		Reload:           true,
+		RenewBefore:      clock.Hour,
+	})
+	require.ErrorContains(t, err, "not supported along with Reload")
+}
+
+// serveTLS accepts connections and completes the handshake until the test completes
+func serveTLS(t *testing.T, conf *tls.Config) net.Listener {
+	t.Helper()
+	ln, err := tls.Listen("tcp", "localhost:0", conf)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			_ = conn.(*tls.Conn).Handshake()
+			_ = conn.Close()
+		}
+	}()
+	return ln
+}
+
+func peerSerial(t *testing.T, addr string, conf *tls.Config) string {
+	t.Helper()
+	conf = conf.Clone()
+	conf.ServerName = "localhost"
+	conn, err := tls.Dial("tcp", addr, conf)
+	require.NoError(t, err)
+	defer func() { _ = conn.Close() }()
+	certs := conn.ConnectionState().PeerCertificates
+	require.NotEmpty(t, certs)
+	return certs[0].SerialNumber.String()
+}
@@ -0,0 +1,78 @@
+package autotls
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"fmt"
+)
+
+// Result is the TLS configuration and PEM encoded material built by New()
+type Result struct {
+	// The config for use by the server
+	ServerTLS *tls.Config
+	// The config for use by clients and peer communication
+	ClientTLS *tls.Config
+
+	// The PEM encoded certificates and keys which were loaded or generated, nil if none.
+	CaPEM             []byte
+	CaKeyPEM          []byte
+	CertPEM           []byte
+	KeyPEM            []byte
+	ClientAuthCaPEM   []byte
+	ClientAuthKeyPEM  []byte
+	ClientAuthCertPEM []byte
+
+	renewer    *renewer
+	refreshers []*refresher
+}
+
+// New builds a server and client TLS configuration given the Config provided. Unlike Setup(), the
+// provided Config is left untouched, such that it can be reused to build any number of configurations.
+// See Config for details.
+func New(conf Config) (*Result, error) {
+	// Clone the configs as Setup() modifies them
+	if conf.ServerTLS != nil {
+		conf.ServerTLS = conf.ServerTLS.Clone()
+	}
+	if conf.ClientTLS != nil {
+		conf.ClientTLS = conf.ClientTLS.Clone()
+	}
+
+	if err := Setup(&conf); err != nil {
+		return nil, fmt.Errorf("while building TLS config: %w", err)
+	}
+
+	return &Result{
+		ServerTLS:         conf.ServerTLS,
+		ClientTLS:         conf.ClientTLS,
+		CaPEM:             bytesOf(conf.CaPEM),
+		CaKeyPEM:          bytesOf(conf.CaKeyPEM),
+		CertPEM:           bytesOf(conf.CertPEM),
+		KeyPEM:            bytesOf(conf.KeyPEM),
+		ClientAuthCaPEM:   bytesOf(conf.ClientAuthCaPEM),
+		ClientAuthKeyPEM:  bytesOf(conf.ClientAuthKeyPEM),
+		ClientAuthCertPEM: bytesOf(conf.ClientAuthCertPEM),
+		renewer:           conf.renewer,
+		refreshers:        conf.refreshers,
+	}, nil
+}
+
+// Close stops the background refreshes started by New() and waits for any refresh in progress to
+// return. See Config.Close()
+func (r *Result) Close(ctx context.Context) error {
+	return stopRefreshers(ctx, r.refreshers)
+}
+
+// Expiry reports when each of the CA, server and client certificates expires
+func (r *Result) Expiry() []CertExpiry {
+	return expiryOf(r.CaPEM, r.CertPEM, r.ClientAuthCertPEM, r.renewer)
+}
+
+// bytesOf returns a copy of the buffer contents, or nil if the buffer is nil
+func bytesOf(b *bytes.Buffer) []byte {
+	if b == nil {
+		return nil
+	}
+	return bytes.Clone(b.Bytes())
+}
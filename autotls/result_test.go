@@ -0,0 +1,58 @@
+package autotls_test
+
+import (
+	"bytes"
+	"crypto/tls"
+	"os"
+	"testing"
+
+	"github.com/kapetan-io/tackle/autotls"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNew(t *testing.T) {
+	serverTLS := &tls.Config{MinVersion: tls.VersionTLS12}
+	conf := autotls.Config{
+		CaFile:    "certs/ca.cert",
+		CaKeyFile: "certs/ca.key",
+		AutoTLS:   true,
+		ServerTLS: serverTLS,
+	}
+
+	first, err := autotls.New(conf)
+	require.NoError(t, err)
+	require.NotNil(t, first.ServerTLS)
+	require.NotNil(t, first.ClientTLS)
+	assert.Len(t, first.ServerTLS.Certificates, 1)
+	assert.NotEmpty(t, first.CertPEM)
+	assert.NotEmpty(t, first.KeyPEM)
+	assert.Equal(t, tls.VersionTLS12, int(first.ServerTLS.MinVersion))
+
+	ca, err := os.ReadFile("certs/ca.cert")
+	require.NoError(t, err)
+	assert.Equal(t, ca, first.CaPEM)
+
+	// The config provided should not be modified
+	assert.Nil(t, conf.CaPEM)
+	assert.Nil(t, conf.CertPEM)
+	assert.Nil(t, conf.ClientTLS)
+	assert.Nil(t, conf.Logger)
+	assert.Empty(t, serverTLS.Certificates)
+	assert.Nil(t, serverTLS.RootCAs)
+
+	// The same config can be used again
+	second, err := autotls.New(conf)
+	require.NoError(t, err)
+	assert.NotEqual(t, first.CertPEM, second.CertPEM)
+	assert.Equal(t, first.CaPEM, second.CaPEM)
+
+	// PEMs can be provided in place of files
+	third, err := autotls.New(autotls.Config{
+		CaPEM:   bytes.NewBuffer(second.CaPEM),
+		CertPEM: bytes.NewBuffer(second.CertPEM),
+		KeyPEM:  bytes.NewBuffer(second.KeyPEM),
+	})
+	require.NoError(t, err)
+	assert.Equal(t, second.ServerTLS.Certificates, third.ServerTLS.Certificates)
+}
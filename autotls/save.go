@@ -0,0 +1,146 @@
+package autotls
+
+import (
+	"bytes"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// The names of the files written to Config.SaveDir
+const (
+	SaveCaFile         = "ca.pem"
+	SaveCaKeyFile      = "ca.key"
+	SaveCertFile       = "server.pem"
+	SaveKeyFile        = "server.key"
+	SaveClientCertFile = "client.pem"
+	SaveClientKeyFile  = "client.key"
+)
+
+// loadSaved loads any previously saved certificates from SaveDir which have not already been provided.
+// Saved certificates which were not issued by the CA in use, such as certificates saved before a
+// CertAuthority or CaFile was provided, are skipped such that they are issued again by the CA.
+func loadSaved(conf *Config) error {
+	var err error
+	// The CertAuthority takes the place of the saved CA
+	if conf.CertAuthority == nil && conf.CaPEM == nil && conf.CaKeyPEM == nil {
+		if conf.CaPEM, conf.CaKeyPEM, err = loadSavedPair(conf.SaveDir, SaveCaFile, SaveCaKeyFile); err != nil {
+			return err
+		}
+	}
+
+	ca := signingCA(conf)
+	for _, f := range []struct {
+		certName, keyName string
+		cert, key         **bytes.Buffer
+	}{
+		{certName: SaveCertFile, keyName: SaveKeyFile, cert: &conf.CertPEM, key: &conf.KeyPEM},
+		{certName: SaveClientCertFile, keyName: SaveClientKeyFile,
+			cert: &conf.ClientAuthCertPEM, key: &conf.ClientAuthKeyPEM},
+	} {
+		if *f.cert != nil || *f.key != nil {
+			continue
+		}
+		cert, key, err := loadSavedPair(conf.SaveDir, f.certName, f.keyName)
+		if err != nil {
+			return err
+		}
+		if cert == nil {
+			continue
+		}
+		if !issuedBy(cert, ca) {
+			conf.Logger.Info("Saved certificate was not issued by the current CA; issuing a new certificate",
+				"file", filepath.Join(conf.SaveDir, f.certName))
+			continue
+		}
+		*f.cert, *f.key = cert, key
+	}
+	return nil
+}
+
+// signingCA returns the CA certificate which signs generated certificates, or nil if the CA is yet to be generated
+func signingCA(conf *Config) *x509.Certificate {
+	if conf.CertAuthority != nil {
+		return conf.CertAuthority.KeyPair().Cert
+	}
+	if certs := certsOf(conf.CaPEM); len(certs) != 0 {
+		return certs[0]
+	}
+	return nil
+}
+
+// issuedBy returns true if the first certificate in the PEM was signed by the CA
+func issuedBy(certPEM *bytes.Buffer, ca *x509.Certificate) bool {
+	if ca == nil {
+		return false
+	}
+	certs := certsOf(certPEM)
+	return len(certs) != 0 && certs[0].CheckSignatureFrom(ca) == nil
+}
+
+// loadSavedPair loads the cert and key only if both files exist
+func loadSavedPair(dir, certName, keyName string) (*bytes.Buffer, *bytes.Buffer, error) {
+	cert, err := fromFile(filepath.Join(dir, certName))
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, nil, nil
+		}
+		return nil, nil, err
+	}
+	key, err := fromFile(filepath.Join(dir, keyName))
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, nil, nil
+		}
+		return nil, nil, err
+	}
+	return cert, key, nil
+}
+
+// save writes the CA, server and client certificates and keys to SaveDir. Keys are
+// written such that only the current user can read them.
+func save(conf *Config) error {
+	if err := os.MkdirAll(conf.SaveDir, 0700); err != nil {
+		return fmt.Errorf("while creating directory '%s': %w", conf.SaveDir, err)
+	}
+
+	for _, f := range []struct {
+		name string
+		buf  *bytes.Buffer
+		perm os.FileMode
+	}{
+		{name: SaveCaFile, buf: conf.CaPEM, perm: 0644},
+		{name: SaveCaKeyFile, buf: conf.CaKeyPEM, perm: 0600},
+		{name: SaveCertFile, buf: conf.CertPEM, perm: 0644},
+		{name: SaveKeyFile, buf: conf.KeyPEM, perm: 0600},
+		{name: SaveClientCertFile, buf: conf.ClientAuthCertPEM, perm: 0644},
+		{name: SaveClientKeyFile, buf: conf.ClientAuthKeyPEM, perm: 0600},
+	} {
+		if f.buf == nil {
+			continue
+		}
+		if err := writeFile(filepath.Join(conf.SaveDir, f.name), f.buf.Bytes(), f.perm); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeFile writes to a temporary file and renames it, such that readers never observe a partial file
+func writeFile(name string, b []byte, perm os.FileMode) error {
+	tmp := name + ".tmp"
+	if err := os.WriteFile(tmp, b, perm); err != nil {
+		return fmt.Errorf("while writing file '%s': %w", tmp, err)
+	}
+	// os.WriteFile does not change the permissions of an existing file
+	if err := os.Chmod(tmp, perm); err != nil {
+		return fmt.Errorf("while changing permissions of file '%s': %w", tmp, err)
+	}
+	if err := os.Rename(tmp, name); err != nil {
+		return fmt.Errorf("while renaming file '%s': %w", tmp, err)
+	}
+	return nil
+}
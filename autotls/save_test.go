@@ -0,0 +1,82 @@
+package autotls_test
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/kapetan-io/tackle/autotls"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSetupSaveDir(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "certs")
+
+	first := autotls.Config{AutoTLS: true, SaveDir: dir}
+	require.NoError(t, autotls.Setup(&first))
+
+	for name, perm := range map[string]os.FileMode{
+		autotls.SaveCaFile:    0644,
+		autotls.SaveCaKeyFile: 0600,
+		autotls.SaveCertFile:  0644,
+		autotls.SaveKeyFile:   0600,
+	} {
+		fi, err := os.Stat(filepath.Join(dir, name))
+		require.NoError(t, err)
+		assert.Equal(t, perm, fi.Mode().Perm(), name)
+	}
+
+	b, err := os.ReadFile(filepath.Join(dir, autotls.SaveCertFile))
+	require.NoError(t, err)
+	assert.Equal(t, first.CertPEM.Bytes(), b)
+
+	// Should reuse the saved identity instead of generating a new one
+	second := autotls.Config{AutoTLS: true, SaveDir: dir}
+	require.NoError(t, autotls.Setup(&second))
+	assert.Equal(t, first.CaPEM.Bytes(), second.CaPEM.Bytes())
+	assert.Equal(t, first.CertPEM.Bytes(), second.CertPEM.Bytes())
+	assert.Equal(t, first.KeyPEM.Bytes(), second.KeyPEM.Bytes())
+}
+
+func TestSetupSaveDirCertAuthority(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "certs")
+
+	first := autotls.Config{AutoTLS: true, DisableDiscovery: true, SaveDir: dir}
+	require.NoError(t, autotls.Setup(&first))
+
+	// The certificates saved by the first Setup() were issued by a different CA, and so must be issued again
+	ca, err := autotls.NewCertAuthority(autotls.CAOptions{KeyAlgorithm: autotls.KeyAlgorithmECDSAP256})
+	require.NoError(t, err)
+	second := autotls.Config{AutoTLS: true, DisableDiscovery: true, SaveDir: dir, CertAuthority: ca}
+	require.NoError(t, autotls.Setup(&second))
+	assert.NotEqual(t, first.CertPEM.Bytes(), second.CertPEM.Bytes())
+
+	verify := func(caPEM, certPEM []byte) {
+		t.Helper()
+		pool := x509.NewCertPool()
+		require.True(t, pool.AppendCertsFromPEM(caPEM))
+		block, _ := pem.Decode(certPEM)
+		require.NotNil(t, block)
+		cert, err := x509.ParseCertificate(block.Bytes)
+		require.NoError(t, err)
+		_, err = cert.Verify(x509.VerifyOptions{Roots: pool, DNSName: "localhost"})
+		require.NoError(t, err)
+	}
+	verify(ca.KeyPair().CertPEM, second.CertPEM.Bytes())
+
+	// The certificates on disk chain to the saved CA
+	caPEM, err := os.ReadFile(filepath.Join(dir, autotls.SaveCaFile))
+	require.NoError(t, err)
+	certPEM, err := os.ReadFile(filepath.Join(dir, autotls.SaveCertFile))
+	require.NoError(t, err)
+	assert.Equal(t, ca.KeyPair().CertPEM, caPEM)
+	verify(caPEM, certPEM)
+
+	// Certificates issued by the CertAuthority are reused
+	third := autotls.Config{AutoTLS: true, DisableDiscovery: true, SaveDir: dir, CertAuthority: ca}
+	require.NoError(t, autotls.Setup(&third))
+	assert.Equal(t, second.CertPEM.Bytes(), third.CertPEM.Bytes())
+}
@@ -0,0 +1,101 @@
+package autotls
+
+import (
+	"bytes"
+	"crypto/tls"
+	"fmt"
+	"strings"
+)
+
+// HostCert is a certificate and private key served to clients which request the
+// associated hostname via SNI. See Config.HostCerts
+type HostCert struct {
+	// (Optional) The path to the certificate file in PEM format
+	CertFile string
+
+	// (Optional) The path to the private key file in PEM format
+	KeyFile string
+
+	// (Optional) The certificate in PEM format. Used if CertFile is unset.
+	CertPEM *bytes.Buffer
+
+	// (Optional) The private key in PEM format. Used if KeyFile is unset.
+	KeyPEM *bytes.Buffer
+}
+
+// setupSNI installs a GetCertificate callback which selects the certificate from HostCerts
+// using the server name requested by the client. Clients which do not request a server name,
+// or request a name not in HostCerts, are served the default server certificate.
+func setupSNI(conf *Config) error {
+	certs := make(map[string]*tls.Certificate, len(conf.HostCerts))
+	for host, hc := range conf.HostCerts {
+		var err error
+		for _, f := range []struct {
+			name string
+			buf  **bytes.Buffer
+		}{
+			{name: hc.CertFile, buf: &hc.CertPEM},
+			{name: hc.KeyFile, buf: &hc.KeyPEM},
+		} {
+			if f.name == "" {
+				continue
+			}
+			if *f.buf, err = fromFile(f.name); err != nil {
+				return err
+			}
+		}
+		if hc.CertPEM == nil || hc.KeyPEM == nil {
+			return fmt.Errorf("HostCerts entry for '%s' requires both a certificate and private key", host)
+		}
+
+		cert, err := tls.X509KeyPair(hc.CertPEM.Bytes(), hc.KeyPEM.Bytes())
+		if err != nil {
+			return fmt.Errorf("while parsing certificate and private key for '%s': %w", host, err)
+		}
+		certs[normalizeHost(host)] = &cert
+	}
+
+	// Fall back to whatever would have been served without HostCerts
+	fallback := conf.ServerTLS.GetCertificate
+	if fallback == nil {
+		defaults := conf.ServerTLS.Certificates
+		fallback = func(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+			if len(defaults) == 0 {
+				return nil, fmt.Errorf("no certificate available for server name '%s'", hello.ServerName)
+			}
+			return &defaults[0], nil
+		}
+	}
+
+	// GetCertificate is only consulted if Certificates is empty
+	conf.ServerTLS.Certificates = nil
+	conf.ServerTLS.GetCertificate = func(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+		if cert := lookupHost(certs, hello.ServerName); cert != nil {
+			return cert, nil
+		}
+		return fallback(hello)
+	}
+	return nil
+}
+
+// lookupHost finds the certificate for the exact server name, else a wildcard
+// entry such as '*.example.com' which matches the first label of the name.
+func lookupHost(certs map[string]*tls.Certificate, name string) *tls.Certificate {
+	if name == "" {
+		return nil
+	}
+	name = normalizeHost(name)
+	if cert, ok := certs[name]; ok {
+		return cert
+	}
+	if i := strings.IndexByte(name, '.'); i != -1 {
+		if cert, ok := certs["*"+name[i:]]; ok {
+			return cert
+		}
+	}
+	return nil
+}
+
+func normalizeHost(name string) string {
+	return strings.TrimSuffix(strings.ToLower(name), ".")
+}
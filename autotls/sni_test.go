@@ -0,0 +1,93 @@
+package autotls_test
+
+import (
+	"bytes"
+	"crypto/tls"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/kapetan-io/tackle/autotls"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSetupHostCerts(t *testing.T) {
+	caPEM, err := os.ReadFile("certs/ca.cert")
+	require.NoError(t, err)
+	caKeyPEM, err := os.ReadFile("certs/ca.key")
+	require.NoError(t, err)
+	ca, err := autotls.ParseKeyPair(caPEM, caKeyPEM)
+	require.NoError(t, err)
+
+	a, err := autotls.GenerateCert(ca, autotls.CertOptions{
+		OrgName:          "Host A",
+		DNSNames:         []string{"a.example.com"},
+		DisableDiscovery: true,
+	})
+	require.NoError(t, err)
+	b, err := autotls.GenerateCert(ca, autotls.CertOptions{
+		OrgName:          "Host B",
+		DNSNames:         []string{"*.b.example.com"},
+		DisableDiscovery: true,
+	})
+	require.NoError(t, err)
+
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "b.pem"), b.CertPEM, 0600))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "b.key"), b.KeyPEM, 0600))
+
+	r, err := autotls.New(autotls.Config{
+		CaFile:           "certs/ca.cert",
+		CaKeyFile:        "certs/ca.key",
+		ServerOrgName:    "Default",
+		AutoTLS:          true,
+		DisableDiscovery: true,
+		HostCerts: map[string]autotls.HostCert{
+			"A.example.com": {
+				CertPEM: bytes.NewBuffer(a.CertPEM),
+				KeyPEM:  bytes.NewBuffer(a.KeyPEM),
+			},
+			"*.b.example.com": {
+				CertFile: filepath.Join(dir, "b.pem"),
+				KeyFile:  filepath.Join(dir, "b.key"),
+			},
+		},
+	})
+	require.NoError(t, err)
+
+	ln := serveTLS(t, r.ServerTLS)
+	org := func(serverName string) string {
+		conf := r.ClientTLS.Clone()
+		conf.ServerName = serverName
+		conn, err := tls.Dial("tcp", ln.Addr().String(), conf)
+		require.NoError(t, err)
+		defer func() { _ = conn.Close() }()
+		return conn.ConnectionState().PeerCertificates[0].Subject.Organization[0]
+	}
+
+	assert.Equal(t, "Host A", org("a.example.com"))
+	assert.Equal(t, "Host A", org("a.example.com."))
+	assert.Equal(t, "Host B", org("x.b.example.com"))
+	assert.Equal(t, "Default", org("localhost"))
+}
+
+func TestSetupHostCertsErrors(t *testing.T) {
+	err := autotls.Setup(&autotls.Config{
+		AutoTLS:          true,
+		DisableDiscovery: true,
+		HostCerts: map[string]autotls.HostCert{
+			"a.example.com": {CertFile: "certs/auto.pem"},
+		},
+	})
+	require.ErrorContains(t, err, "requires both a certificate and private key")
+
+	err = autotls.Setup(&autotls.Config{
+		AutoTLS:          true,
+		DisableDiscovery: true,
+		HostCerts: map[string]autotls.HostCert{
+			"a.example.com": {CertFile: "certs/auto.pem", KeyFile: "certs/client-auth.key"},
+		},
+	})
+	require.ErrorContains(t, err, "while parsing certificate and private key for 'a.example.com'")
+}
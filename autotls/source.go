@@ -0,0 +1,153 @@
+package autotls
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"os"
+	"sync"
+
+	"github.com/kapetan-io/tackle/clock"
+	"github.com/kapetan-io/tackle/metrics"
+	"github.com/kapetan-io/tackle/set"
+)
+
+// CertSource provides the PEM encoded server certificate and private key. Implement this interface
+// to load certificates from a secret store such as HashiCorp Vault or a cloud KMS.
+type CertSource interface {
+	Fetch(ctx context.Context) (certPEM, keyPEM []byte, err error)
+}
+
+// FileCertSource reads the certificate and private key from files on disk
+type FileCertSource struct {
+	CertFile string
+	KeyFile  string
+}
+
+func (s *FileCertSource) Fetch(context.Context) ([]byte, []byte, error) {
+	cert, err := os.ReadFile(s.CertFile)
+	if err != nil {
+		return nil, nil, fmt.Errorf("while reading file '%s': %w", s.CertFile, err)
+	}
+	key, err := os.ReadFile(s.KeyFile)
+	if err != nil {
+		return nil, nil, fmt.Errorf("while reading file '%s': %w", s.KeyFile, err)
+	}
+	return cert, key, nil
+}
+
+// MemoryCertSource provides a certificate and private key held in memory, which
+// can be replaced at any time by calling Set()
+type MemoryCertSource struct {
+	mutex   sync.Mutex
+	certPEM []byte
+	keyPEM  []byte
+}
+
+// NewMemoryCertSource returns a MemoryCertSource which provides the certificate and private key
+func NewMemoryCertSource(certPEM, keyPEM []byte) *MemoryCertSource {
+	return &MemoryCertSource{certPEM: certPEM, keyPEM: keyPEM}
+}
+
+// Set replaces the certificate and private key provided by the source
+func (s *MemoryCertSource) Set(certPEM, keyPEM []byte) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.certPEM = certPEM
+	s.keyPEM = keyPEM
+}
+
+func (s *MemoryCertSource) Fetch(context.Context) ([]byte, []byte, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.certPEM, s.keyPEM, nil
+}
+
+// fetchSource fetches the server certificate and private key from the CertSource
+func fetchSource(conf *Config) error {
+	timeout := conf.RefreshTimeout
+	set.Default(&timeout, 30*clock.Second)
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	cert, key, err := conf.CertSource.Fetch(ctx)
+	if err != nil {
+		return err
+	}
+	conf.CertPEM = bytes.NewBuffer(cert)
+	conf.KeyPEM = bytes.NewBuffer(key)
+	return nil
+}
+
+// sourceReloader provides the most recent certificate from a CertSource, which is
+// fetched again in the background once per interval.
+type sourceReloader struct {
+	mutex     sync.Mutex
+	source    CertSource
+	interval  clock.Duration
+	certPEM   []byte
+	keyPEM    []byte
+	cert      *tls.Certificate
+	fetched   clock.Time
+	refresher *refresher
+	log       StandardLogger
+	metrics   metrics.Registry
+}
+
+func newSourceReloader(conf *Config) (*sourceReloader, error) {
+	cert, err := tls.X509KeyPair(conf.CertPEM.Bytes(), conf.KeyPEM.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("while parsing certificate and private key from CertSource: %w", err)
+	}
+	return &sourceReloader{
+		refresher: conf.newRefresher(30 * clock.Second),
+		interval:  conf.ReloadInterval,
+		certPEM:   conf.CertPEM.Bytes(),
+		keyPEM:    conf.KeyPEM.Bytes(),
+		source:    conf.CertSource,
+		log:       conf.Logger,
+		metrics:   conf.Metrics,
+		fetched:   clock.Now(),
+		cert:      &cert,
+	}, nil
+}
+
+// get returns the current certificate. If the certificate was fetched longer ago than the
+// interval, the certificate is fetched again in the background.
+func (r *sourceReloader) get() *tls.Certificate {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if clock.Since(r.fetched) >= r.interval {
+		r.refresher.start(r.refresh)
+	}
+	return r.cert
+}
+
+func (r *sourceReloader) refresh(ctx context.Context) {
+	certPEM, keyPEM, err := r.source.Fetch(ctx)
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.fetched = clock.Now()
+	if err != nil {
+		r.log.Warn("while fetching certificate from CertSource; continuing to use the previous certificate",
+			"err", err)
+		return
+	}
+
+	if bytes.Equal(certPEM, r.certPEM) && bytes.Equal(keyPEM, r.keyPEM) {
+		return
+	}
+
+	cert, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		r.log.Warn("while parsing certificate from CertSource; continuing to use the previous certificate",
+			"err", err)
+		return
+	}
+	r.log.Info("Reloaded certificate from CertSource")
+	r.certPEM, r.keyPEM = certPEM, keyPEM
+	r.cert = &cert
+	recordExpiry(r.metrics, cert)
+}
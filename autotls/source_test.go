@@ -0,0 +1,72 @@
+package autotls_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/kapetan-io/tackle/autotls"
+	"github.com/kapetan-io/tackle/clock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type failingSource struct{}
+
+func (failingSource) Fetch(context.Context) ([]byte, []byte, error) {
+	return nil, nil, errors.New("vault sealed")
+}
+
+func TestSetupCertSource(t *testing.T) {
+	defer clock.Freeze(clock.Now()).UnFreeze()
+
+	gen := func(org string) *autotls.Config {
+		conf := autotls.Config{
+			CaFile:           "certs/ca.cert",
+			CaKeyFile:        "certs/ca.key",
+			ServerOrgName:    org,
+			AutoTLS:          true,
+			DisableDiscovery: true,
+		}
+		require.NoError(t, autotls.Setup(&conf))
+		return &conf
+	}
+
+	first := gen("First")
+	source := autotls.NewMemoryCertSource(first.CertPEM.Bytes(), first.KeyPEM.Bytes())
+	r, err := autotls.New(autotls.Config{
+		CaFile:         "certs/ca.cert",
+		CertSource:     source,
+		Reload:         true,
+		ReloadInterval: clock.Minute,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, first.CertPEM.Bytes(), r.CertPEM)
+
+	ln := serveTLS(t, r.ServerTLS)
+	assert.Equal(t, "First", peerOrg(t, ln.Addr(), r.ClientTLS))
+
+	second := gen("Second")
+	source.Set(second.CertPEM.Bytes(), second.KeyPEM.Bytes())
+
+	// The source is not fetched again until the interval has elapsed
+	assert.Equal(t, "First", peerOrg(t, ln.Addr(), r.ClientTLS))
+
+	clock.Advance(clock.Minute)
+	require.Eventually(t, func() bool {
+		return peerOrg(t, ln.Addr(), r.ClientTLS) == "Second"
+	}, clock.Second*5, clock.Millisecond*10)
+}
+
+func TestFileCertSource(t *testing.T) {
+	source := &autotls.FileCertSource{CertFile: "certs/auto.pem", KeyFile: "certs/auto.key"}
+	conf := autotls.Config{CaFile: "certs/ca.cert", CertSource: source}
+	require.NoError(t, autotls.Setup(&conf))
+	require.Len(t, conf.ServerTLS.Certificates, 1)
+
+	_, _, err := (&autotls.FileCertSource{CertFile: "certs/missing.pem"}).Fetch(context.Background())
+	require.ErrorContains(t, err, "while reading file 'certs/missing.pem'")
+
+	err = autotls.Setup(&autotls.Config{CertSource: failingSource{}})
+	require.ErrorContains(t, err, "while fetching certificate from CertSource: vault sealed")
+}
@@ -0,0 +1,85 @@
+package autotls
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net/url"
+)
+
+// SPIFFE X.509 SVIDs identify a workload using a 'spiffe://<trust-domain>/<path>' URI SAN.
+//
+// Fetching SVIDs from the SPIFFE Workload API socket is NOT supported, as the Workload API is only
+// available via gRPC which autotls does not depend upon. Instead, have the SPIRE agent or
+// spiffe-helper write the SVID, key and bundle to disk and use CertFile, KeyFile and CaFile along
+// with Reload to pick up rotated SVIDs.
+
+// spiffeID returns the SPIFFE ID of the certificate
+func spiffeID(cert *x509.Certificate) (*url.URL, error) {
+	var id *url.URL
+	for _, u := range cert.URIs {
+		if u.Scheme != "spiffe" {
+			continue
+		}
+		if id != nil {
+			return nil, errors.New("certificate contains more than one SPIFFE ID")
+		}
+		id = u
+	}
+	if id == nil {
+		return nil, errors.New("certificate does not contain a SPIFFE ID")
+	}
+	return id, nil
+}
+
+// verifySPIFFEID returns a VerifyPeerCertificate callback which rejects peers that do not present one
+// of the allowed SPIFFE IDs, or an ID within the trust domain.
+func verifySPIFFEID(allowed []string, trustDomain string) func([][]byte, [][]*x509.Certificate) error {
+	ids := make(map[string]struct{}, len(allowed))
+	for _, id := range allowed {
+		ids[id] = struct{}{}
+	}
+
+	return func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+		if len(rawCerts) == 0 {
+			return errors.New("peer did not present a certificate with a SPIFFE ID")
+		}
+		leaf, err := x509.ParseCertificate(rawCerts[0])
+		if err != nil {
+			return fmt.Errorf("while parsing peer certificate: %w", err)
+		}
+		id, err := spiffeID(leaf)
+		if err != nil {
+			return fmt.Errorf("while verifying peer SPIFFE ID: %w", err)
+		}
+		if _, ok := ids[id.String()]; ok {
+			return nil
+		}
+		if trustDomain != "" && id.Host == trustDomain {
+			return nil
+		}
+		return fmt.Errorf("peer SPIFFE ID '%s' is not allowed", id)
+	}
+}
+
+// setupSPIFFE requires peers of both the ServerTLS and ClientTLS configs present an allowed SPIFFE ID
+func setupSPIFFE(conf *Config) error {
+	// VerifyPeerCertificate is never called by the ServerTLS if client certificates are not requested,
+	// which would silently allow clients without an allowed SPIFFE ID.
+	if conf.ClientAuth == tls.NoClientCert {
+		return errors.New("SPIFFEAllowedIDs and SPIFFETrustDomain require ClientAuth to request client certificates")
+	}
+	for _, id := range conf.SPIFFEAllowedIDs {
+		u, err := url.Parse(id)
+		if err != nil || u.Scheme != "spiffe" || u.Host == "" {
+			return fmt.Errorf("invalid SPIFFE ID '%s' in SPIFFEAllowedIDs", id)
+		}
+	}
+
+	verify := verifySPIFFEID(conf.SPIFFEAllowedIDs, conf.SPIFFETrustDomain)
+	for _, c := range []*tls.Config{conf.ServerTLS, conf.ClientTLS} {
+		addVerifyPeer(c, verify)
+	}
+	return nil
+}
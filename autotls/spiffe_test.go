@@ -0,0 +1,109 @@
+package autotls_test
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"io"
+	"testing"
+
+	"github.com/kapetan-io/tackle/autotls"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSetupSPIFFE(t *testing.T) {
+	ca, err := autotls.GenerateCA(autotls.CAOptions{KeyAlgorithm: autotls.KeyAlgorithmECDSAP256})
+	require.NoError(t, err)
+
+	svid := func(id string) *autotls.KeyPair {
+		kp, err := autotls.GenerateCert(ca, autotls.CertOptions{
+			KeyAlgorithm:     autotls.KeyAlgorithmECDSAP256,
+			DisableDiscovery: true,
+			URIs:             []string{id},
+		})
+		require.NoError(t, err)
+		return kp
+	}
+	server := svid("spiffe://example.org/server")
+
+	r, err := autotls.New(autotls.Config{
+		CaPEM:            bytes.NewBuffer(ca.CertPEM),
+		CertPEM:          bytes.NewBuffer(server.CertPEM),
+		KeyPEM:           bytes.NewBuffer(server.KeyPEM),
+		ClientAuth:       tls.RequireAndVerifyClientCert,
+		SPIFFEAllowedIDs: []string{"spiffe://example.org/client"},
+	})
+	require.NoError(t, err)
+
+	ln, err := tls.Listen("tcp", "localhost:0", r.ServerTLS)
+	require.NoError(t, err)
+	defer func() { _ = ln.Close() }()
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			if err := conn.(*tls.Conn).Handshake(); err == nil {
+				_, _ = conn.Write([]byte("ok"))
+			}
+			_ = conn.Close()
+		}
+	}()
+
+	dial := func(conf *tls.Config, kp *autotls.KeyPair) (string, error) {
+		cert, err := kp.TLSCertificate()
+		require.NoError(t, err)
+		conf = conf.Clone()
+		conf.Certificates = []tls.Certificate{cert}
+		conf.ServerName = "localhost"
+		conn, err := tls.Dial("tcp", ln.Addr().String(), conf)
+		if err != nil {
+			return "", err
+		}
+		defer func() { _ = conn.Close() }()
+		b, err := io.ReadAll(conn)
+		return string(b), err
+	}
+
+	pool := x509.NewCertPool()
+	pool.AddCert(ca.Cert)
+
+	// Allowed client SPIFFE ID
+	resp, err := dial(&tls.Config{RootCAs: pool}, svid("spiffe://example.org/client"))
+	require.NoError(t, err)
+	assert.Equal(t, "ok", resp)
+
+	// Client SPIFFE ID which is not allowed
+	resp, err = dial(&tls.Config{RootCAs: pool}, svid("spiffe://example.org/intruder"))
+	assert.Error(t, err)
+	assert.Empty(t, resp)
+
+	// Client which only trusts servers in another trust domain
+	client, err := autotls.New(autotls.Config{
+		CaPEM:             bytes.NewBuffer(ca.CertPEM),
+		ClientAuth:        tls.RequireAndVerifyClientCert,
+		SPIFFETrustDomain: "other.org",
+	})
+	require.NoError(t, err)
+	_, err = dial(client.ClientTLS, svid("spiffe://example.org/client"))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "peer SPIFFE ID 'spiffe://example.org/server' is not allowed")
+
+	_, err = autotls.New(autotls.Config{
+		CaPEM:            bytes.NewBuffer(ca.CertPEM),
+		ClientAuth:       tls.RequireAndVerifyClientCert,
+		SPIFFEAllowedIDs: []string{"https://example.org"},
+	})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "invalid SPIFFE ID 'https://example.org'")
+
+	// SPIFFE IDs of clients cannot be verified unless client certificates are requested
+	_, err = autotls.New(autotls.Config{
+		CaPEM:            bytes.NewBuffer(ca.CertPEM),
+		SPIFFEAllowedIDs: []string{"spiffe://example.org/client"},
+	})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "require ClientAuth to request client certificates")
+}
@@ -0,0 +1,129 @@
+package autotls
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/kapetan-io/tackle/clock"
+)
+
+// The number of session ticket keys kept when generating keys, such that tickets issued
+// before the two most recent rotations can still be resumed.
+const ticketKeyCount = 3
+
+// TicketKeySource provides the session ticket keys shared by all the servers in a cluster, such
+// that a session established with one server can be resumed by any other. The first key is used
+// to encrypt new tickets, all keys are used to decrypt tickets. The source is responsible for
+// rotating the keys, which are fetched every SessionTicketRotation.
+type TicketKeySource interface {
+	TicketKeys(ctx context.Context) ([][32]byte, error)
+}
+
+// NewTicketKey generates a random session ticket key
+func NewTicketKey() ([32]byte, error) {
+	var key [32]byte
+	if _, err := rand.Read(key[:]); err != nil {
+		return key, fmt.Errorf("while generating session ticket key: %w", err)
+	}
+	return key, nil
+}
+
+// ticketRotator rotates the session ticket keys used by the server config once per interval
+type ticketRotator struct {
+	mutex sync.Mutex
+	// Holds the keys which encrypt and decrypt tickets. The server config delegates to it via
+	// WrapSession and UnwrapSession, which unlike the keys are kept by configs cloned from ServerTLS.
+	keys      *tls.Config
+	source    TicketKeySource
+	interval  clock.Duration
+	current   [][32]byte
+	rotated   clock.Time
+	refresher *refresher
+	log       StandardLogger
+}
+
+// check starts a rotation in the background if the keys were rotated longer ago than the interval
+func (r *ticketRotator) check() {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if clock.Since(r.rotated) >= r.interval {
+		r.refresher.start(func(ctx context.Context) {
+			if err := r.rotate(ctx); err != nil {
+				r.log.Warn("while rotating session ticket keys; continuing to use the previous keys", "err", err)
+			}
+		})
+	}
+}
+
+// rotate fetches the keys from the source, or generates a new key, and installs them
+func (r *ticketRotator) rotate(ctx context.Context) error {
+	keys, err := r.next(ctx)
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.rotated = clock.Now()
+	if err != nil {
+		return err
+	}
+	r.current = keys
+	r.keys.SetSessionTicketKeys(keys)
+	return nil
+}
+
+func (r *ticketRotator) next(ctx context.Context) ([][32]byte, error) {
+	if r.source != nil {
+		keys, err := r.source.TicketKeys(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if len(keys) == 0 {
+			return nil, errors.New("TicketKeySource returned no session ticket keys")
+		}
+		return keys, nil
+	}
+
+	key, err := NewTicketKey()
+	if err != nil {
+		return nil, err
+	}
+
+	r.mutex.Lock()
+	keys := append([][32]byte{key}, r.current...)
+	r.mutex.Unlock()
+	if len(keys) > ticketKeyCount {
+		keys = keys[:ticketKeyCount]
+	}
+	return keys, nil
+}
+
+// setupSessionTickets installs the initial session ticket keys and installs WrapSession and
+// UnwrapSession callbacks on ServerTLS which encrypt tickets using the keys, rotating them
+// during TLS handshakes.
+func setupSessionTickets(conf *Config) error {
+	r := &ticketRotator{
+		refresher: conf.newRefresher(30 * clock.Second),
+		interval:  conf.SessionTicketRotation,
+		source:    conf.TicketKeySource,
+		keys:      &tls.Config{},
+		log:       conf.Logger,
+	}
+	var err error
+	r.refresher.run(func(ctx context.Context) { err = r.rotate(ctx) })
+	if err != nil {
+		return err
+	}
+
+	conf.ServerTLS.WrapSession = func(cs tls.ConnectionState, ss *tls.SessionState) ([]byte, error) {
+		r.check()
+		return r.keys.EncryptTicket(cs, ss)
+	}
+	conf.ServerTLS.UnwrapSession = func(identity []byte, cs tls.ConnectionState) (*tls.SessionState, error) {
+		r.check()
+		return r.keys.DecryptTicket(identity, cs)
+	}
+	return nil
+}
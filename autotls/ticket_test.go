@@ -0,0 +1,145 @@
+package autotls_test
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"net"
+	"sync"
+	"testing"
+
+	"github.com/kapetan-io/tackle/autotls"
+	"github.com/kapetan-io/tackle/clock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// firstTicketCache keeps only the first session ticket received, such that
+// later handshakes always attempt to resume with the same ticket.
+type firstTicketCache struct {
+	mutex    sync.Mutex
+	sessions map[string]*tls.ClientSessionState
+}
+
+func (c *firstTicketCache) Get(key string) (*tls.ClientSessionState, bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	s, ok := c.sessions[key]
+	return s, ok
+}
+
+func (c *firstTicketCache) Put(key string, cs *tls.ClientSessionState) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.sessions == nil {
+		c.sessions = make(map[string]*tls.ClientSessionState)
+	}
+	if _, ok := c.sessions[key]; !ok && cs != nil {
+		c.sessions[key] = cs
+	}
+}
+
+type fakeTicketSource struct {
+	mutex sync.Mutex
+	keys  [][32]byte
+	err   error
+	count int
+}
+
+func (f *fakeTicketSource) TicketKeys(context.Context) ([][32]byte, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.count++
+	return f.keys, f.err
+}
+
+func (f *fakeTicketSource) Count() int {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	return f.count
+}
+
+// resumed connects to the server and reports if the session was resumed
+func resumed(t *testing.T, addr net.Addr, conf *tls.Config) bool {
+	t.Helper()
+	conf = conf.Clone()
+	conf.ServerName = "localhost"
+	conn, err := tls.Dial("tcp", addr.String(), conf)
+	require.NoError(t, err)
+	defer func() { _ = conn.Close() }()
+	// TLS 1.3 session tickets are sent after the handshake, reading processes them
+	_, _ = conn.Read(make([]byte, 1))
+	return conn.ConnectionState().DidResume
+}
+
+func TestSetupSessionTicketRotation(t *testing.T) {
+	defer clock.Freeze(clock.Now()).UnFreeze()
+
+	r, err := autotls.New(autotls.Config{
+		AutoTLS:               true,
+		DisableDiscovery:      true,
+		SessionTicketRotation: clock.Hour,
+	})
+	require.NoError(t, err)
+	// Servers such as http.Server clone the config, which must continue to use the rotated keys
+	ln := serveTLS(t, r.ServerTLS.Clone())
+
+	client := r.ClientTLS.Clone()
+	client.ClientSessionCache = &firstTicketCache{}
+	assert.False(t, resumed(t, ln.Addr(), client))
+	assert.True(t, resumed(t, ln.Addr(), client))
+
+	// Tickets can no longer be resumed once the key which encrypted them has been rotated out
+	other := r.ClientTLS.Clone()
+	require.Eventually(t, func() bool {
+		clock.Advance(clock.Hour)
+		resumed(t, ln.Addr(), other)
+		return !resumed(t, ln.Addr(), client)
+	}, clock.Second*5, clock.Millisecond*10)
+}
+
+func TestSetupTicketKeySource(t *testing.T) {
+	defer clock.Freeze(clock.Now()).UnFreeze()
+
+	key, err := autotls.NewTicketKey()
+	require.NoError(t, err)
+	source := &fakeTicketSource{keys: [][32]byte{key}}
+
+	ca, err := autotls.NewCertAuthority(autotls.CAOptions{KeyAlgorithm: autotls.KeyAlgorithmECDSAP256})
+	require.NoError(t, err)
+
+	newServer := func(source autotls.TicketKeySource) (*autotls.Result, net.Listener) {
+		r, err := autotls.New(autotls.Config{
+			CertAuthority:    ca,
+			AutoTLS:          true,
+			DisableDiscovery: true,
+			TicketKeySource:  source,
+		})
+		require.NoError(t, err)
+		return r, serveTLS(t, r.ServerTLS)
+	}
+
+	first, firstLn := newServer(source)
+	_, secondLn := newServer(source)
+	_, unsharedLn := newServer(nil)
+	assert.Equal(t, 2, source.Count())
+
+	// A session established with one server can be resumed by another which shares the keys
+	client := first.ClientTLS.Clone()
+	client.ClientSessionCache = &firstTicketCache{}
+	assert.False(t, resumed(t, firstLn.Addr(), client))
+	assert.True(t, resumed(t, secondLn.Addr(), client))
+	assert.False(t, resumed(t, unsharedLn.Addr(), client))
+
+	// Keys are fetched again from the source once SessionTicketRotation has elapsed
+	clock.Advance(clock.Hour)
+	resumed(t, firstLn.Addr(), client)
+	require.Eventually(t, func() bool { return source.Count() == 3 }, clock.Second*5, clock.Millisecond*10)
+
+	_, err = autotls.New(autotls.Config{
+		AutoTLS:          true,
+		DisableDiscovery: true,
+		TicketKeySource:  &fakeTicketSource{err: errors.New("cluster unavailable")},
+	})
+	require.ErrorContains(t, err, "while setting up session ticket keys: cluster unavailable")
+}
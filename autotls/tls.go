@@ -18,21 +18,16 @@ package autotls
 
 import (
 	"bytes"
-	"crypto/ecdsa"
-	"crypto/elliptic"
-	"crypto/rand"
 	"crypto/tls"
 	"crypto/x509"
-	"crypto/x509/pkix"
-	"encoding/pem"
 	"errors"
 	"fmt"
-	"math/big"
 	"net"
 	"os"
 	"strings"
-	"time"
 
+	"github.com/kapetan-io/tackle/clock"
+	"github.com/kapetan-io/tackle/metrics"
 	"github.com/kapetan-io/tackle/set"
 )
 
@@ -41,10 +36,22 @@ const (
 	blockTypeRSA  = "RSA PRIVATE KEY"
 	blockTypePriv = "PRIVATE KEY"
 	blockTypeCert = "CERTIFICATE"
+
+	// The ALPN protocol used by the ACME TLS-ALPN-01 challenge (RFC 8737)
+	acmeALPNProto = "acme-tls/1"
 )
 
+// CertManager provides certificates for the server during the TLS handshake.
+// *autocert.Manager from golang.org/x/crypto/acme/autocert implements this interface.
+type CertManager interface {
+	GetCertificate(hello *tls.ClientHelloInfo) (*tls.Certificate, error)
+}
+
 type Config struct {
-	// (Optional) The path to the Trusted Certificate Authority.
+	// (Optional) The path to the Trusted Certificate Authority. The file may contain a bundle of
+	// certificates, in which case all are trusted. When generating server certificates, the first
+	// certificate in the bundle is the issuing CA and must match CaKeyFile; if it is an intermediate
+	// CA, the rest of the bundle is included in the chain presented by the server.
 	CaFile string
 
 	// (Optional) The path to the Trusted Certificate Authority private key.
@@ -61,14 +68,66 @@ type Config struct {
 	//  the CaFile provided.
 	AutoTLS bool
 
-	// (Optional) Configures the MinVersion for ServerTLS. If not set, defaults to TLS 1.0
+	// (Optional) Additional DNS names included in the generated server certificate, for
+	// example the name of a kubernetes service. 'localhost' is always included.
+	DNSNames []string
+
+	// (Optional) Additional IP addresses included in the generated server certificate.
+	// '127.0.0.1' is always included.
+	IPAddresses []string
+
+	// (Optional) If true, the network interfaces and reverse DNS names of the current host are not
+	// discovered and included in the generated server certificate. Only 'localhost', '127.0.0.1'
+	// and the names provided via DNSNames and IPAddresses are used.
+	DisableDiscovery bool
+
+	// (Optional) If true, only IPv4 addresses are discovered. By default, global unicast IPv6
+	// addresses of the current host are also included in the generated server certificate.
+	DisableIPv6Discovery bool
+
+	// (Optional) If set along with AutoTLS, the server and client certificates are issued by the
+	// CertAuthority instead of generating a new CA, and CaFile and CaKeyFile are ignored. Share a
+	// single CertAuthority between configs to avoid generating a CA each time.
+	CertAuthority *CertAuthority
+
+	// (Optional) If set along with AutoTLS, the CA, server and client certificates and keys are
+	// written as PEM files into this directory (see SaveCaFile, etc...), such that other processes
+	// can reuse the same self-signed identity. If the files already exist, they are loaded instead
+	// of generating new certificates, such that restarts also reuse the same identity. Saved
+	// certificates not issued by the CA in use, such as the CertAuthority, are issued again.
+	SaveDir string
+
+	// (Optional) The algorithm used when generating private keys for the CA and server
+	// certificates. Defaults to KeyAlgorithmECDSAP521
+	KeyAlgorithm KeyAlgorithm
+
+	// (Optional) How long generated server certificates are valid for. Defaults to 365 days
+	CertDuration clock.Duration
+
+	// (Optional) How long the generated CA certificate is valid for. Defaults to 10 years
+	CaDuration clock.Duration
+
+	// (Optional) How far in the past the NotBefore of generated certificates is set, such that
+	// certificates are not rejected by hosts whose clocks are slightly behind. Defaults to 0
+	NotBeforeSkew clock.Duration
+
+	// (Optional) Selects the minimum TLS version and cipher suites for ServerTLS. Defaults to
+	// TLSProfileModern, which supports only TLS 1.3
+	TLSProfile TLSProfile
+
+	// (Optional) Configures the MinVersion for ServerTLS, overriding the version chosen by TLSProfile
 	MinVersion uint16
 
+	// (Optional) The cipher suites enabled for TLS 1.2 and below on ServerTLS, overriding the
+	// cipher suites chosen by TLSProfile. TLS 1.3 cipher suites are not configurable.
+	CipherSuites []uint16
+
 	// (Optional) Sets the Client Authentication type as defined in the 'tls' package.
 	// Defaults to tls.NoClientCert.See the standard library tls.ClientAuthType for valid values.
 	// If set to anything but tls.NoClientCert then Setup() attempts to load ClientAuthCaFile,
 	// ClientAuthKeyFile and ClientAuthCertFile and sets those certs into the ClientTLS struct. If
-	// none of the ClientXXXFile's are set, uses KeyFile and CertFile for client authentication.
+	// none of the ClientXXXFile's are set, uses KeyFile and CertFile for client authentication,
+	// unless AutoTLS is enabled, in which case a distinct client certificate is generated.
 	ClientAuth tls.ClientAuthType
 
 	// (Optional) The path to the Trusted Certificate Authority used for client auth. If ClientAuth is
@@ -83,13 +142,54 @@ type Config struct {
 	// ClientAuth is set and this field is empty then KeyFile is used to create the ClientTLS.
 	ClientAuthCertFile string
 
+	// (Optional) Obtains and renews publicly trusted certificates via ACME (Let's Encrypt) as an
+	// alternative to self-signed generation. Typically an *autocert.Manager from the
+	// golang.org/x/crypto/acme/autocert package, which provides TLS-ALPN-01 support through
+	// ServerTLS, HTTP-01 support via Manager.HTTPHandler() and a pluggable certificate cache.
+	// If set, ServerTLS obtains its certificates from AutoCert and the acme-tls/1 protocol is
+	// enabled; AutoTLS, CertFile and KeyFile are then only used for the ClientTLS config.
+	AutoCert CertManager
+
+	// (Optional) If set, the server certificate and private key are fetched from the source and used
+	// instead of CertFile, KeyFile, CertPEM and KeyPEM. If Reload is true, the source is fetched
+	// again in the background every ReloadInterval.
+	CertSource CertSource
+
+	// (Optional) If true, CertFile and KeyFile (and ClientAuthCertFile, ClientAuthKeyFile and the
+	// client auth CA file when ClientAuth is set) are watched for changes and reloaded without
+	// restarting the server. This is useful when certificates are rotated by cert-manager or Vault.
+	// The CA used by clients to verify the server (RootCAs) is not reloaded.
+	Reload bool
+
+	// (Optional) How often the files are checked for changes when Reload is true. Files are checked
+	// during TLS handshakes, at most once per interval. Defaults to 10 seconds.
+	ReloadInterval clock.Duration
+
+	// (Optional) If true, the system CA pool is not trusted; only CaFile (or CaPEM) is used
+	// to verify certificates. By default, the CA is added to the system CA pool.
+	NoSystemRoots bool
+
+	// (Optional) If set, this pool is used as is to verify certificates by both ServerTLS and
+	// ClientTLS, and CaFile is not added to it, nor is the system CA pool consulted. Also used to
+	// verify client certificates if ClientAuth is set and ClientAuthCaFile is not.
+	RootCAs *x509.CertPool
+
 	// (Optional) If InsecureSkipVerify is true, TLS clients will accept any certificate
 	// presented by the server and any host name in that certificate.
 	InsecureSkipVerify bool
 
-	// (Optional) A Logger which implements the declared logger interface (typically *logrus.Entry)
+	// (Optional) Hex encoded SHA-256 fingerprints of the public keys (SPKI) the ClientTLS config
+	// accepts, see Fingerprint(). If set, the server certificate is not verified against the CA;
+	// instead the public key of the certificate presented by the server must match one of the
+	// fingerprints. This is a safer alternative to InsecureSkipVerify for self-signed deployments.
+	PinnedFingerprints []string
+
+	// (Optional) A Logger which implements the declared logger interface (typically *slog.Logger)
 	Logger StandardLogger
 
+	// (Optional) The registry the server certificate expiry gauge is reported to. Defaults to metrics.Default()
+	Metrics metrics.Registry
+
 	// (Optional) The CA Certificate in PEM format. Used if CaFile is unset
 	CaPEM *bytes.Buffer
 
@@ -108,9 +208,74 @@ type Config struct {
 	// (Optional) The client auth private key in PEM format. Used if ClientAuthKeyFile is unset.
 	ClientAuthKeyPEM *bytes.Buffer
 
-	// (Optional) The client auth Certificate in PEM format. Used if ClientAuthCertFile is unset.
+	// (Optional) The client auth Certificate in PEM format. Used if ClientAuthCertFile is unset. If
+	// AutoTLS generates a client certificate, it is stored here along with ClientAuthKeyPEM.
 	ClientAuthCertPEM *bytes.Buffer
 
+	// (Optional) The SPIFFE IDs (e.g. 'spiffe://example.org/billing') peers are allowed to present.
+	// If set, the leaf certificates presented by peers to both the ServerTLS and ClientTLS configs
+	// must contain exactly one SPIFFE ID URI SAN which is allowed, or is within SPIFFETrustDomain.
+	// ClientAuth must be set to a mode which requests client certificates. SVIDs are not fetched
+	// from the SPIFFE Workload API, use CertFile, KeyFile and CaFile with SVIDs written to disk.
+	SPIFFEAllowedIDs []string
+
+	// (Optional) If set, peers presenting any SPIFFE ID within this trust domain (e.g. 'example.org')
+	// are allowed. See SPIFFEAllowedIDs.
+	SPIFFETrustDomain string
+
+	// (Optional) URI SANs included in the generated server certificate, for example the SPIFFE ID
+	// of the workload.
+	URIs []string
+
+	// (Optional) The path to a PEM or DER encoded certificate revocation list (see GenerateCRL()).
+	// The CRL must be signed by ClientAuthCaFile or CaFile. Peer certificates revoked by the CRL are
+	// rejected by both the ServerTLS and ClientTLS configs. Once the NextUpdate time of the CRL has
+	// passed, the CRL is considered stale and all peer certificates are rejected.
+	CRLFile string
+
+	// (Optional) The certificate revocation list in PEM format. Used if CRLFile is unset.
+	CRLPEM *bytes.Buffer
+
+	// (Optional) Fetches OCSP responses for the server certificate, which enables OCSP stapling if set.
+	// The response is stapled to the certificate during the TLS handshake, and only responses reporting
+	// the certificate as good are stapled. The response is refreshed in the background half way between
+	// its ThisUpdate and NextUpdate times. The separate autotls/ocsp module provides a fetcher which
+	// requests responses from an OCSP responder. Not supported along with Reload or AutoCert.
+	OCSPFetcher OCSPFetcher
+
+	// (Optional) How often the stapled OCSP response is refreshed if the response has no
+	// NextUpdate time. Defaults to 1 hour
+	OCSPRefreshInterval clock.Duration
+
+	// (Optional) If set, the server certificate is renewed in the background once it expires within
+	// this duration. Not supported along with Reload, AutoCert or OCSP stapling.
+	RenewBefore clock.Duration
+
+	// (Optional) Returns a new server certificate when the current certificate expires within
+	// RenewBefore. If unset and AutoTLS is enabled, a new certificate is generated and signed by the CA.
+	RenewFunc RenewFunc
+
+	// (Optional) Additional certificates keyed by hostname, selected using the server name the client
+	// requests via SNI, such that one listener can serve several virtual hosts. A key of the form
+	// '*.example.com' matches any single label subdomain. Clients which request any other name are
+	// served the default server certificate.
+	HostCerts map[string]HostCert
+
+	// (Optional) If set, session ticket keys for ServerTLS are generated and rotated every interval,
+	// keeping the previous keys such that recently issued tickets can still be resumed. Keys are
+	// rotated during TLS handshakes, at most once per interval. If unset, the tls package default of
+	// rotating keys every 24 hours applies.
+	SessionTicketRotation clock.Duration
+
+	// (Optional) Provides session ticket keys shared across a cluster of servers, which are fetched
+	// every SessionTicketRotation (defaults to 1 hour) instead of generating keys locally.
+	TicketKeySource TicketKeySource
+
+	// (Optional) How long a background refresh may take before it is cancelled. Applies when fetching
+	// from CertSource, OCSPFetcher or TicketKeySource (defaults to 30 seconds) and when renewing
+	// the server certificate (defaults to 5 minutes). Call Close() to stop background refreshes.
+	RefreshTimeout clock.Duration
+
 	// (Optional) the server name to check when validating the provided certificate
 	ClientAuthServerName string
 
@@ -126,6 +291,12 @@ type Config struct {
 	// fields in this struct are ignored and this config is used. If unset, Setup()
 	// will create a config using the above fields.
 	ClientTLS *tls.Config
+
+	// renewer is set by Setup() if RenewBefore is set
+	renewer *renewer
+
+	// refreshers are stopped by Close()
+	refreshers []*refresher
 }
 
 func fromFile(name string) (*bytes.Buffer, error) {
@@ -140,6 +311,39 @@ func fromFile(name string) (*bytes.Buffer, error) {
 	return bytes.NewBuffer(b), nil
 }
 
+// loadFiles loads the certificates and keys from any files or CertSource provided,
+// else the PEMs provided are used
+func loadFiles(conf *Config) error {
+	var err error
+	for _, f := range []struct {
+		name string
+		buf  **bytes.Buffer
+	}{
+		{name: conf.CaFile, buf: &conf.CaPEM},
+		{name: conf.CaKeyFile, buf: &conf.CaKeyPEM},
+		{name: conf.KeyFile, buf: &conf.KeyPEM},
+		{name: conf.CertFile, buf: &conf.CertPEM},
+		{name: conf.ClientAuthCaFile, buf: &conf.ClientAuthCaPEM},
+		{name: conf.ClientAuthKeyFile, buf: &conf.ClientAuthKeyPEM},
+		{name: conf.ClientAuthCertFile, buf: &conf.ClientAuthCertPEM},
+		{name: conf.CRLFile, buf: &conf.CRLPEM},
+	} {
+		if f.name == "" {
+			continue
+		}
+		if *f.buf, err = fromFile(f.name); err != nil {
+			return err
+		}
+	}
+
+	if conf.CertSource != nil {
+		if err := fetchSource(conf); err != nil {
+			return fmt.Errorf("while fetching certificate from CertSource: %w", err)
+		}
+	}
+	return nil
+}
+
 // Setup attempts to build a server and client TLS configuration given the Config provided.
 func Setup(conf *Config) error {
 	var err error
@@ -153,83 +357,49 @@ func Setup(conf *Config) error {
 		return nil
 	}
 
-	minServerTLSVersion := conf.MinVersion
-	if minServerTLSVersion == 0 {
-		minServerTLSVersion = tls.VersionTLS13
+	minVersion, cipherSuites, err := profileOf(conf.TLSProfile)
+	if err != nil {
+		return fmt.Errorf("while selecting TLSProfile: %w", err)
+	}
+	if conf.MinVersion != 0 {
+		minVersion = conf.MinVersion
+	}
+	if len(conf.CipherSuites) != 0 {
+		cipherSuites = conf.CipherSuites
 	}
 
-	// Basic config with reasonably secure defaults
+	// Basic config with reasonably secure defaults. Certificates are verified using the same
+	// clock they are generated with, such that certificates are valid when the clock is frozen.
 	set.Default(&conf.ServerTLS, &tls.Config{
-		CipherSuites: []uint16{
-			tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256,
-			tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,
-			tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384,
-			tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384,
-			tls.TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305,
-			tls.TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305,
-			tls.TLS_ECDHE_ECDSA_WITH_AES_128_CBC_SHA256,
-			tls.TLS_ECDHE_RSA_WITH_AES_128_CBC_SHA256,
-			tls.TLS_ECDHE_ECDSA_WITH_AES_128_CBC_SHA,
-			tls.TLS_ECDHE_RSA_WITH_AES_128_CBC_SHA,
-			tls.TLS_ECDHE_ECDSA_WITH_AES_256_CBC_SHA,
-			tls.TLS_ECDHE_RSA_WITH_AES_256_CBC_SHA,
-			tls.TLS_RSA_WITH_AES_128_GCM_SHA256,
-			tls.TLS_RSA_WITH_AES_256_GCM_SHA384,
-			tls.TLS_RSA_WITH_AES_128_CBC_SHA256,
-			tls.TLS_RSA_WITH_AES_128_CBC_SHA,
-			tls.TLS_RSA_WITH_AES_256_CBC_SHA,
-		},
-		ClientAuth: conf.ClientAuth,
-		MinVersion: minServerTLSVersion,
+		CipherSuites: cipherSuites,
+		ClientAuth:   conf.ClientAuth,
+		MinVersion:   minVersion,
+		Time:         clock.Now,
 		NextProtos: []string{
 			"h2", "http/1.1", // enable HTTP/2
 		},
 	})
-	set.Default(&conf.ClientTLS, &tls.Config{})
+	set.Default(&conf.ClientTLS, &tls.Config{Time: clock.Now})
 
-	// Attempt to load any files provided
-	conf.CaPEM, err = fromFile(conf.CaFile)
-	if err != nil {
-		return err
-	}
-
-	conf.CaKeyPEM, err = fromFile(conf.CaKeyFile)
-	if err != nil {
-		return err
-	}
-
-	conf.KeyPEM, err = fromFile(conf.KeyFile)
-	if err != nil {
-		return err
-	}
-
-	conf.CertPEM, err = fromFile(conf.CertFile)
-	if err != nil {
-		return err
-	}
-
-	conf.ClientAuthCaPEM, err = fromFile(conf.ClientAuthCaFile)
-	if err != nil {
-		return err
-	}
-
-	conf.ClientAuthKeyPEM, err = fromFile(conf.ClientAuthKeyFile)
-	if err != nil {
-		return err
-	}
-
-	conf.ClientAuthCertPEM, err = fromFile(conf.ClientAuthCertFile)
-	if err != nil {
+	if err := loadFiles(conf); err != nil {
 		return err
 	}
 
 	set.Default(&conf.Logger, &NoOpLogger{})
+	set.Default(&conf.Metrics, metrics.Default())
 
 	// If generated TLS certs requested
 	if conf.AutoTLS {
 		conf.Logger.Info("AutoTLS Enabled")
 		set.Default(&conf.ServerOrgName, "Self Signed Org")
 
+		// Reuse previously generated certs
+		if conf.SaveDir != "" {
+			if err := loadSaved(conf); err != nil {
+				return fmt.Errorf("while loading saved certs: %w", err)
+			}
+		}
+
 		// Generate CA Cert and Private Key
 		if err := selfCA(conf); err != nil {
 			return fmt.Errorf("while generating self signed CA certs: %w", err)
@@ -239,15 +409,33 @@ func Setup(conf *Config) error {
 		if err := selfCert(conf); err != nil {
 			return fmt.Errorf("while generating self signed server certs: %w", err)
 		}
+
+		// Generate a distinct Client Cert and Private Key for client authentication
+		if err := selfClientCert(conf); err != nil {
+			return fmt.Errorf("while generating self signed client certs: %w", err)
+		}
+
+		if conf.SaveDir != "" {
+			if err := save(conf); err != nil {
+				return fmt.Errorf("while saving generated certs: %w", err)
+			}
+		}
 	}
 
-	if conf.CaPEM != nil {
-		rootPool, err := x509.SystemCertPool()
-		if err != nil {
-			conf.Logger.Warn("while loading system CA Certs; using provided pool instead", "err", err)
-			rootPool = x509.NewCertPool()
+	if conf.RootCAs != nil {
+		conf.ServerTLS.RootCAs = conf.RootCAs
+		conf.ClientTLS.RootCAs = conf.RootCAs
+	} else if conf.CaPEM != nil || conf.NoSystemRoots {
+		rootPool := x509.NewCertPool()
+		if !conf.NoSystemRoots {
+			if rootPool, err = x509.SystemCertPool(); err != nil {
+				conf.Logger.Warn("while loading system CA Certs; using provided pool instead", "err", err)
+				rootPool = x509.NewCertPool()
+			}
+		}
+		if conf.CaPEM != nil {
+			rootPool.AppendCertsFromPEM(conf.CaPEM.Bytes())
 		}
-		rootPool.AppendCertsFromPEM(conf.CaPEM.Bytes())
 		conf.ServerTLS.RootCAs = rootPool
 		conf.ClientTLS.RootCAs = rootPool
 	}
@@ -259,6 +447,7 @@ func Setup(conf *Config) error {
 		}
 		conf.ServerTLS.Certificates = []tls.Certificate{serverCert}
 		conf.ClientTLS.Certificates = []tls.Certificate{serverCert}
+		recordExpiry(conf.Metrics, serverCert)
 	}
 
 	// If user asked for client auth
@@ -270,6 +459,11 @@ func Setup(conf *Config) error {
 			clientPool.AppendCertsFromPEM(conf.ClientAuthCaPEM.Bytes())
 			certProvided = true
 
+		} else if conf.RootCAs != nil {
+			// else use the pinned root pool
+			clientPool = conf.RootCAs
+			certProvided = true
+
 		} else if conf.CaPEM != nil {
 			// else use the servers CA
 			clientPool.AppendCertsFromPEM(conf.CaPEM.Bytes())
@@ -295,158 +489,197 @@ func Setup(conf *Config) error {
 
 	conf.ClientTLS.ServerName = conf.ClientAuthServerName
 	conf.ClientTLS.InsecureSkipVerify = conf.InsecureSkipVerify
+
+	if len(conf.PinnedFingerprints) != 0 {
+		if err := setupPinning(conf); err != nil {
+			return fmt.Errorf("while setting up certificate pinning: %w", err)
+		}
+	}
+
+	if len(conf.SPIFFEAllowedIDs) != 0 || conf.SPIFFETrustDomain != "" {
+		if err := setupSPIFFE(conf); err != nil {
+			return fmt.Errorf("while setting up SPIFFE ID verification: %w", err)
+		}
+	}
+
+	if conf.CRLPEM != nil {
+		if err := setupCRL(conf); err != nil {
+			return fmt.Errorf("while loading CRL: %w", err)
+		}
+	}
+
+	if conf.OCSPFetcher != nil {
+		set.Default(&conf.OCSPRefreshInterval, clock.Hour)
+		if err := setupOCSP(conf); err != nil {
+			return fmt.Errorf("while setting up OCSP stapling: %w", err)
+		}
+	}
+
+	if conf.RenewBefore != 0 {
+		if err := setupRenew(conf); err != nil {
+			return fmt.Errorf("while setting up certificate renewal: %w", err)
+		}
+	}
+
+	if conf.Reload {
+		set.Default(&conf.ReloadInterval, 10*clock.Second)
+		if err := setupReload(conf); err != nil {
+			return fmt.Errorf("while setting up certificate reload: %w", err)
+		}
+	}
+
+	if conf.AutoCert != nil {
+		conf.Logger.Info("AutoCert Enabled")
+		conf.ServerTLS.Certificates = nil
+		conf.ServerTLS.GetCertificate = conf.AutoCert.GetCertificate
+		conf.ServerTLS.NextProtos = append(conf.ServerTLS.NextProtos, acmeALPNProto)
+	}
+
+	if len(conf.HostCerts) != 0 {
+		if err := setupSNI(conf); err != nil {
+			return fmt.Errorf("while loading HostCerts: %w", err)
+		}
+	}
+
+	if conf.SessionTicketRotation != 0 || conf.TicketKeySource != nil {
+		set.Default(&conf.SessionTicketRotation, clock.Hour)
+		if err := setupSessionTickets(conf); err != nil {
+			return fmt.Errorf("while setting up session ticket keys: %w", err)
+		}
+	}
+
+	if conf.Reload {
+		setupReloadCA(conf)
+	}
 	return nil
 }
 
+// recordExpiry records the expiry of the server certificate in the metrics registry
+func recordExpiry(registry metrics.Registry, cert tls.Certificate) {
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		return
+	}
+	registry.Gauge("tackle_autotls_cert_expiry_timestamp_seconds",
+		"The unix time in seconds when the server certificate expires").Set(float64(leaf.NotAfter.Unix()))
+}
+
 func selfCert(conf *Config) error {
 	if conf.CertPEM != nil && conf.KeyPEM != nil {
 		return nil
 	}
 
-	network, err := discoverNetwork()
+	// Attempt to sign the generated certs with the provided CaFile
+	if conf.CaPEM == nil || conf.CaKeyPEM == nil {
+		return errors.New("unable to generate server certs without a signing CA")
+	}
+
+	ca, err := authorityOf(conf)
 	if err != nil {
-		return fmt.Errorf("while detecting ip and host names: %w", err)
+		return err
 	}
 
-	cert := x509.Certificate{
-		ExtKeyUsage: []x509.ExtKeyUsage{
-			x509.ExtKeyUsageClientAuth,
-			x509.ExtKeyUsageServerAuth,
-		},
-		KeyUsage:              x509.KeyUsageKeyEncipherment | x509.KeyUsageDigitalSignature,
-		Subject:               pkix.Name{Organization: []string{conf.ServerOrgName}},
-		NotAfter:              time.Now().Add(365 * (24 * time.Hour)),
-		DNSNames:              []string{"localhost"},
-		IPAddresses:           []net.IP{net.ParseIP("127.0.0.1")},
-		SerialNumber:          big.NewInt(0xC0FFEE),
-		NotBefore:             time.Now(),
-		BasicConstraintsValid: true,
-	}
-
-	// Ensure all our names and ip addresses are included in the Certificate
-	cert.DNSNames = append(cert.DNSNames, network.DNSNames...)
-
-	for _, ipStr := range network.IPAddresses {
-		if ip := net.ParseIP(ipStr); ip != nil {
-			cert.IPAddresses = append(cert.IPAddresses, ip)
-		}
+	kp, err := ca.IssueServerCert(CertOptions{
+		OrgName:              conf.ServerOrgName,
+		DNSNames:             conf.DNSNames,
+		IPAddresses:          conf.IPAddresses,
+		URIs:                 conf.URIs,
+		DisableDiscovery:     conf.DisableDiscovery,
+		DisableIPv6Discovery: conf.DisableIPv6Discovery,
+		KeyAlgorithm:         conf.KeyAlgorithm,
+		Duration:             conf.CertDuration,
+		NotBeforeSkew:        conf.NotBeforeSkew,
+	})
+	if err != nil {
+		return err
 	}
 
 	conf.Logger.Info("Generating Server Private Key and Certificate....",
-		"dns-names", strings.Join(cert.DNSNames, ","),
+		"dns-names", strings.Join(kp.Cert.DNSNames, ","),
 		"cert-ips", func() string {
 			var r []string
-			for i := range cert.IPAddresses {
-				r = append(r, cert.IPAddresses[i].String())
+			for i := range kp.Cert.IPAddresses {
+				r = append(r, kp.Cert.IPAddresses[i].String())
 			}
 			return strings.Join(r, ",")
 		}(),
 	)
 
-	// Generate a public / private key
-	privKey, err := ecdsa.GenerateKey(elliptic.P521(), rand.Reader)
-	if err != nil {
-		return fmt.Errorf("while generating pubic/private key pair: %w", err)
-	}
-
-	// Attempt to sign the generated certs with the provided CaFile
-	if conf.CaPEM == nil && conf.CaKeyPEM == nil {
-		return errors.New("unable to generate server certs without a signing CA")
-	}
+	conf.CertPEM = bytes.NewBuffer(kp.CertPEM)
+	conf.KeyPEM = bytes.NewBuffer(kp.KeyPEM)
+	return nil
+}
 
-	keyPair, err := tls.X509KeyPair(conf.CaPEM.Bytes(), conf.CaKeyPEM.Bytes())
-	if err != nil {
-		return fmt.Errorf("while reading generated PEMs: %w", err)
+// selfClientCert generates a client certificate signed by the CA, unless a client certificate or a
+// separate client auth CA was provided, in which case the client certificate must be provided too.
+func selfClientCert(conf *Config) error {
+	if conf.ClientAuth == tls.NoClientCert || conf.ClientAuthCaPEM != nil {
+		return nil
 	}
-
-	if len(keyPair.Certificate) == 0 {
-		return errors.New("no certificates found in CA PEM")
+	if conf.ClientAuthCertPEM != nil || conf.ClientAuthKeyPEM != nil {
+		return nil
 	}
 
-	caCert, err := x509.ParseCertificate(keyPair.Certificate[0])
+	ca, err := authorityOf(conf)
 	if err != nil {
-		return fmt.Errorf("while parsing CA Cert: %w", err)
+		return err
 	}
 
-	signedBytes, err := x509.CreateCertificate(rand.Reader, &cert, caCert, &privKey.PublicKey, keyPair.PrivateKey)
+	conf.Logger.Info("Generating Client Private Key and Certificate....")
+	kp, err := ca.IssueClientCert(ClientCertOptions{
+		OrgName:       conf.ServerOrgName,
+		URIs:          conf.URIs,
+		KeyAlgorithm:  conf.KeyAlgorithm,
+		Duration:      conf.CertDuration,
+		NotBeforeSkew: conf.NotBeforeSkew,
+	})
 	if err != nil {
-		return fmt.Errorf("while self signing server cert: %w", err)
+		return err
 	}
 
-	conf.CertPEM = new(bytes.Buffer)
-	if err := pem.Encode(conf.CertPEM, &pem.Block{
-		Type:  "CERTIFICATE",
-		Bytes: signedBytes,
-	}); err != nil {
-		return fmt.Errorf("while encoding CERTIFICATE PEM: %w", err)
-	}
+	conf.ClientAuthCertPEM = bytes.NewBuffer(kp.CertPEM)
+	conf.ClientAuthKeyPEM = bytes.NewBuffer(kp.KeyPEM)
+	return nil
+}
 
-	b, err := x509.MarshalECPrivateKey(privKey)
-	if err != nil {
-		return fmt.Errorf("while encoding EC Marshalling: %w", err)
+// authorityOf returns the CertAuthority which issues the generated certificates
+func authorityOf(conf *Config) (*CertAuthority, error) {
+	if conf.CertAuthority != nil {
+		return conf.CertAuthority, nil
 	}
-
-	conf.KeyPEM = new(bytes.Buffer)
-	if err := pem.Encode(conf.KeyPEM, &pem.Block{
-		Type:  blockTypeEC,
-		Bytes: b,
-	}); err != nil {
-		return fmt.Errorf("while encoding EC KEY PEM: %w", err)
+	ca, err := LoadCertAuthority(conf.CaPEM.Bytes(), conf.CaKeyPEM.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("while reading generated PEMs: %w", err)
 	}
-	return nil
+	return ca, nil
 }
 
 func selfCA(conf *Config) error {
-	ca := x509.Certificate{
-		SerialNumber:          big.NewInt(2319),
-		Subject:               pkix.Name{Organization: []string{conf.ServerOrgName}},
-		NotBefore:             time.Now(),
-		NotAfter:              time.Now().AddDate(10, 0, 0),
-		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth, x509.ExtKeyUsageServerAuth},
-		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
-		BasicConstraintsValid: true,
-		IsCA:                  true,
-	}
-
-	var privKey *ecdsa.PrivateKey
-	var err error
-	var b []byte
+	// The CertAuthority takes the place of CaFile and CaKeyFile
+	if conf.CertAuthority != nil {
+		ca := conf.CertAuthority.KeyPair()
+		conf.CaPEM = bytes.NewBuffer(bytes.Clone(ca.CertPEM))
+		conf.CaKeyPEM = bytes.NewBuffer(bytes.Clone(ca.KeyPEM))
+		return nil
+	}
 
 	if conf.CaPEM != nil && conf.CaKeyPEM != nil {
 		return nil
 	}
 
 	conf.Logger.Info("Generating CA Certificates....")
-	privKey, err = ecdsa.GenerateKey(elliptic.P521(), rand.Reader)
-	if err != nil {
-		return fmt.Errorf("while generating pubic/private key pair: %w", err)
-	}
-
-	b, err = x509.CreateCertificate(rand.Reader, &ca, &ca, &privKey.PublicKey, privKey)
-	if err != nil {
-		return fmt.Errorf("while self signing CA certificate: %w", err)
-	}
-
-	conf.CaPEM = new(bytes.Buffer)
-	if err := pem.Encode(conf.CaPEM, &pem.Block{
-		Type:  blockTypeCert,
-		Bytes: b,
-	}); err != nil {
-		return fmt.Errorf("while encoding CERTIFICATE PEM: %w", err)
-	}
-
-	b, err = x509.MarshalECPrivateKey(privKey)
+	ca, err := GenerateCA(CAOptions{
+		OrgName:       conf.ServerOrgName,
+		KeyAlgorithm:  conf.KeyAlgorithm,
+		Duration:      conf.CaDuration,
+		NotBeforeSkew: conf.NotBeforeSkew,
+	})
 	if err != nil {
-		return fmt.Errorf("while marshalling EC private key: %w", err)
+		return err
 	}
 
-	conf.CaKeyPEM = new(bytes.Buffer)
-	if err := pem.Encode(conf.CaKeyPEM, &pem.Block{
-		Type:  blockTypeEC,
-		Bytes: b,
-	}); err != nil {
-		return fmt.Errorf("while encoding EC private key into PEM: %w", err)
-	}
+	conf.CaPEM = bytes.NewBuffer(ca.CertPEM)
+	conf.CaKeyPEM = bytes.NewBuffer(ca.KeyPEM)
 	return nil
 }
 
@@ -456,11 +689,11 @@ type netInfo struct {
 }
 
 // Attempts to discover all the external ips and dns names associated with the current host.
-func discoverNetwork() (netInfo, error) {
+func discoverNetwork(ipv6 bool) (netInfo, error) {
 	var result netInfo
 
 	var err error
-	result.IPAddresses, err = discoverNetworkAddresses()
+	result.IPAddresses, err = discoverNetworkAddresses(ipv6)
 	if err != nil {
 		return result, err
 	}
@@ -472,8 +705,10 @@ func discoverNetwork() (netInfo, error) {
 	return result, nil
 }
 
-// Returns a list of net addresses by inspecting the network interfaces on the current host.
-func discoverNetworkAddresses() ([]string, error) {
+// Returns a list of net addresses by inspecting the network interfaces on the current host. IPv6
+// addresses are only included if ipv6 is true, and only if they are global unicast addresses, as
+// link local addresses are only meaningful along with the interface they belong to.
+func discoverNetworkAddresses(ipv6 bool) ([]string, error) {
 	var results []string
 	ifaces, err := net.Interfaces()
 	if err != nil {
@@ -501,11 +736,13 @@ func discoverNetworkAddresses() ([]string, error) {
 			if ip == nil || ip.IsLoopback() {
 				continue
 			}
-			ip = ip.To4()
-			if ip == nil {
-				continue // not an ipv4 address
+			if ip4 := ip.To4(); ip4 != nil {
+				results = append(results, ip4.String())
+				continue
+			}
+			if ipv6 && ip.IsGlobalUnicast() {
+				results = append(results, ip.String())
 			}
-			results = append(results, ip.String())
 		}
 	}
 	return results, nil
@@ -19,16 +19,23 @@ package autotls_test
 import (
 	"context"
 	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
 	"errors"
 	"fmt"
 	"github.com/kapetan-io/tackle/autotls"
+	"github.com/kapetan-io/tackle/metrics"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"io"
 	"log"
+	"net"
 	"net/http"
+	"os"
+	"path/filepath"
 	"sync"
 	"testing"
+	"time"
 )
 
 func TestSetup(t *testing.T) {
@@ -84,30 +91,26 @@ func TestSetup(t *testing.T) {
 			err := autotls.Setup(tt.tls)
 			require.NoError(t, err)
 
-			srv := http.Server{
-				Addr: "localhost:9685",
-				Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-					_, _ = fmt.Fprintln(w, "Hello, client")
-				}),
-				TLSConfig: tt.tls.ServerTLS,
-			}
+			srv := tt.tls.NewHTTPServer("localhost:9685", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				_, _ = fmt.Fprintln(w, "Hello, client")
+			}))
 			defer func() { _ = srv.Close() }()
 
+			// Listen before serving, so the client does not race the server startup
+			ln, err := net.Listen("tcp", srv.Addr)
+			require.NoError(t, err)
+
 			wg := sync.WaitGroup{}
 			wg.Add(1)
 			go func() {
 				defer wg.Done()
-				err = srv.ListenAndServeTLS("", "")
+				err = srv.ServeTLS(ln, "", "")
 				if err != nil && !errors.Is(err, http.ErrServerClosed) {
 					t.Logf("server listen error: %v", err)
 				}
 			}()
 
-			c := &http.Client{
-				Transport: &http.Transport{TLSClientConfig: tt.tls.ClientTLS},
-			}
-
-			resp, err := c.Get("https://localhost:9685/")
+			resp, err := tt.tls.NewHTTPClient().Get("https://localhost:9685/")
 			require.NoError(t, err)
 			defer func() { _ = resp.Body.Close() }()
 			b, err := io.ReadAll(resp.Body)
@@ -130,20 +133,20 @@ func TestSetupTLSSkipVerify(t *testing.T) {
 	err := autotls.Setup(&serverTLS)
 	require.NoError(t, err)
 
-	srv := http.Server{
-		Addr: "localhost:9685",
-		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			_, _ = fmt.Fprintln(w, "Hello, client")
-		}),
-		TLSConfig: serverTLS.ServerTLS,
-	}
+	srv := serverTLS.NewHTTPServer("localhost:9685", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = fmt.Fprintln(w, "Hello, client")
+	}))
 	defer func() { _ = srv.Close() }()
 
+	// Listen before serving, so the client does not race the server startup
+	ln, err := net.Listen("tcp", srv.Addr)
+	require.NoError(t, err)
+
 	wg := sync.WaitGroup{}
 	wg.Add(1)
 	go func() {
 		defer wg.Done()
-		err = srv.ListenAndServeTLS("", "")
+		err = srv.ServeTLS(ln, "", "")
 		if err != nil && !errors.Is(err, http.ErrServerClosed) {
 			t.Logf("server listen error: %v", err)
 		}
@@ -157,11 +160,7 @@ func TestSetupTLSSkipVerify(t *testing.T) {
 	err = autotls.Setup(&clientTLS)
 	require.NoError(t, err)
 
-	c := &http.Client{
-		Transport: &http.Transport{TLSClientConfig: clientTLS.ClientTLS},
-	}
-
-	resp, err := c.Get("https://localhost:9685/")
+	resp, err := clientTLS.NewHTTPClient().Get("https://localhost:9685/")
 	require.NoError(t, err)
 	defer func() { _ = resp.Body.Close() }()
 	b, err := io.ReadAll(resp.Body)
@@ -182,21 +181,21 @@ func TestSetupTLSClientAuth(t *testing.T) {
 	err := autotls.Setup(&serverTLS)
 	require.NoError(t, err)
 
-	srv := http.Server{
-		Addr: "localhost:9685",
-		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			_, _ = fmt.Fprintln(w, "Hello, client")
-		}),
-		ErrorLog:  log.New(io.Discard, "", log.LstdFlags),
-		TLSConfig: serverTLS.ServerTLS,
-	}
+	srv := serverTLS.NewHTTPServer("localhost:9685", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = fmt.Fprintln(w, "Hello, client")
+	}))
+	srv.ErrorLog = log.New(io.Discard, "", log.LstdFlags)
 	defer func() { _ = srv.Close() }()
 
+	// Listen before serving, so the client does not race the server startup
+	ln, err := net.Listen("tcp", srv.Addr)
+	require.NoError(t, err)
+
 	wg := sync.WaitGroup{}
 	wg.Add(1)
 	go func() {
 		defer wg.Done()
-		err = srv.ListenAndServeTLS("", "")
+		err = srv.ServeTLS(ln, "", "")
 		if err != nil && !errors.Is(err, http.ErrServerClosed) {
 			t.Logf("server listen error: %v", err)
 		}
@@ -209,11 +208,11 @@ func TestSetupTLSClientAuth(t *testing.T) {
 	}
 	require.NoError(t, autotls.Setup(clientTLS))
 
-	// Should NOT be allowed without a cert signed by the client CA
+	// Should NOT be allowed without a cert signed by the client CA. HTTP/2 can report the rejection
+	// as a generic connection error, so HTTP/1.1 is used to observe the TLS alert.
 	c := &http.Client{
 		Transport: &http.Transport{TLSClientConfig: clientTLS.ClientTLS},
 	}
-
 	_, err = c.Get("https://localhost:9685/")
 	require.Error(t, err)
 	assert.Contains(t, err.Error(), "tls: certificate required")
@@ -226,11 +225,7 @@ func TestSetupTLSClientAuth(t *testing.T) {
 	}
 	require.NoError(t, autotls.Setup(clientTLS))
 
-	c = &http.Client{
-		Transport: &http.Transport{TLSClientConfig: clientTLS.ClientTLS},
-	}
-
-	resp, err := c.Get("https://localhost:9685/")
+	resp, err := clientTLS.NewHTTPClient().Get("https://localhost:9685/")
 	require.NoError(t, err)
 	defer func() { _ = resp.Body.Close() }()
 	b, err := io.ReadAll(resp.Body)
@@ -240,3 +235,347 @@ func TestSetupTLSClientAuth(t *testing.T) {
 	wg.Wait()
 
 }
+
+func TestSetupCertExpiryMetric(t *testing.T) {
+	r := metrics.NewInMemory()
+	metrics.SetDefault(r)
+	defer metrics.SetDefault(nil)
+
+	conf := autotls.Config{AutoTLS: true}
+	require.NoError(t, autotls.Setup(&conf))
+
+	s := r.Snapshot()
+	require.Len(t, s, 1)
+	assert.Equal(t, "tackle_autotls_cert_expiry_timestamp_seconds", s[0].Name)
+	expiry := time.Unix(int64(s[0].Value), 0)
+	assert.WithinDuration(t, time.Now().Add(365*24*time.Hour), expiry, time.Minute)
+}
+
+func TestSetupMetricsRegistry(t *testing.T) {
+	global := metrics.NewInMemory()
+	metrics.SetDefault(global)
+	defer metrics.SetDefault(nil)
+
+	// The registry provided is used instead of the default registry
+	r := metrics.NewInMemory()
+	conf := autotls.Config{AutoTLS: true, DisableDiscovery: true, Metrics: r}
+	require.NoError(t, autotls.Setup(&conf))
+
+	s := r.Snapshot()
+	require.Len(t, s, 1)
+	assert.Equal(t, "tackle_autotls_cert_expiry_timestamp_seconds", s[0].Name)
+	assert.Empty(t, global.Snapshot())
+}
+
+type fakeCertManager struct {
+	mutex      sync.Mutex
+	cert       tls.Certificate
+	serverName string
+}
+
+func (f *fakeCertManager) GetCertificate(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.serverName = hello.ServerName
+	return &f.cert, nil
+}
+
+func (f *fakeCertManager) ServerName() string {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	return f.serverName
+}
+
+func TestSetupAutoCert(t *testing.T) {
+	// Given a certificate authority the client trusts
+	gen := autotls.Config{AutoTLS: true}
+	require.NoError(t, autotls.Setup(&gen))
+
+	manager := &fakeCertManager{cert: gen.ServerTLS.Certificates[0]}
+	conf := autotls.Config{AutoCert: manager}
+	require.NoError(t, autotls.Setup(&conf))
+	assert.Empty(t, conf.ServerTLS.Certificates)
+	assert.Contains(t, conf.ServerTLS.NextProtos, "acme-tls/1")
+
+	ln, err := tls.Listen("tcp", "localhost:0", conf.ServerTLS)
+	require.NoError(t, err)
+	defer func() { _ = ln.Close() }()
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		_ = conn.(*tls.Conn).Handshake()
+		_ = conn.Close()
+	}()
+
+	client := gen.ClientTLS.Clone()
+	client.ServerName = "localhost"
+	conn, err := tls.Dial("tcp", ln.Addr().String(), client)
+	require.NoError(t, err)
+	_ = conn.Close()
+	assert.Equal(t, "localhost", manager.ServerName())
+}
+
+func TestSetupCertDuration(t *testing.T) {
+	conf := autotls.Config{
+		AutoTLS:       true,
+		CertDuration:  24 * time.Hour,
+		CaDuration:    48 * time.Hour,
+		NotBeforeSkew: 5 * time.Minute,
+	}
+	require.NoError(t, autotls.Setup(&conf))
+
+	leaf, err := x509.ParseCertificate(conf.ServerTLS.Certificates[0].Certificate[0])
+	require.NoError(t, err)
+	assert.WithinDuration(t, time.Now().Add(24*time.Hour), leaf.NotAfter, time.Minute)
+	assert.WithinDuration(t, time.Now().Add(-5*time.Minute), leaf.NotBefore, time.Minute)
+
+	block, _ := pem.Decode(conf.CaPEM.Bytes())
+	require.NotNil(t, block)
+	ca, err := x509.ParseCertificate(block.Bytes)
+	require.NoError(t, err)
+	assert.WithinDuration(t, time.Now().Add(48*time.Hour), ca.NotAfter, time.Minute)
+	assert.WithinDuration(t, time.Now().Add(-5*time.Minute), ca.NotBefore, time.Minute)
+}
+
+func TestSetupSANs(t *testing.T) {
+	conf := autotls.Config{
+		AutoTLS:     true,
+		DNSNames:    []string{"api.default.svc.cluster.local"},
+		IPAddresses: []string{"10.0.0.10", "fd00::10"},
+	}
+	require.NoError(t, autotls.Setup(&conf))
+
+	leaf, err := x509.ParseCertificate(conf.ServerTLS.Certificates[0].Certificate[0])
+	require.NoError(t, err)
+	assert.Contains(t, leaf.DNSNames, "localhost")
+	assert.Contains(t, leaf.DNSNames, "api.default.svc.cluster.local")
+	require.NoError(t, leaf.VerifyHostname("api.default.svc.cluster.local"))
+	require.NoError(t, leaf.VerifyHostname("10.0.0.10"))
+	require.NoError(t, leaf.VerifyHostname("fd00::10"))
+	require.NoError(t, leaf.VerifyHostname("127.0.0.1"))
+
+	conf = autotls.Config{AutoTLS: true, IPAddresses: []string{"not-an-ip"}}
+	err = autotls.Setup(&conf)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "invalid IP address 'not-an-ip' in IPAddresses")
+}
+
+func TestSetupDisableDiscovery(t *testing.T) {
+	conf := autotls.Config{
+		AutoTLS:          true,
+		DisableDiscovery: true,
+		DNSNames:         []string{"api.example.com"},
+	}
+	require.NoError(t, autotls.Setup(&conf))
+
+	leaf, err := x509.ParseCertificate(conf.ServerTLS.Certificates[0].Certificate[0])
+	require.NoError(t, err)
+	assert.Equal(t, []string{"localhost", "api.example.com"}, leaf.DNSNames)
+	require.Len(t, leaf.IPAddresses, 1)
+	assert.Equal(t, "127.0.0.1", leaf.IPAddresses[0].String())
+}
+
+func TestSetupNoSystemRoots(t *testing.T) {
+	caPEM, err := os.ReadFile("certs/ca.cert")
+	require.NoError(t, err)
+	expected := x509.NewCertPool()
+	require.True(t, expected.AppendCertsFromPEM(caPEM))
+
+	conf := autotls.Config{
+		CaFile:        "certs/ca.cert",
+		CertFile:      "certs/auto.pem",
+		KeyFile:       "certs/auto.key",
+		NoSystemRoots: true,
+	}
+	require.NoError(t, autotls.Setup(&conf))
+	assert.True(t, expected.Equal(conf.ClientTLS.RootCAs))
+	assert.True(t, expected.Equal(conf.ServerTLS.RootCAs))
+
+	// Without a CA, nothing is trusted
+	conf = autotls.Config{NoSystemRoots: true}
+	require.NoError(t, autotls.Setup(&conf))
+	require.NotNil(t, conf.ClientTLS.RootCAs)
+	assert.True(t, x509.NewCertPool().Equal(conf.ClientTLS.RootCAs))
+}
+
+func TestSetupRootCAs(t *testing.T) {
+	caPEM, err := os.ReadFile("certs/client-auth-ca.pem")
+	require.NoError(t, err)
+	pool := x509.NewCertPool()
+	require.True(t, pool.AppendCertsFromPEM(caPEM))
+
+	conf := autotls.Config{
+		CaFile:     "certs/ca.cert",
+		CertFile:   "certs/auto.pem",
+		KeyFile:    "certs/auto.key",
+		ClientAuth: tls.RequireAndVerifyClientCert,
+		RootCAs:    pool,
+	}
+	require.NoError(t, autotls.Setup(&conf))
+	assert.Same(t, pool, conf.ClientTLS.RootCAs)
+	assert.Same(t, pool, conf.ServerTLS.RootCAs)
+	assert.Same(t, pool, conf.ServerTLS.ClientCAs)
+
+	// The server certificate is signed by CaFile, which is not in the pinned pool
+	srv := conf.ServerTLS.Clone()
+	srv.ClientAuth = tls.NoClientCert
+	ln := serveTLS(t, srv)
+	client := conf.ClientTLS.Clone()
+	client.ServerName = "localhost"
+	_, err = tls.Dial("tcp", ln.Addr().String(), client)
+	var unknown x509.UnknownAuthorityError
+	require.ErrorAs(t, err, &unknown)
+}
+
+func TestSetupTLSProfile(t *testing.T) {
+	for _, test := range []struct {
+		name       string
+		conf       autotls.Config
+		minVersion uint16
+		suites     []uint16
+		excluded   []uint16
+	}{
+		{
+			name:       "DefaultIsModern",
+			minVersion: tls.VersionTLS13,
+		},
+		{
+			name:       "Intermediate",
+			conf:       autotls.Config{TLSProfile: autotls.TLSProfileIntermediate},
+			minVersion: tls.VersionTLS12,
+			suites:     []uint16{tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256},
+			excluded:   []uint16{tls.TLS_RSA_WITH_AES_128_CBC_SHA, tls.TLS_ECDHE_RSA_WITH_AES_128_CBC_SHA},
+		},
+		{
+			name:       "Legacy",
+			conf:       autotls.Config{TLSProfile: autotls.TLSProfileLegacy},
+			minVersion: tls.VersionTLS10,
+			suites:     []uint16{tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256, tls.TLS_RSA_WITH_AES_128_CBC_SHA},
+		},
+		{
+			name: "Override",
+			conf: autotls.Config{
+				TLSProfile:   autotls.TLSProfileLegacy,
+				MinVersion:   tls.VersionTLS12,
+				CipherSuites: []uint16{tls.TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305},
+			},
+			minVersion: tls.VersionTLS12,
+			suites:     []uint16{tls.TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305},
+			excluded:   []uint16{tls.TLS_RSA_WITH_AES_128_CBC_SHA},
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			require.NoError(t, autotls.Setup(&test.conf))
+			assert.Equal(t, test.minVersion, test.conf.ServerTLS.MinVersion)
+			for _, s := range test.suites {
+				assert.Contains(t, test.conf.ServerTLS.CipherSuites, s)
+			}
+			for _, s := range test.excluded {
+				assert.NotContains(t, test.conf.ServerTLS.CipherSuites, s)
+			}
+		})
+	}
+
+	err := autotls.Setup(&autotls.Config{TLSProfile: "bogus"})
+	require.ErrorContains(t, err, "unknown TLS profile 'bogus'")
+
+	// TLS 1.2 clients can connect to the intermediate profile, TLS 1.1 clients can not
+	conf := autotls.Config{AutoTLS: true, DisableDiscovery: true, TLSProfile: autotls.TLSProfileIntermediate}
+	require.NoError(t, autotls.Setup(&conf))
+	ln := serveTLS(t, conf.ServerTLS)
+	dial := func(version uint16) error {
+		client := conf.ClientTLS.Clone()
+		client.ServerName = "localhost"
+		client.MinVersion = version
+		client.MaxVersion = version
+		conn, err := tls.Dial("tcp", ln.Addr().String(), client)
+		if err != nil {
+			return err
+		}
+		return conn.Close()
+	}
+	require.NoError(t, dial(tls.VersionTLS12))
+	require.Error(t, dial(tls.VersionTLS11))
+}
+
+func TestSetupIPv6Discovery(t *testing.T) {
+	// Find the global unicast IPv6 addresses of the host, if any
+	var expected []string
+	addrs, err := net.InterfaceAddrs()
+	require.NoError(t, err)
+	for _, addr := range addrs {
+		ipNet, ok := addr.(*net.IPNet)
+		if ok && ipNet.IP.To4() == nil && ipNet.IP.IsGlobalUnicast() {
+			expected = append(expected, ipNet.IP.String())
+		}
+	}
+
+	ipv6Of := func(conf autotls.Config) []string {
+		require.NoError(t, autotls.Setup(&conf))
+		leaf, err := x509.ParseCertificate(conf.ServerTLS.Certificates[0].Certificate[0])
+		require.NoError(t, err)
+		var results []string
+		for _, ip := range leaf.IPAddresses {
+			if ip.To4() == nil {
+				assert.True(t, ip.IsGlobalUnicast(), "unexpected IPv6 address '%s'", ip)
+				results = append(results, ip.String())
+			}
+		}
+		return results
+	}
+
+	assert.ElementsMatch(t, expected, ipv6Of(autotls.Config{AutoTLS: true}))
+	assert.Empty(t, ipv6Of(autotls.Config{AutoTLS: true, DisableIPv6Discovery: true}))
+}
+
+func TestSetupAutoTLSClientCert(t *testing.T) {
+	dir := t.TempDir()
+	r, err := autotls.New(autotls.Config{
+		AutoTLS:          true,
+		DisableDiscovery: true,
+		ClientAuth:       tls.RequireAndVerifyClientCert,
+		SaveDir:          dir,
+	})
+	require.NoError(t, err)
+	require.NotNil(t, r.ClientAuthCertPEM)
+	require.NotNil(t, r.ClientAuthKeyPEM)
+
+	client, err := autotls.ParseKeyPair(r.ClientAuthCertPEM, r.ClientAuthKeyPEM)
+	require.NoError(t, err)
+	server, err := autotls.ParseKeyPair(r.CertPEM, r.KeyPEM)
+	require.NoError(t, err)
+	assert.NotEqual(t, server.Cert.SerialNumber, client.Cert.SerialNumber)
+	assert.Equal(t, []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth}, client.Cert.ExtKeyUsage)
+
+	saved, err := os.ReadFile(filepath.Join(dir, autotls.SaveClientCertFile))
+	require.NoError(t, err)
+	assert.Equal(t, r.ClientAuthCertPEM, saved)
+
+	// The server verifies the distinct client certificate
+	ln, err := tls.Listen("tcp", "localhost:0", r.ServerTLS)
+	require.NoError(t, err)
+	defer func() { _ = ln.Close() }()
+	peer := make(chan string, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer func() { _ = conn.Close() }()
+		if err := conn.(*tls.Conn).Handshake(); err != nil {
+			peer <- err.Error()
+			return
+		}
+		peer <- conn.(*tls.Conn).ConnectionState().PeerCertificates[0].SerialNumber.String()
+	}()
+
+	conf := r.ClientTLS.Clone()
+	conf.ServerName = "localhost"
+	conn, err := tls.Dial("tcp", ln.Addr().String(), conf)
+	require.NoError(t, err)
+	defer func() { _ = conn.Close() }()
+	assert.Equal(t, server.Cert.SerialNumber, conn.ConnectionState().PeerCertificates[0].SerialNumber)
+	assert.Equal(t, client.Cert.SerialNumber.String(), <-peer)
+}
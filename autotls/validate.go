@@ -0,0 +1,181 @@
+package autotls
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+
+	"github.com/kapetan-io/tackle/clock"
+)
+
+// Problem is an issue with the configuration found by Validate()
+type Problem struct {
+	// The Config field the problem relates to, such as 'CertFile'
+	Field string
+	// A description of the problem
+	Message string
+}
+
+func (p Problem) String() string {
+	return fmt.Sprintf("%s: %s", p.Field, p.Message)
+}
+
+// Validate checks the certificates and keys of the configuration and returns a list of any problems
+// found, such that misconfiguration is reported before the server starts rather than during the
+// first TLS handshake. Validate checks the server and client certificates match their private keys,
+// chain to the CA, are not expired, cover ClientAuthServerName and that the prerequisites of
+// ClientAuth are present. If called before Setup(), the files provided are loaded without modifying
+// the Config, else the certificates assembled by Setup(), including any generated, are checked.
+func (c *Config) Validate() []Problem {
+	var problems []Problem
+	add := func(field, format string, args ...any) {
+		problems = append(problems, Problem{Field: field, Message: fmt.Sprintf(format, args...)})
+	}
+
+	if c.ServerTLS == nil {
+		conf := *c
+		if err := loadFiles(&conf); err != nil {
+			add("Config", "%s", err)
+			return problems
+		}
+		c = &conf
+	}
+
+	for _, ca := range certsOf(c.CaPEM) {
+		checkExpiry(add, "CaFile", "CA certificate", ca)
+	}
+
+	roots := c.RootCAs
+	if roots == nil && c.CaPEM != nil {
+		roots = x509.NewCertPool()
+		roots.AppendCertsFromPEM(c.CaPEM.Bytes())
+	}
+
+	server := checkKeyPair(add, "CertFile", "KeyFile", "server", c.CertPEM, c.KeyPEM)
+	if server != nil {
+		checkExpiry(add, "CertFile", "server certificate", server.Leaf)
+		if roots != nil {
+			checkChain(add, "CaFile", "server certificate", server, roots, x509.ExtKeyUsageServerAuth)
+		}
+		if c.ClientAuthServerName != "" {
+			if err := server.Leaf.VerifyHostname(c.ClientAuthServerName); err != nil {
+				add("ClientAuthServerName", "server certificate SANs do not cover '%s': %s",
+					c.ClientAuthServerName, err)
+			}
+		}
+	}
+
+	if c.ClientAuth == tls.NoClientCert {
+		return problems
+	}
+
+	clientRoots := roots
+	if c.ClientAuthCaPEM != nil {
+		clientRoots = x509.NewCertPool()
+		if !clientRoots.AppendCertsFromPEM(c.ClientAuthCaPEM.Bytes()) {
+			add("ClientAuthCaFile", "no certificates found in the client auth CA")
+		}
+		for _, ca := range certsOf(c.ClientAuthCaPEM) {
+			checkExpiry(add, "ClientAuthCaFile", "client auth CA certificate", ca)
+		}
+	}
+	if clientRoots == nil {
+		add("ClientAuthCaFile", "ClientAuth is set, but no CA is provided to verify client certificates")
+	}
+
+	// If no client certificate is provided, the server certificate is used for client authentication
+	client := server
+	if c.ClientAuthCertPEM != nil || c.ClientAuthKeyPEM != nil {
+		client = checkKeyPair(add, "ClientAuthCertFile", "ClientAuthKeyFile", "client",
+			c.ClientAuthCertPEM, c.ClientAuthKeyPEM)
+		if client != nil {
+			checkExpiry(add, "ClientAuthCertFile", "client certificate", client.Leaf)
+		}
+	}
+	if client == nil {
+		add("ClientAuthCertFile", "ClientAuth is set, but no client certificate is provided")
+	} else if clientRoots != nil {
+		checkChain(add, "ClientAuthCaFile", "client certificate", client, clientRoots, x509.ExtKeyUsageClientAuth)
+	}
+	return problems
+}
+
+// checkKeyPair reports if only one of the cert and key is provided, or if they do not match
+func checkKeyPair(add func(string, string, ...any), certField, keyField, name string,
+	certPEM, keyPEM *bytes.Buffer) *tls.Certificate {
+	switch {
+	case certPEM == nil && keyPEM == nil:
+		return nil
+	case certPEM == nil:
+		add(certField, "%s private key provided without a certificate", name)
+		return nil
+	case keyPEM == nil:
+		add(keyField, "%s certificate provided without a private key", name)
+		return nil
+	}
+
+	cert, err := tls.X509KeyPair(certPEM.Bytes(), keyPEM.Bytes())
+	if err != nil {
+		add(keyField, "%s private key does not match the certificate: %s", name, err)
+		return nil
+	}
+	if cert.Leaf, err = x509.ParseCertificate(cert.Certificate[0]); err != nil {
+		add(certField, "while parsing %s certificate: %s", name, err)
+		return nil
+	}
+	return &cert
+}
+
+func checkExpiry(add func(string, string, ...any), field, name string, cert *x509.Certificate) {
+	now := clock.Now()
+	if now.After(cert.NotAfter) {
+		add(field, "%s '%s' expired at %s", name, cert.Subject, cert.NotAfter)
+	}
+	if now.Before(cert.NotBefore) {
+		add(field, "%s '%s' is not valid until %s", name, cert.Subject, cert.NotBefore)
+	}
+}
+
+// checkChain reports if the certificate does not chain to the roots. Expiry is reported by
+// checkExpiry, so the chain is verified at a time the leaf certificate is valid.
+func checkChain(add func(string, string, ...any), field, name string, cert *tls.Certificate,
+	roots *x509.CertPool, usage x509.ExtKeyUsage) {
+	at := clock.Now()
+	if at.After(cert.Leaf.NotAfter) {
+		at = cert.Leaf.NotAfter
+	}
+	if at.Before(cert.Leaf.NotBefore) {
+		at = cert.Leaf.NotBefore
+	}
+
+	intermediates := x509.NewCertPool()
+	for _, der := range cert.Certificate[1:] {
+		if c, err := x509.ParseCertificate(der); err == nil {
+			intermediates.AddCert(c)
+		}
+	}
+	_, err := cert.Leaf.Verify(x509.VerifyOptions{
+		Roots:         roots,
+		Intermediates: intermediates,
+		CurrentTime:   at,
+		KeyUsages:     []x509.ExtKeyUsage{usage},
+	})
+	if err != nil {
+		add(field, "%s does not chain to the CA: %s", name, err)
+	}
+}
+
+// certsOf parses the certificates in the PEM buffer, skipping any which fail to parse
+func certsOf(b *bytes.Buffer) []*x509.Certificate {
+	if b == nil {
+		return nil
+	}
+	var results []*x509.Certificate
+	for _, der := range derOf(b.Bytes()) {
+		if c, err := x509.ParseCertificate(der); err == nil {
+			results = append(results, c)
+		}
+	}
+	return results
+}
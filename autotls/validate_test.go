@@ -0,0 +1,133 @@
+package autotls_test
+
+import (
+	"crypto/tls"
+	"testing"
+
+	"github.com/kapetan-io/tackle/autotls"
+	"github.com/kapetan-io/tackle/clock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidate(t *testing.T) {
+	for _, test := range []struct {
+		name    string
+		conf    autotls.Config
+		field   string
+		message string
+	}{
+		{
+			name:    "KeyMismatch",
+			conf:    autotls.Config{CertFile: "certs/auto.pem", KeyFile: "certs/client-auth.key"},
+			field:   "KeyFile",
+			message: "server private key does not match the certificate",
+		},
+		{
+			name:    "MissingKey",
+			conf:    autotls.Config{CertFile: "certs/auto.pem"},
+			field:   "KeyFile",
+			message: "server certificate provided without a private key",
+		},
+		{
+			name: "WrongCA",
+			conf: autotls.Config{
+				CaFile:   "certs/client-auth-ca.pem",
+				CertFile: "certs/auto.pem",
+				KeyFile:  "certs/auto.key",
+			},
+			field:   "CaFile",
+			message: "server certificate does not chain to the CA",
+		},
+		{
+			name: "ServerName",
+			conf: autotls.Config{
+				CaFile:               "certs/ca.cert",
+				CertFile:             "certs/auto.pem",
+				KeyFile:              "certs/auto.key",
+				ClientAuthServerName: "wrong.example.com",
+			},
+			field:   "ClientAuthServerName",
+			message: "server certificate SANs do not cover 'wrong.example.com'",
+		},
+		{
+			name: "ClientAuthWithoutCA",
+			conf: autotls.Config{
+				CertFile:   "certs/auto.pem",
+				KeyFile:    "certs/auto.key",
+				ClientAuth: tls.RequireAndVerifyClientCert,
+			},
+			field:   "ClientAuthCaFile",
+			message: "no CA is provided to verify client certificates",
+		},
+		{
+			name: "ClientAuthWithoutCert",
+			conf: autotls.Config{
+				CaFile:     "certs/ca.cert",
+				ClientAuth: tls.RequireAndVerifyClientCert,
+			},
+			field:   "ClientAuthCertFile",
+			message: "no client certificate is provided",
+		},
+		{
+			name: "ClientCertWrongCA",
+			conf: autotls.Config{
+				CaFile:             "certs/ca.cert",
+				CertFile:           "certs/auto.pem",
+				KeyFile:            "certs/auto.key",
+				ClientAuth:         tls.RequireAndVerifyClientCert,
+				ClientAuthCertFile: "certs/client-auth.pem",
+				ClientAuthKeyFile:  "certs/client-auth.key",
+			},
+			field:   "ClientAuthCaFile",
+			message: "client certificate does not chain to the CA",
+		},
+		{
+			name:    "MissingFile",
+			conf:    autotls.Config{CertFile: "certs/missing.pem"},
+			field:   "Config",
+			message: "while reading file 'certs/missing.pem'",
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			problems := test.conf.Validate()
+			require.NotEmpty(t, problems)
+			var found bool
+			for _, p := range problems {
+				if p.Field == test.field {
+					assert.Contains(t, p.Message, test.message)
+					assert.Contains(t, p.String(), test.field+": ")
+					found = true
+				}
+			}
+			assert.True(t, found, "expected a problem with '%s' in %v", test.field, problems)
+
+			// Validate does not modify the Config
+			assert.Nil(t, test.conf.CertPEM)
+			assert.Nil(t, test.conf.ServerTLS)
+		})
+	}
+}
+
+func TestValidateAfterSetup(t *testing.T) {
+	defer clock.Freeze(clock.Now()).UnFreeze()
+
+	conf := autotls.Config{
+		AutoTLS:              true,
+		DisableDiscovery:     true,
+		ClientAuth:           tls.RequireAndVerifyClientCert,
+		ClientAuthServerName: "localhost",
+		CertDuration:         30 * 24 * clock.Hour,
+	}
+	require.NoError(t, autotls.Setup(&conf))
+	assert.Empty(t, conf.Validate())
+
+	// Expired certificates are reported
+	clock.Advance(31 * 24 * clock.Hour)
+	problems := conf.Validate()
+	require.Len(t, problems, 2)
+	assert.Equal(t, "CertFile", problems[0].Field)
+	assert.Contains(t, problems[0].Message, "server certificate 'O=Self Signed Org' expired at")
+	assert.Equal(t, "ClientAuthCertFile", problems[1].Field)
+	assert.Contains(t, problems[1].Message, "client certificate 'O=Self Signed Org' expired at")
+}
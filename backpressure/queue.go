@@ -0,0 +1,273 @@
+// Package backpressure provides a generic bounded queue with configurable behavior when the queue
+// is full, watermark callbacks and depth metrics. It is useful in front of worker pools or async
+// handlers where an unbounded queue would hide an overloaded consumer.
+package backpressure
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/kapetan-io/tackle/metrics"
+	"github.com/kapetan-io/tackle/set"
+)
+
+var (
+	// ErrFull is returned by Push() when the queue is full and the Overflow policy is Reject
+	ErrFull = errors.New("backpressure: queue is full")
+	// ErrClosed is returned by Push() when the queue is closed, and by Pop() when the queue is closed and empty
+	ErrClosed = errors.New("backpressure: queue is closed")
+)
+
+// Overflow determines the behavior of Push() when the queue is full
+type Overflow int
+
+const (
+	// Block waits until there is room in the queue or the context is cancelled
+	Block Overflow = iota
+	// DropOldest removes the oldest item in the queue to make room for the new item
+	DropOldest
+	// DropNewest discards the item being pushed
+	DropNewest
+	// Reject returns ErrFull
+	Reject
+)
+
+type QueueConfig[T any] struct {
+	// (Optional) The maximum number of items the queue can hold. Defaults to 100 if zero or negative
+	Capacity int
+
+	// (Optional) The behavior of Push() when the queue is full. Defaults to Block
+	Overflow Overflow
+
+	// (Optional) OnDrop is called with any item dropped due to the DropOldest or DropNewest policies
+	OnDrop func(item T)
+
+	// (Optional) When the queue depth reaches HighWatermark, OnHighWatermark is called. It will not
+	// be called again until the depth has dropped to LowWatermark and OnLowWatermark has been called.
+	HighWatermark   int
+	LowWatermark    int
+	OnHighWatermark func(depth int)
+	OnLowWatermark  func(depth int)
+
+	// (Optional) If set, the queue depth is reported as a gauge with this name in the default metrics registry
+	MetricName string
+}
+
+// Stats are the statistics collected since the queue was created
+type Stats struct {
+	Depth    int
+	Capacity int
+	Pushed   int64
+	Popped   int64
+	Dropped  int64
+	Rejected int64
+}
+
+// Queue is a bounded FIFO queue, it is safe for concurrent use
+type Queue[T any] struct {
+	mutex    sync.Mutex
+	conf     QueueConfig[T]
+	items    []T
+	head     int
+	size     int
+	closed   bool
+	high     bool
+	stats    Stats
+	notEmpty chan struct{}
+	notFull  chan struct{}
+	depth    metrics.Gauge
+}
+
+// NewQueue creates a new Queue using the config provided
+func NewQueue[T any](conf QueueConfig[T]) *Queue[T] {
+	// A negative capacity would panic when allocating the queue, so it is treated as unset
+	if conf.Capacity < 0 {
+		conf.Capacity = 0
+	}
+	set.Default(&conf.Capacity, 100)
+	q := &Queue[T]{
+		conf:     conf,
+		items:    make([]T, conf.Capacity),
+		notEmpty: make(chan struct{}),
+		notFull:  make(chan struct{}),
+	}
+	if conf.MetricName != "" {
+		q.depth = metrics.Default().Gauge(conf.MetricName, "The number of items in the queue")
+	}
+	return q
+}
+
+// Push adds an item to the end of the queue. If the queue is full, the behavior is determined by
+// the QueueConfig.Overflow policy. Returns ErrClosed if the queue has been closed.
+func (q *Queue[T]) Push(ctx context.Context, item T) error {
+	for {
+		q.mutex.Lock()
+		if q.closed {
+			q.mutex.Unlock()
+			return ErrClosed
+		}
+
+		if q.size < len(q.items) {
+			q.push(item)
+			q.unlockAndNotify()
+			return nil
+		}
+
+		switch q.conf.Overflow {
+		case DropOldest:
+			dropped := q.remove()
+			q.push(item)
+			q.stats.Dropped++
+			q.unlockAndNotify()
+			q.drop(dropped)
+			return nil
+		case DropNewest:
+			q.stats.Dropped++
+			q.mutex.Unlock()
+			q.drop(item)
+			return nil
+		case Reject:
+			q.stats.Rejected++
+			q.mutex.Unlock()
+			return ErrFull
+		}
+
+		notFull := q.notFull
+		q.mutex.Unlock()
+		select {
+		case <-notFull:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// Pop removes and returns the item at the front of the queue, blocking until an item is available
+// or the context is cancelled. Returns ErrClosed once the queue is closed and empty.
+func (q *Queue[T]) Pop(ctx context.Context) (T, error) {
+	var zero T
+	for {
+		q.mutex.Lock()
+		if q.size > 0 {
+			item := q.pop()
+			q.unlockAndNotify()
+			return item, nil
+		}
+		if q.closed {
+			q.mutex.Unlock()
+			return zero, ErrClosed
+		}
+
+		notEmpty := q.notEmpty
+		q.mutex.Unlock()
+		select {
+		case <-notEmpty:
+		case <-ctx.Done():
+			return zero, ctx.Err()
+		}
+	}
+}
+
+// TryPop removes and returns the item at the front of the queue, returns false if the queue is empty
+func (q *Queue[T]) TryPop() (T, bool) {
+	q.mutex.Lock()
+	if q.size == 0 {
+		q.mutex.Unlock()
+		var zero T
+		return zero, false
+	}
+	item := q.pop()
+	q.unlockAndNotify()
+	return item, true
+}
+
+// Len returns the number of items in the queue
+func (q *Queue[T]) Len() int {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	return q.size
+}
+
+// Stats returns a snapshot of the queue statistics
+func (q *Queue[T]) Stats() Stats {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	s := q.stats
+	s.Depth = q.size
+	s.Capacity = len(q.items)
+	return s
+}
+
+// Close closes the queue. Subsequent calls to Push() return ErrClosed, while Pop()
+// continues to return the remaining items before returning ErrClosed.
+func (q *Queue[T]) Close() {
+	q.mutex.Lock()
+	if !q.closed {
+		q.closed = true
+		close(q.notEmpty)
+		close(q.notFull)
+		q.notEmpty = make(chan struct{})
+		q.notFull = make(chan struct{})
+	}
+	q.mutex.Unlock()
+}
+
+// push adds an item to the ring buffer, must be called with the mutex held
+func (q *Queue[T]) push(item T) {
+	q.items[(q.head+q.size)%len(q.items)] = item
+	q.size++
+	q.stats.Pushed++
+}
+
+// pop removes an item from the ring buffer and counts it as popped, must be called with the mutex held
+func (q *Queue[T]) pop() T {
+	q.stats.Popped++
+	return q.remove()
+}
+
+// remove removes an item from the ring buffer without updating the stats, must be called with the mutex held
+func (q *Queue[T]) remove() T {
+	var zero T
+	item := q.items[q.head]
+	q.items[q.head] = zero
+	q.head = (q.head + 1) % len(q.items)
+	q.size--
+	return item
+}
+
+// unlockAndNotify wakes any blocked Push() or Pop() calls, updates the depth gauge
+// and calls the watermark callbacks after releasing the mutex.
+func (q *Queue[T]) unlockAndNotify() {
+	close(q.notEmpty)
+	close(q.notFull)
+	q.notEmpty = make(chan struct{})
+	q.notFull = make(chan struct{})
+
+	depth := q.size
+	var callback func(int)
+	if q.conf.HighWatermark > 0 {
+		if !q.high && depth >= q.conf.HighWatermark {
+			q.high = true
+			callback = q.conf.OnHighWatermark
+		} else if q.high && depth <= q.conf.LowWatermark {
+			q.high = false
+			callback = q.conf.OnLowWatermark
+		}
+	}
+	// Set the gauge while holding the mutex so concurrent updates are not reordered
+	if q.depth != nil {
+		q.depth.Set(float64(depth))
+	}
+	q.mutex.Unlock()
+
+	if callback != nil {
+		callback(depth)
+	}
+}
+
+func (q *Queue[T]) drop(item T) {
+	if q.conf.OnDrop != nil {
+		q.conf.OnDrop(item)
+	}
+}
@@ -0,0 +1,144 @@
+package backpressure_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/kapetan-io/tackle/backpressure"
+	"github.com/kapetan-io/tackle/metrics"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestQueueOverflow(t *testing.T) {
+	ctx := context.Background()
+
+	for _, tt := range []struct {
+		name     string
+		overflow backpressure.Overflow
+		err      error
+		items    []int
+		dropped  []int
+	}{
+		{name: "drop oldest", overflow: backpressure.DropOldest, items: []int{2, 3}, dropped: []int{1}},
+		{name: "drop newest", overflow: backpressure.DropNewest, items: []int{1, 2}, dropped: []int{3}},
+		{name: "reject", overflow: backpressure.Reject, items: []int{1, 2}, err: backpressure.ErrFull},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			var dropped []int
+			q := backpressure.NewQueue(backpressure.QueueConfig[int]{
+				Capacity: 2,
+				Overflow: tt.overflow,
+				OnDrop:   func(item int) { dropped = append(dropped, item) },
+			})
+
+			require.NoError(t, q.Push(ctx, 1))
+			require.NoError(t, q.Push(ctx, 2))
+			assert.ErrorIs(t, q.Push(ctx, 3), tt.err)
+
+			// Dropped items are not counted as popped
+			stats := q.Stats()
+			assert.Equal(t, int64(len(tt.dropped)), stats.Dropped)
+			assert.Equal(t, int64(0), stats.Popped)
+
+			var items []int
+			for v, ok := q.TryPop(); ok; v, ok = q.TryPop() {
+				items = append(items, v)
+			}
+			assert.Equal(t, tt.items, items)
+			assert.Equal(t, tt.dropped, dropped)
+		})
+	}
+}
+
+func TestQueueBlock(t *testing.T) {
+	q := backpressure.NewQueue(backpressure.QueueConfig[string]{Capacity: 1})
+	require.NoError(t, q.Push(context.Background(), "first"))
+
+	// Push should block until the context is cancelled
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, q.Push(ctx, "second"), context.DeadlineExceeded)
+
+	// Push should unblock once there is room
+	done := make(chan error)
+	go func() { done <- q.Push(context.Background(), "third") }()
+	v, err := q.Pop(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, "first", v)
+	require.NoError(t, <-done)
+
+	v, err = q.Pop(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, "third", v)
+
+	// Pop should block until an item is available
+	go func() { done <- q.Push(context.Background(), "fourth") }()
+	v, err = q.Pop(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, "fourth", v)
+	require.NoError(t, <-done)
+
+	assert.Equal(t, backpressure.Stats{Capacity: 1, Pushed: 3, Popped: 3}, q.Stats())
+}
+
+func TestQueueDefaultCapacity(t *testing.T) {
+	for _, capacity := range []int{0, -1} {
+		q := backpressure.NewQueue(backpressure.QueueConfig[int]{Capacity: capacity})
+		assert.Equal(t, 100, q.Stats().Capacity)
+	}
+}
+
+func TestQueueClose(t *testing.T) {
+	q := backpressure.NewQueue(backpressure.QueueConfig[int]{})
+	require.NoError(t, q.Push(context.Background(), 1))
+
+	done := make(chan error)
+	blocked := backpressure.NewQueue(backpressure.QueueConfig[int]{})
+	go func() {
+		_, err := blocked.Pop(context.Background())
+		done <- err
+	}()
+	blocked.Close()
+	assert.ErrorIs(t, <-done, backpressure.ErrClosed)
+
+	q.Close()
+	assert.ErrorIs(t, q.Push(context.Background(), 2), backpressure.ErrClosed)
+	// Remaining items are still returned after close
+	v, err := q.Pop(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 1, v)
+	_, err = q.Pop(context.Background())
+	assert.ErrorIs(t, err, backpressure.ErrClosed)
+}
+
+func TestQueueWatermarks(t *testing.T) {
+	r := metrics.NewInMemory()
+	metrics.SetDefault(r)
+	defer metrics.SetDefault(nil)
+
+	var events []string
+	q := backpressure.NewQueue(backpressure.QueueConfig[int]{
+		Capacity:        10,
+		HighWatermark:   3,
+		LowWatermark:    1,
+		OnHighWatermark: func(depth int) { events = append(events, "high") },
+		OnLowWatermark:  func(depth int) { events = append(events, "low") },
+		MetricName:      "work_queue_depth",
+	})
+
+	ctx := context.Background()
+	for i := 0; i < 4; i++ {
+		require.NoError(t, q.Push(ctx, i))
+	}
+	assert.Equal(t, []string{"high"}, events)
+	assert.Equal(t, float64(4), r.Snapshot()[0].Value)
+
+	for i := 0; i < 3; i++ {
+		_, ok := q.TryPop()
+		require.True(t, ok)
+	}
+	assert.Equal(t, []string{"high", "low"}, events)
+	assert.Equal(t, float64(1), r.Snapshot()[0].Value)
+}
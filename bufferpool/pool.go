@@ -0,0 +1,99 @@
+// Package bufferpool provides a leveled pool of *bytes.Buffer backed by sync.Pool. Buffers are
+// grouped by capacity such that a request for a small buffer does not pin a large one, and buffers
+// which have grown beyond the maximum retained size are discarded rather than returned to the pool.
+package bufferpool
+
+import (
+	"bytes"
+	"sync"
+)
+
+const (
+	// DefaultMaxRetained is the maximum capacity of a buffer retained by the Default pool
+	DefaultMaxRetained = 64 * 1024
+	// minLevelSize is the capacity of buffers in the smallest level
+	minLevelSize = 256
+)
+
+// Default is the pool used by the package level Get() and Put() functions
+var Default = New(DefaultMaxRetained)
+
+// Pool is a leveled pool of buffers, it is safe for concurrent use
+type Pool struct {
+	levels      []sync.Pool
+	maxRetained int
+}
+
+// New creates a new Pool which retains buffers with a capacity up to maxRetained bytes.
+// Buffers are grouped into levels of power of two capacities, starting at 256 bytes.
+func New(maxRetained int) *Pool {
+	if maxRetained < minLevelSize {
+		maxRetained = minLevelSize
+	}
+
+	var count int
+	for size := minLevelSize; size <= maxRetained; size <<= 1 {
+		count++
+	}
+
+	p := &Pool{
+		levels:      make([]sync.Pool, count),
+		maxRetained: maxRetained,
+	}
+	for i := range p.levels {
+		size := minLevelSize << i
+		p.levels[i].New = func() any {
+			return bytes.NewBuffer(make([]byte, 0, size))
+		}
+	}
+	return p
+}
+
+// Get returns an empty buffer with a capacity of at least sizeHint bytes. If sizeHint is larger
+// than the maximum retained size, a new buffer is allocated which will not be retained by Put().
+func (p *Pool) Get(sizeHint int) *bytes.Buffer {
+	if sizeHint > p.maxRetained {
+		return bytes.NewBuffer(make([]byte, 0, sizeHint))
+	}
+	// Choose the smallest level which satisfies the size hint
+	level := 0
+	for size := minLevelSize; size < sizeHint; size <<= 1 {
+		level++
+	}
+	if level >= len(p.levels) {
+		level = len(p.levels) - 1
+	}
+
+	b := p.levels[level].Get().(*bytes.Buffer)
+	if b.Cap() < sizeHint {
+		b.Grow(sizeHint)
+	}
+	return b
+}
+
+// Put resets the buffer and returns it to the pool. Buffers which have grown larger than the
+// maximum retained size are discarded. The buffer must not be used after calling Put().
+func (p *Pool) Put(b *bytes.Buffer) {
+	if b == nil || b.Cap() > p.maxRetained || b.Cap() < minLevelSize {
+		return
+	}
+	b.Reset()
+
+	// Choose the largest level whose size the buffer can satisfy, such that Get() from
+	// a level always returns a buffer with at least the capacity of that level.
+	level := 0
+	for size := minLevelSize << 1; size <= b.Cap() && level < len(p.levels)-1; size <<= 1 {
+		level++
+	}
+	p.levels[level].Put(b)
+}
+
+// Get returns an empty buffer from the Default pool. See Pool.Get()
+func Get(sizeHint int) *bytes.Buffer {
+	return Default.Get(sizeHint)
+}
+
+// Put returns a buffer to the Default pool. See Pool.Put()
+func Put(b *bytes.Buffer) {
+	Default.Put(b)
+}
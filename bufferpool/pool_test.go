@@ -0,0 +1,78 @@
+package bufferpool_test
+
+import (
+	"bytes"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/kapetan-io/tackle/bufferpool"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPool(t *testing.T) {
+	p := bufferpool.New(4096)
+
+	b := p.Get(10)
+	assert.Equal(t, 0, b.Len())
+	assert.GreaterOrEqual(t, b.Cap(), 10)
+	b.WriteString("hello")
+	p.Put(b)
+
+	b = p.Get(1000)
+	assert.Equal(t, 0, b.Len())
+	assert.GreaterOrEqual(t, b.Cap(), 1000)
+	p.Put(b)
+
+	// Larger than max retained should still satisfy the size hint
+	b = p.Get(10_000)
+	assert.GreaterOrEqual(t, b.Cap(), 10_000)
+	p.Put(b)
+
+	// Buffers which have grown beyond the max must not be retained
+	b = p.Get(100)
+	b.WriteString(strings.Repeat("x", 8192))
+	p.Put(b)
+	for i := 0; i < 10; i++ {
+		assert.LessOrEqual(t, p.Get(100).Cap(), 4096)
+	}
+
+	// Should not panic
+	p.Put(nil)
+	p.Put(&bytes.Buffer{})
+}
+
+func TestDefault(t *testing.T) {
+	b := bufferpool.Get(bufferpool.DefaultMaxRetained)
+	assert.GreaterOrEqual(t, b.Cap(), bufferpool.DefaultMaxRetained)
+	bufferpool.Put(b)
+}
+
+var payload = []byte(strings.Repeat("time=12:00:00 level=INFO msg=\"hello world\" ", 10))
+
+func BenchmarkPool(b *testing.B) {
+	b.Run("bufferpool", func(b *testing.B) {
+		b.ReportAllocs()
+		b.RunParallel(func(pb *testing.PB) {
+			for pb.Next() {
+				buf := bufferpool.Get(len(payload))
+				buf.Write(payload)
+				bufferpool.Put(buf)
+			}
+		})
+	})
+
+	b.Run("new buffer", func(b *testing.B) {
+		b.ReportAllocs()
+		b.RunParallel(func(pb *testing.PB) {
+			for pb.Next() {
+				buf := &bytes.Buffer{}
+				buf.Write(payload)
+				// Ensure the buffer escapes to the heap as it would in real use
+				sink.Store(buf)
+			}
+		})
+	})
+}
+
+var sink atomic.Pointer[bytes.Buffer]
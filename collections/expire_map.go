@@ -0,0 +1,236 @@
+package collections
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/kapetan-io/tackle/clock"
+	"github.com/kapetan-io/tackle/wait"
+)
+
+type ExpireMapConfig struct {
+	// (Optional) The default TTL for entries added via Set() and GetOrLoad(). If zero, entries do not expire.
+	TTL time.Duration
+
+	// (Optional) If non-zero, a background go routine removes expired entries at this interval.
+	// Call Close() to stop the background go routine.
+	SweepInterval time.Duration
+}
+
+// ExpireMapStats is a snapshot of the ExpireMap statistics collected since the map was created
+type ExpireMapStats struct {
+	// Size is the number of entries in the map, including expired entries not yet swept
+	Size int
+	// Hits is the number of Get() and GetOrLoad() calls which found an unexpired entry
+	Hits int64
+	// Misses is the number of Get() and GetOrLoad() calls which did not find an unexpired entry
+	Misses int64
+	// Loads is the number of times a GetOrLoad() loader was called
+	Loads int64
+	// LoadErrors is the number of times a GetOrLoad() loader returned an error
+	LoadErrors int64
+	// Expired is the number of entries removed because their TTL elapsed
+	Expired int64
+}
+
+// HitRate returns the ratio of hits to total lookups, or zero if there have been no lookups
+func (s ExpireMapStats) HitRate() float64 {
+	total := s.Hits + s.Misses
+	if total == 0 {
+		return 0
+	}
+	return float64(s.Hits) / float64(total)
+}
+
+// ExpireMap is a thread safe map where each entry expires after a TTL. Unlike LRU, ExpireMap
+// has no capacity limit and so is suited to caching a bounded set of keys such as tokens or
+// DNS results. Expiry is calculated using the clock package.
+type ExpireMap[K comparable, V any] struct {
+	mutex    sync.Mutex
+	conf     ExpireMapConfig
+	items    map[K]expireEntry[V]
+	inflight map[K]*loadCall[V]
+	stats    ExpireMapStats
+	wg       wait.Group
+}
+
+type expireEntry[V any] struct {
+	value    V
+	expireAt time.Time
+}
+
+type loadCall[V any] struct {
+	done  chan struct{}
+	value V
+	err   error
+}
+
+// NewExpireMap creates a new ExpireMap using the config provided. If ExpireMapConfig.SweepInterval
+// is set, Close() must be called to stop the background sweep.
+func NewExpireMap[K comparable, V any](conf ExpireMapConfig) *ExpireMap[K, V] {
+	m := &ExpireMap[K, V]{
+		conf:     conf,
+		items:    make(map[K]expireEntry[V]),
+		inflight: make(map[K]*loadCall[V]),
+	}
+
+	if conf.SweepInterval > 0 {
+		ticker := clock.NewTicker(conf.SweepInterval)
+		m.wg.Until(func(done chan struct{}) bool {
+			select {
+			case <-ticker.C():
+				m.Sweep()
+			case <-done:
+				ticker.Stop()
+				return false
+			}
+			return true
+		})
+	}
+	return m
+}
+
+// Set adds a value to the map using the default TTL provided by ExpireMapConfig.TTL
+func (m *ExpireMap[K, V]) Set(key K, value V) {
+	m.SetWithTTL(key, value, m.conf.TTL)
+}
+
+// SetWithTTL adds a value to the map which expires after the TTL provided.
+// A TTL of zero means the entry never expires.
+func (m *ExpireMap[K, V]) SetWithTTL(key K, value V, ttl time.Duration) {
+	e := expireEntry[V]{value: value}
+	if ttl > 0 {
+		e.expireAt = clock.Now().Add(ttl)
+	}
+
+	m.mutex.Lock()
+	m.items[key] = e
+	m.mutex.Unlock()
+}
+
+// Get returns the value for the provided key, returns false if the key does not exist or has expired
+func (m *ExpireMap[K, V]) Get(key K) (V, bool) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	return m.get(key, clock.Now())
+}
+
+// ErrLoadPanicked is returned by GetOrLoad() to the callers waiting on a load which panicked.
+// The panic itself continues in the goroutine which called load.
+var ErrLoadPanicked = errors.New("collections: GetOrLoad load function panicked")
+
+// GetOrLoad returns the value for the provided key if it exists and has not expired, else it calls
+// the provided load function and stores the result using the default TTL. Concurrent calls to
+// GetOrLoad() for the same key share a single call to load. If load returns an error the error
+// is returned to all waiting callers and nothing is stored. If load panics, waiting callers
+// receive ErrLoadPanicked.
+func (m *ExpireMap[K, V]) GetOrLoad(key K, load func(key K) (V, error)) (V, error) {
+	m.mutex.Lock()
+	if v, ok := m.get(key, clock.Now()); ok {
+		m.mutex.Unlock()
+		return v, nil
+	}
+
+	if call, ok := m.inflight[key]; ok {
+		m.mutex.Unlock()
+		<-call.done
+		return call.value, call.err
+	}
+
+	call := &loadCall[V]{done: make(chan struct{})}
+	m.inflight[key] = call
+	m.stats.Loads++
+	m.mutex.Unlock()
+
+	var returned bool
+	defer func() {
+		if !returned {
+			call.err = ErrLoadPanicked
+		}
+
+		m.mutex.Lock()
+		delete(m.inflight, key)
+		if call.err != nil {
+			m.stats.LoadErrors++
+		} else {
+			e := expireEntry[V]{value: call.value}
+			if m.conf.TTL > 0 {
+				e.expireAt = clock.Now().Add(m.conf.TTL)
+			}
+			m.items[key] = e
+		}
+		m.mutex.Unlock()
+		close(call.done)
+	}()
+
+	call.value, call.err = load(key)
+	returned = true
+	return call.value, call.err
+}
+
+// Delete removes the provided key from the map
+func (m *ExpireMap[K, V]) Delete(key K) {
+	m.mutex.Lock()
+	delete(m.items, key)
+	m.mutex.Unlock()
+}
+
+// Len returns the number of entries in the map, including expired entries not yet swept
+func (m *ExpireMap[K, V]) Len() int {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	return len(m.items)
+}
+
+// Sweep removes all expired entries from the map and returns the number removed
+func (m *ExpireMap[K, V]) Sweep() int {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	var count int
+	now := clock.Now()
+	for k, e := range m.items {
+		if e.expired(now) {
+			delete(m.items, k)
+			count++
+		}
+	}
+	m.stats.Expired += int64(count)
+	return count
+}
+
+// Stats returns a snapshot of the current map statistics
+func (m *ExpireMap[K, V]) Stats() ExpireMapStats {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	s := m.stats
+	s.Size = len(m.items)
+	return s
+}
+
+// Close stops the background sweep go routine if ExpireMapConfig.SweepInterval was set
+func (m *ExpireMap[K, V]) Close() {
+	m.wg.Stop()
+}
+
+func (m *ExpireMap[K, V]) get(key K, now time.Time) (V, bool) {
+	var zero V
+	e, ok := m.items[key]
+	if !ok {
+		m.stats.Misses++
+		return zero, false
+	}
+	if e.expired(now) {
+		delete(m.items, key)
+		m.stats.Expired++
+		m.stats.Misses++
+		return zero, false
+	}
+	m.stats.Hits++
+	return e.value, true
+}
+
+func (e expireEntry[V]) expired(now time.Time) bool {
+	return !e.expireAt.IsZero() && !now.Before(e.expireAt)
+}
@@ -0,0 +1,149 @@
+package collections_test
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/kapetan-io/tackle/clock"
+	"github.com/kapetan-io/tackle/collections"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExpireMap(t *testing.T) {
+	defer clock.Freeze(clock.Now()).UnFreeze()
+
+	m := collections.NewExpireMap[string, string](collections.ExpireMapConfig{TTL: clock.Minute})
+	defer m.Close()
+
+	m.Set("token", "abc")
+	m.SetWithTTL("dns", "10.0.0.1", clock.Second)
+
+	v, ok := m.Get("token")
+	require.True(t, ok)
+	assert.Equal(t, "abc", v)
+
+	clock.Advance(clock.Second)
+	_, ok = m.Get("dns")
+	assert.False(t, ok)
+	_, ok = m.Get("none")
+	assert.False(t, ok)
+
+	clock.Advance(clock.Minute)
+	assert.Equal(t, 1, m.Sweep())
+	assert.Equal(t, 0, m.Len())
+
+	s := m.Stats()
+	assert.Equal(t, collections.ExpireMapStats{Hits: 1, Misses: 2, Expired: 2}, s)
+	assert.InDelta(t, 0.333, s.HitRate(), 0.001)
+}
+
+func TestExpireMapGetOrLoad(t *testing.T) {
+	m := collections.NewExpireMap[string, int](collections.ExpireMapConfig{})
+
+	var calls int32
+	release := make(chan struct{})
+	load := func(key string) (int, error) {
+		atomic.AddInt32(&calls, 1)
+		<-release
+		return 42, nil
+	}
+
+	var wg sync.WaitGroup
+	results := make(chan int, 10)
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			v, err := m.GetOrLoad("key", load)
+			assert.NoError(t, err)
+			results <- v
+		}()
+	}
+
+	// Wait for the first load to begin before releasing it
+	require.Eventually(t, func() bool { return atomic.LoadInt32(&calls) == 1 },
+		clock.Second, clock.Millisecond)
+	close(release)
+	wg.Wait()
+	close(results)
+
+	for v := range results {
+		assert.Equal(t, 42, v)
+	}
+	assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+	assert.Equal(t, int64(1), m.Stats().Loads)
+
+	// Errors are returned but not stored
+	_, err := m.GetOrLoad("fail", func(string) (int, error) {
+		return 0, errors.New("load failed")
+	})
+	require.EqualError(t, err, "load failed")
+	_, ok := m.Get("fail")
+	assert.False(t, ok)
+	assert.Equal(t, int64(1), m.Stats().LoadErrors)
+}
+
+func TestExpireMapGetOrLoadPanic(t *testing.T) {
+	m := collections.NewExpireMap[string, int](collections.ExpireMapConfig{})
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	panicked := make(chan any, 1)
+	go func() {
+		defer func() { panicked <- recover() }()
+		_, _ = m.GetOrLoad("key", func(string) (int, error) {
+			close(started)
+			<-release
+			panic("load exploded")
+		})
+	}()
+	<-started
+
+	// A caller waiting on the load should be released with an error rather than block forever
+	waiter := make(chan error, 1)
+	go func() {
+		_, err := m.GetOrLoad("key", func(string) (int, error) { return 1, nil })
+		waiter <- err
+	}()
+	// Give the waiter time to block on the load in progress
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+
+	assert.Equal(t, "load exploded", <-panicked)
+	select {
+	case err := <-waiter:
+		// The waiter either shared the panicked load, or started a new load once it was cleaned up
+		if err != nil {
+			assert.ErrorIs(t, err, collections.ErrLoadPanicked)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("GetOrLoad() blocked after a load panicked")
+	}
+
+	// The key can be loaded again
+	v, err := m.GetOrLoad("key", func(string) (int, error) { return 42, nil })
+	require.NoError(t, err)
+	assert.Equal(t, 42, v)
+}
+
+func TestExpireMapSweepInterval(t *testing.T) {
+	defer clock.Freeze(clock.Now()).UnFreeze()
+
+	m := collections.NewExpireMap[string, int](collections.ExpireMapConfig{
+		TTL:           clock.Second,
+		SweepInterval: clock.Minute,
+	})
+	defer m.Close()
+
+	m.Set("one", 1)
+	m.Set("two", 2)
+	assert.Equal(t, 2, m.Len())
+
+	clock.Advance(clock.Minute)
+	require.Eventually(t, func() bool { return m.Len() == 0 }, clock.Second, clock.Millisecond)
+	assert.Equal(t, int64(2), m.Stats().Expired)
+}
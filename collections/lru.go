@@ -0,0 +1,261 @@
+// Package collections provides generic data structures which are safe for concurrent use.
+// Where time is involved, the clock package is used such that tests can freeze and
+// advance time via clock.Freeze() and clock.Advance().
+package collections
+
+import (
+	"container/list"
+	"sync"
+	"time"
+
+	"github.com/kapetan-io/tackle/clock"
+)
+
+// EvictReason describes why an entry was removed from the cache
+type EvictReason int
+
+const (
+	// EvictCapacity indicates the entry was the least recently used when the cache was full
+	EvictCapacity EvictReason = iota
+	// EvictExpired indicates the entry TTL elapsed
+	EvictExpired
+	// EvictRemoved indicates the entry was removed via Remove() or Purge()
+	EvictRemoved
+)
+
+func (r EvictReason) String() string {
+	switch r {
+	case EvictCapacity:
+		return "capacity"
+	case EvictExpired:
+		return "expired"
+	case EvictRemoved:
+		return "removed"
+	}
+	return "unknown"
+}
+
+type LRUConfig[K comparable, V any] struct {
+	// (Optional) The maximum number of entries the cache will hold before evicting the
+	// least recently used entry. If zero, the cache is unbounded.
+	Capacity int
+
+	// (Optional) The default TTL for entries added via Add(). If zero, entries do not expire.
+	TTL time.Duration
+
+	// (Optional) OnEvict is called each time an entry is removed from the cache. It is
+	// called after the cache lock is released, so it is safe to call the cache from OnEvict.
+	OnEvict func(key K, value V, reason EvictReason)
+}
+
+// LRU is a thread safe least recently used cache with optional per entry TTL. Expiry
+// is calculated using the clock package, such that tests can use clock.Freeze() and
+// clock.Advance() to expire entries.
+type LRU[K comparable, V any] struct {
+	mutex sync.Mutex
+	conf  LRUConfig[K, V]
+	ll    *list.List
+	items map[K]*list.Element
+}
+
+type lruEntry[K comparable, V any] struct {
+	key      K
+	value    V
+	expireAt time.Time
+}
+
+func (e *lruEntry[K, V]) expired(now time.Time) bool {
+	return !e.expireAt.IsZero() && !now.Before(e.expireAt)
+}
+
+type eviction[K comparable, V any] struct {
+	key    K
+	value  V
+	reason EvictReason
+}
+
+// NewLRU creates a new LRU cache using the config provided
+func NewLRU[K comparable, V any](conf LRUConfig[K, V]) *LRU[K, V] {
+	return &LRU[K, V]{
+		conf:  conf,
+		ll:    list.New(),
+		items: make(map[K]*list.Element),
+	}
+}
+
+// Add adds a value to the cache using the default TTL provided by LRUConfig.TTL.
+// Returns true if an existing entry was replaced.
+func (c *LRU[K, V]) Add(key K, value V) bool {
+	return c.AddWithTTL(key, value, c.conf.TTL)
+}
+
+// AddWithTTL adds a value to the cache which will expire after the provided TTL.
+// A TTL of zero means the entry never expires. Returns true if an existing entry
+// was replaced.
+func (c *LRU[K, V]) AddWithTTL(key K, value V, ttl time.Duration) bool {
+	var expireAt time.Time
+	if ttl > 0 {
+		expireAt = clock.Now().Add(ttl)
+	}
+
+	var evicted []eviction[K, V]
+	defer func() { c.notify(evicted) }()
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if ele, ok := c.items[key]; ok {
+		c.ll.MoveToFront(ele)
+		e := ele.Value.(*lruEntry[K, V])
+		e.value = value
+		e.expireAt = expireAt
+		return true
+	}
+
+	c.items[key] = c.ll.PushFront(&lruEntry[K, V]{key: key, value: value, expireAt: expireAt})
+
+	if c.conf.Capacity > 0 && c.ll.Len() > c.conf.Capacity {
+		if e := c.removeOldest(EvictCapacity); e != nil {
+			evicted = append(evicted, *e)
+		}
+	}
+	return false
+}
+
+// Get returns the value for the provided key and marks the entry as recently used.
+// Returns false if the key does not exist or the entry has expired.
+func (c *LRU[K, V]) Get(key K) (V, bool) {
+	var evicted []eviction[K, V]
+	defer func() { c.notify(evicted) }()
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	var zero V
+	ele, ok := c.items[key]
+	if !ok {
+		return zero, false
+	}
+
+	e := ele.Value.(*lruEntry[K, V])
+	if e.expired(clock.Now()) {
+		evicted = append(evicted, c.removeElement(ele, EvictExpired))
+		return zero, false
+	}
+	c.ll.MoveToFront(ele)
+	return e.value, true
+}
+
+// Peek returns the value for the provided key without marking the entry as recently used.
+// Returns false if the key does not exist or the entry has expired.
+func (c *LRU[K, V]) Peek(key K) (V, bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	var zero V
+	ele, ok := c.items[key]
+	if !ok {
+		return zero, false
+	}
+
+	e := ele.Value.(*lruEntry[K, V])
+	if e.expired(clock.Now()) {
+		return zero, false
+	}
+	return e.value, true
+}
+
+// Remove removes the provided key from the cache, returns true if the key was found
+func (c *LRU[K, V]) Remove(key K) bool {
+	var evicted []eviction[K, V]
+	defer func() { c.notify(evicted) }()
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	ele, ok := c.items[key]
+	if !ok {
+		return false
+	}
+	evicted = append(evicted, c.removeElement(ele, EvictRemoved))
+	return true
+}
+
+// RemoveExpired removes all expired entries from the cache and returns the number removed.
+// Expired entries are otherwise only removed when accessed or when evicted due to capacity.
+func (c *LRU[K, V]) RemoveExpired() int {
+	var evicted []eviction[K, V]
+	defer func() { c.notify(evicted) }()
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	now := clock.Now()
+	for ele := c.ll.Back(); ele != nil; {
+		prev := ele.Prev()
+		if ele.Value.(*lruEntry[K, V]).expired(now) {
+			evicted = append(evicted, c.removeElement(ele, EvictExpired))
+		}
+		ele = prev
+	}
+	return len(evicted)
+}
+
+// Keys returns the keys currently in the cache ordered from most to least recently used.
+// The result may include entries which have expired but have not yet been removed.
+func (c *LRU[K, V]) Keys() []K {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	keys := make([]K, 0, c.ll.Len())
+	for ele := c.ll.Front(); ele != nil; ele = ele.Next() {
+		keys = append(keys, ele.Value.(*lruEntry[K, V]).key)
+	}
+	return keys
+}
+
+// Len returns the number of entries in the cache. The result may include
+// entries which have expired but have not yet been removed.
+func (c *LRU[K, V]) Len() int {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.ll.Len()
+}
+
+// Purge removes all entries from the cache
+func (c *LRU[K, V]) Purge() {
+	var evicted []eviction[K, V]
+	defer func() { c.notify(evicted) }()
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	for ele := c.ll.Back(); ele != nil; ele = c.ll.Back() {
+		evicted = append(evicted, c.removeElement(ele, EvictRemoved))
+	}
+}
+
+func (c *LRU[K, V]) removeOldest(reason EvictReason) *eviction[K, V] {
+	ele := c.ll.Back()
+	if ele == nil {
+		return nil
+	}
+	e := c.removeElement(ele, reason)
+	return &e
+}
+
+func (c *LRU[K, V]) removeElement(ele *list.Element, reason EvictReason) eviction[K, V] {
+	c.ll.Remove(ele)
+	e := ele.Value.(*lruEntry[K, V])
+	delete(c.items, e.key)
+	return eviction[K, V]{key: e.key, value: e.value, reason: reason}
+}
+
+func (c *LRU[K, V]) notify(evicted []eviction[K, V]) {
+	if c.conf.OnEvict == nil {
+		return
+	}
+	for _, e := range evicted {
+		c.conf.OnEvict(e.key, e.value, e.reason)
+	}
+}
@@ -0,0 +1,95 @@
+package collections_test
+
+import (
+	"testing"
+
+	"github.com/kapetan-io/tackle/clock"
+	"github.com/kapetan-io/tackle/collections"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLRUCapacity(t *testing.T) {
+	type evicted struct {
+		key    string
+		reason collections.EvictReason
+	}
+	var evictions []evicted
+
+	c := collections.NewLRU(collections.LRUConfig[string, int]{
+		Capacity: 2,
+		OnEvict: func(key string, value int, reason collections.EvictReason) {
+			evictions = append(evictions, evicted{key: key, reason: reason})
+		},
+	})
+
+	assert.False(t, c.Add("one", 1))
+	assert.False(t, c.Add("two", 2))
+
+	// Access "one" so "two" becomes the least recently used
+	v, ok := c.Get("one")
+	require.True(t, ok)
+	assert.Equal(t, 1, v)
+
+	assert.False(t, c.Add("three", 3))
+	assert.Equal(t, 2, c.Len())
+	assert.Equal(t, []string{"three", "one"}, c.Keys())
+
+	_, ok = c.Get("two")
+	assert.False(t, ok)
+	require.Len(t, evictions, 1)
+	assert.Equal(t, evicted{key: "two", reason: collections.EvictCapacity}, evictions[0])
+
+	// Replacing an existing key should not evict
+	assert.True(t, c.Add("one", 10))
+	v, ok = c.Peek("one")
+	require.True(t, ok)
+	assert.Equal(t, 10, v)
+	assert.Len(t, evictions, 1)
+
+	assert.True(t, c.Remove("one"))
+	assert.False(t, c.Remove("one"))
+	c.Purge()
+	assert.Equal(t, 0, c.Len())
+	assert.Equal(t, []evicted{
+		{key: "two", reason: collections.EvictCapacity},
+		{key: "one", reason: collections.EvictRemoved},
+		{key: "three", reason: collections.EvictRemoved},
+	}, evictions)
+}
+
+func TestLRUTTL(t *testing.T) {
+	defer clock.Freeze(clock.Now()).UnFreeze()
+
+	var expired []string
+	c := collections.NewLRU(collections.LRUConfig[string, string]{
+		TTL: clock.Minute,
+		OnEvict: func(key string, value string, reason collections.EvictReason) {
+			if reason == collections.EvictExpired {
+				expired = append(expired, key)
+			}
+		},
+	})
+
+	c.Add("default", "a")
+	c.AddWithTTL("short", "b", clock.Second)
+	c.AddWithTTL("forever", "c", 0)
+
+	clock.Advance(clock.Second)
+	_, ok := c.Get("short")
+	assert.False(t, ok)
+	v, ok := c.Get("default")
+	require.True(t, ok)
+	assert.Equal(t, "a", v)
+
+	clock.Advance(clock.Minute)
+	_, ok = c.Peek("default")
+	assert.False(t, ok)
+	assert.Equal(t, 1, c.RemoveExpired())
+	assert.Equal(t, []string{"short", "default"}, expired)
+
+	v, ok = c.Get("forever")
+	require.True(t, ok)
+	assert.Equal(t, "c", v)
+	assert.Equal(t, 1, c.Len())
+}
@@ -0,0 +1,179 @@
+package collections
+
+import (
+	"container/heap"
+	"context"
+	"sync"
+	"time"
+
+	"github.com/kapetan-io/tackle/clock"
+)
+
+// PriorityQueue is a thread safe heap backed queue which returns items in the order
+// determined by the less function provided to NewPriorityQueue()
+type PriorityQueue[T any] struct {
+	mutex sync.Mutex
+	h     *itemHeap[T]
+}
+
+// NewPriorityQueue creates a new PriorityQueue. Pop() returns the item for which less
+// reports true when compared to all other items in the queue.
+//
+//	// Creates a min queue where Pop() returns the smallest int
+//	q := collections.NewPriorityQueue(func(a, b int) bool { return a < b })
+func NewPriorityQueue[T any](less func(a, b T) bool) *PriorityQueue[T] {
+	return &PriorityQueue[T]{h: &itemHeap[T]{less: less}}
+}
+
+// Push adds an item to the queue
+func (q *PriorityQueue[T]) Push(item T) {
+	q.mutex.Lock()
+	heap.Push(q.h, item)
+	q.mutex.Unlock()
+}
+
+// Pop removes and returns the highest priority item, returns false if the queue is empty
+func (q *PriorityQueue[T]) Pop() (T, bool) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	if q.h.Len() == 0 {
+		var zero T
+		return zero, false
+	}
+	return heap.Pop(q.h).(T), true
+}
+
+// Peek returns the highest priority item without removing it, returns false if the queue is empty
+func (q *PriorityQueue[T]) Peek() (T, bool) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	if q.h.Len() == 0 {
+		var zero T
+		return zero, false
+	}
+	return q.h.items[0], true
+}
+
+// Len returns the number of items in the queue
+func (q *PriorityQueue[T]) Len() int {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	return q.h.Len()
+}
+
+// itemHeap implements heap.Interface
+type itemHeap[T any] struct {
+	items []T
+	less  func(a, b T) bool
+}
+
+func (h *itemHeap[T]) Len() int           { return len(h.items) }
+func (h *itemHeap[T]) Less(i, j int) bool { return h.less(h.items[i], h.items[j]) }
+func (h *itemHeap[T]) Swap(i, j int)      { h.items[i], h.items[j] = h.items[j], h.items[i] }
+func (h *itemHeap[T]) Push(x any)         { h.items = append(h.items, x.(T)) }
+
+func (h *itemHeap[T]) Pop() any {
+	n := len(h.items) - 1
+	item := h.items[n]
+	var zero T
+	h.items[n] = zero
+	h.items = h.items[:n]
+	return item
+}
+
+// DelayQueue is a thread safe queue where items may only be removed once their deadline
+// has passed. Items are returned in deadline order. Deadlines are evaluated using the
+// clock package, such that tests can use clock.Freeze() and clock.Advance().
+type DelayQueue[T any] struct {
+	mutex sync.Mutex
+	h     *itemHeap[delayItem[T]]
+	wake  chan struct{}
+}
+
+type delayItem[T any] struct {
+	value    T
+	deadline time.Time
+}
+
+// NewDelayQueue creates a new empty DelayQueue
+func NewDelayQueue[T any]() *DelayQueue[T] {
+	return &DelayQueue[T]{
+		h: &itemHeap[delayItem[T]]{less: func(a, b delayItem[T]) bool {
+			return a.deadline.Before(b.deadline)
+		}},
+		wake: make(chan struct{}),
+	}
+}
+
+// Push adds an item which becomes available at the deadline provided
+func (q *DelayQueue[T]) Push(item T, deadline time.Time) {
+	q.mutex.Lock()
+	heap.Push(q.h, delayItem[T]{value: item, deadline: deadline})
+	// Wake any waiting Pop() calls, as the earliest deadline may have changed
+	close(q.wake)
+	q.wake = make(chan struct{})
+	q.mutex.Unlock()
+}
+
+// PushAfter adds an item which becomes available after the duration provided
+func (q *DelayQueue[T]) PushAfter(item T, d time.Duration) {
+	q.Push(item, clock.Now().Add(d))
+}
+
+// Pop blocks until the item with the earliest deadline is due and returns it. Returns
+// ctx.Err() if the context is cancelled before an item becomes available.
+func (q *DelayQueue[T]) Pop(ctx context.Context) (T, error) {
+	var zero T
+	for {
+		q.mutex.Lock()
+		wake := q.wake
+		if q.h.Len() == 0 {
+			q.mutex.Unlock()
+			select {
+			case <-wake:
+				continue
+			case <-ctx.Done():
+				return zero, ctx.Err()
+			}
+		}
+
+		wait := q.h.items[0].deadline.Sub(clock.Now())
+		if wait <= 0 {
+			item := heap.Pop(q.h).(delayItem[T])
+			q.mutex.Unlock()
+			return item.value, nil
+		}
+		q.mutex.Unlock()
+
+		timer := clock.NewTimer(wait)
+		select {
+		case <-timer.C():
+		case <-wake:
+			timer.Stop()
+		case <-ctx.Done():
+			timer.Stop()
+			return zero, ctx.Err()
+		}
+	}
+}
+
+// TryPop returns the item with the earliest deadline if it is due, else returns false without blocking
+func (q *DelayQueue[T]) TryPop() (T, bool) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	if q.h.Len() == 0 || q.h.items[0].deadline.After(clock.Now()) {
+		var zero T
+		return zero, false
+	}
+	return heap.Pop(q.h).(delayItem[T]).value, true
+}
+
+// Len returns the number of items in the queue, including items which are not yet due
+func (q *DelayQueue[T]) Len() int {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	return q.h.Len()
+}
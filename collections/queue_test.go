@@ -0,0 +1,94 @@
+package collections_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/kapetan-io/tackle/clock"
+	"github.com/kapetan-io/tackle/collections"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPriorityQueue(t *testing.T) {
+	type job struct {
+		name     string
+		priority int
+	}
+	q := collections.NewPriorityQueue(func(a, b job) bool { return a.priority > b.priority })
+
+	_, ok := q.Pop()
+	assert.False(t, ok)
+
+	q.Push(job{name: "low", priority: 1})
+	q.Push(job{name: "high", priority: 10})
+	q.Push(job{name: "medium", priority: 5})
+	assert.Equal(t, 3, q.Len())
+
+	j, ok := q.Peek()
+	require.True(t, ok)
+	assert.Equal(t, "high", j.name)
+
+	var names []string
+	for j, ok := q.Pop(); ok; j, ok = q.Pop() {
+		names = append(names, j.name)
+	}
+	assert.Equal(t, []string{"high", "medium", "low"}, names)
+	assert.Equal(t, 0, q.Len())
+}
+
+func TestDelayQueue(t *testing.T) {
+	defer clock.Freeze(clock.Now()).UnFreeze()
+
+	q := collections.NewDelayQueue[string]()
+	q.PushAfter("second", 2*clock.Second)
+	q.PushAfter("first", clock.Second)
+
+	_, ok := q.TryPop()
+	assert.False(t, ok)
+
+	result := make(chan string)
+	go func() {
+		v, err := q.Pop(context.Background())
+		assert.NoError(t, err)
+		result <- v
+	}()
+
+	// Wait for Pop() to schedule a timer for the earliest deadline
+	require.True(t, clock.Wait4Scheduled(1, clock.Second))
+	clock.Advance(clock.Second)
+	assert.Equal(t, "first", <-result)
+
+	clock.Advance(clock.Second)
+	v, ok := q.TryPop()
+	require.True(t, ok)
+	assert.Equal(t, "second", v)
+	assert.Equal(t, 0, q.Len())
+}
+
+func TestDelayQueuePushWakesPop(t *testing.T) {
+	defer clock.Freeze(clock.Now()).UnFreeze()
+
+	q := collections.NewDelayQueue[string]()
+	result := make(chan string)
+	go func() {
+		v, err := q.Pop(context.Background())
+		assert.NoError(t, err)
+		result <- v
+	}()
+
+	// An item which is already due should be returned by a blocked Pop()
+	q.Push("now", clock.Now())
+	assert.Equal(t, "now", <-result)
+}
+
+func TestDelayQueueCancel(t *testing.T) {
+	q := collections.NewDelayQueue[int]()
+	q.PushAfter(1, clock.Hour)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*clock.Millisecond)
+	defer cancel()
+	_, err := q.Pop(ctx)
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Equal(t, 1, q.Len())
+}
@@ -5,12 +5,12 @@ import (
 	"bytes"
 	"context"
 	"fmt"
+	"github.com/kapetan-io/tackle/bufferpool"
 	"github.com/kapetan-io/tackle/set"
 	"io"
 	"log/slog"
 	"os"
 	"slices"
-	"strings"
 	"sync"
 )
 
@@ -231,7 +231,8 @@ func (h *Handler) Handle(ctx context.Context, r slog.Record) error {
 		return err
 	}
 
-	out := strings.Builder{}
+	out := bufferpool.Get(len(timestamp) + len(level) + len(msg) + len(attrs) + 3)
+	defer bufferpool.Put(out)
 	if len(timestamp) > 0 {
 		out.WriteString(timestamp)
 		out.WriteString(" ")
@@ -248,7 +249,7 @@ func (h *Handler) Handle(ctx context.Context, r slog.Record) error {
 		out.WriteString(h.opts.ColorFunc(FgHiBlack, attrs))
 	}
 
-	_, err = io.WriteString(h.opts.Writer, out.String())
+	_, err = h.opts.Writer.Write(out.Bytes())
 	if err != nil {
 		return err
 	}
@@ -8,6 +8,7 @@ import (
 	"github.com/kapetan-io/tackle/color"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"io"
 	"log/slog"
 	"regexp"
 	"testing"
@@ -92,3 +93,15 @@ func TestColor(t *testing.T) {
 	log.Log(context.Background(), slog.LevelError+2, "This is a error+2", "attr1", 2319, "attr2", "foo")
 
 }
+
+func BenchmarkHandler(b *testing.B) {
+	log := slog.New(color.NewLog(&color.LogOptions{
+		Writer: io.Discard,
+	}))
+	b.ReportAllocs()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			log.Info("This is a benchmark", "attr1", 2319, "attr2", "foo")
+		}
+	})
+}
@@ -0,0 +1,82 @@
+// Package ctxutil provides context primitives which are missing from the standard context package.
+package ctxutil
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Detach returns a context which retains all the values of the parent, but is never
+// cancelled and has no deadline. This is useful for async work which must outlive
+// the request which started it, while still retaining request scoped values such as
+// trace ids or loggers.
+//
+//	func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+//		// Audit logging continues after the response is sent
+//		go h.audit(ctxutil.Detach(r.Context()), r)
+//	}
+func Detach(ctx context.Context) context.Context {
+	return context.WithoutCancel(ctx)
+}
+
+// Merge returns a context which is cancelled when either of the provided contexts is cancelled,
+// or when the returned cancel function is called. Values are looked up in 'a' first, then
+// in 'b'. The deadline of the merged context is the earliest deadline of the two contexts.
+// Err() returns the error of whichever context was cancelled first.
+func Merge(a, b context.Context) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancelCause(a)
+	m := &mergedCtx{Context: ctx, other: b}
+
+	stop := context.AfterFunc(b, func() {
+		m.mutex.Lock()
+		defer m.mutex.Unlock()
+		if ctx.Err() == nil {
+			m.err = b.Err()
+			cancel(context.Cause(b))
+		}
+	})
+
+	return m, func() {
+		stop()
+		cancel(context.Canceled)
+	}
+}
+
+type mergedCtx struct {
+	context.Context
+	other context.Context
+	mutex sync.Mutex
+	err   error
+}
+
+func (m *mergedCtx) Deadline() (time.Time, bool) {
+	d1, ok1 := m.Context.Deadline()
+	d2, ok2 := m.other.Deadline()
+	switch {
+	case ok1 && ok2:
+		if d2.Before(d1) {
+			return d2, true
+		}
+		return d1, true
+	case ok2:
+		return d2, true
+	}
+	return d1, ok1
+}
+
+func (m *mergedCtx) Err() error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if m.err != nil {
+		return m.err
+	}
+	return m.Context.Err()
+}
+
+func (m *mergedCtx) Value(key any) any {
+	if v := m.Context.Value(key); v != nil {
+		return v
+	}
+	return m.other.Value(key)
+}
@@ -0,0 +1,87 @@
+package ctxutil_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/kapetan-io/tackle/ctxutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type key string
+
+func TestDetach(t *testing.T) {
+	parent, cancel := context.WithTimeout(context.WithValue(context.Background(), key("id"), "abc"), time.Minute)
+	ctx := ctxutil.Detach(parent)
+	cancel()
+
+	require.Error(t, parent.Err())
+	assert.NoError(t, ctx.Err())
+	assert.Nil(t, ctx.Done())
+	assert.Equal(t, "abc", ctx.Value(key("id")))
+	_, ok := ctx.Deadline()
+	assert.False(t, ok)
+}
+
+func TestMerge(t *testing.T) {
+	t.Run("first cancelled", func(t *testing.T) {
+		a, cancelA := context.WithCancel(context.Background())
+		ctx, cancel := ctxutil.Merge(a, context.Background())
+		defer cancel()
+
+		cancelA()
+		<-ctx.Done()
+		assert.ErrorIs(t, ctx.Err(), context.Canceled)
+	})
+
+	t.Run("second deadline exceeded", func(t *testing.T) {
+		b, cancelB := context.WithTimeout(context.Background(), time.Millisecond)
+		defer cancelB()
+		ctx, cancel := ctxutil.Merge(context.Background(), b)
+		defer cancel()
+
+		select {
+		case <-ctx.Done():
+		case <-time.After(time.Second):
+			t.Fatal("merged context was not cancelled")
+		}
+		assert.ErrorIs(t, ctx.Err(), context.DeadlineExceeded)
+	})
+
+	t.Run("cause propagated", func(t *testing.T) {
+		b, cancelB := context.WithCancelCause(context.Background())
+		ctx, cancel := ctxutil.Merge(context.Background(), b)
+		defer cancel()
+
+		cancelB(errors.New("shutdown"))
+		<-ctx.Done()
+		assert.EqualError(t, context.Cause(ctx), "shutdown")
+	})
+
+	t.Run("cancel func", func(t *testing.T) {
+		ctx, cancel := ctxutil.Merge(context.Background(), context.Background())
+		cancel()
+		<-ctx.Done()
+		assert.ErrorIs(t, ctx.Err(), context.Canceled)
+	})
+
+	t.Run("values and deadline", func(t *testing.T) {
+		now := time.Now()
+		a, cancelA := context.WithDeadline(context.WithValue(context.Background(), key("a"), "from-a"), now.Add(time.Hour))
+		defer cancelA()
+		b, cancelB := context.WithDeadline(context.WithValue(context.Background(), key("b"), "from-b"), now.Add(time.Minute))
+		defer cancelB()
+
+		ctx, cancel := ctxutil.Merge(a, b)
+		defer cancel()
+
+		assert.Equal(t, "from-a", ctx.Value(key("a")))
+		assert.Equal(t, "from-b", ctx.Value(key("b")))
+		d, ok := ctx.Deadline()
+		require.True(t, ok)
+		assert.Equal(t, now.Add(time.Minute), d)
+	})
+}
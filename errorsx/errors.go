@@ -0,0 +1,131 @@
+// Package errorsx provides errors which carry structured fields and a stack trace from the failure site
+// to the log line. Errors created by this package implement slog.LogValuer such that logging the error
+// as an attribute renders the message along with all fields attached throughout the error chain.
+//
+//	err := errorsx.Wrap(err, "while fetching user", "user_id", id)
+//	log.Error("request failed", "err", err)
+//	// request failed err.msg="while fetching user: connection refused" err.user_id=123 err.source=...
+package errorsx
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"runtime"
+)
+
+const maxStackDepth = 32
+
+// Error is an error with structured fields and the stack captured when it was created
+type Error struct {
+	msg   string
+	err   error
+	attrs []slog.Attr
+	stack []uintptr
+}
+
+// New returns an error with the provided message and fields. Fields are provided as alternating
+// key/value pairs or slog.Attr using the same rules as slog.Logger.Info()
+func New(msg string, kv ...any) error {
+	return newError(msg, nil, kv)
+}
+
+// Wrap returns an error which wraps 'err' with the provided message and fields.
+// Returns nil if 'err' is nil.
+func Wrap(err error, msg string, kv ...any) error {
+	if err == nil {
+		return nil
+	}
+	return newError(msg, err, kv)
+}
+
+// With returns an error which wraps 'err' with the provided fields but no additional message.
+// Returns nil if 'err' is nil.
+func With(err error, kv ...any) error {
+	if err == nil {
+		return nil
+	}
+	return newError("", err, kv)
+}
+
+func newError(msg string, err error, kv []any) *Error {
+	e := &Error{
+		msg: msg,
+		err: err,
+		// slog.Group() is used to parse key/value pairs consistently with slog
+		attrs: slog.Group("", kv...).Value.Group(),
+	}
+	var pcs [maxStackDepth]uintptr
+	// Skip runtime.Callers, newError and the exported function which called newError
+	n := runtime.Callers(3, pcs[:])
+	e.stack = pcs[:n]
+	return e
+}
+
+func (e *Error) Error() string {
+	switch {
+	case e.err == nil:
+		return e.msg
+	case e.msg == "":
+		return e.err.Error()
+	}
+	return fmt.Sprintf("%s: %s", e.msg, e.err.Error())
+}
+
+func (e *Error) Unwrap() error {
+	return e.err
+}
+
+// LogValue implements slog.LogValuer. The returned group contains the error message, all fields
+// found in the error chain and the source location where the innermost Error was created.
+func (e *Error) LogValue() slog.Value {
+	attrs := []slog.Attr{slog.String("message", e.Error())}
+	attrs = append(attrs, Fields(e)...)
+	if frames := Stack(e); len(frames) != 0 {
+		attrs = append(attrs, slog.String("source", fmt.Sprintf("%s:%d", frames[0].File, frames[0].Line)))
+	}
+	return slog.GroupValue(attrs...)
+}
+
+// Fields returns all the fields attached to errors in the chain, ordered from the outermost
+// error to the innermost. Returns nil if no errors in the chain have fields.
+func Fields(err error) []slog.Attr {
+	var result []slog.Attr
+	for err != nil {
+		var e *Error
+		if !errors.As(err, &e) {
+			break
+		}
+		result = append(result, e.attrs...)
+		err = e.err
+	}
+	return result
+}
+
+// Stack returns the stack frames captured by the innermost Error in the chain, which
+// is the stack closest to the original failure site. Returns nil if no Error is found.
+func Stack(err error) []runtime.Frame {
+	var stack []uintptr
+	for err != nil {
+		var e *Error
+		if !errors.As(err, &e) {
+			break
+		}
+		stack = e.stack
+		err = e.err
+	}
+	if len(stack) == 0 {
+		return nil
+	}
+
+	var result []runtime.Frame
+	frames := runtime.CallersFrames(stack)
+	for {
+		frame, more := frames.Next()
+		result = append(result, frame)
+		if !more {
+			break
+		}
+	}
+	return result
+}
@@ -0,0 +1,68 @@
+package errorsx_test
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/kapetan-io/tackle/color"
+	"github.com/kapetan-io/tackle/errorsx"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWrap(t *testing.T) {
+	assert.Nil(t, errorsx.Wrap(nil, "nothing"))
+	assert.Nil(t, errorsx.With(nil, "key", "value"))
+
+	base := errorsx.New("connection refused", "addr", "10.0.0.1:80")
+	err := errorsx.Wrap(base, "while fetching user", "user_id", 123)
+	err = fmt.Errorf("handler: %w", errorsx.With(err, slog.String("path", "/users")))
+
+	assert.EqualError(t, err, "handler: while fetching user: connection refused")
+	assert.True(t, errors.Is(err, base))
+	assert.Equal(t, []slog.Attr{
+		slog.String("path", "/users"),
+		slog.Int("user_id", 123),
+		slog.String("addr", "10.0.0.1:80"),
+	}, errorsx.Fields(err))
+
+	assert.Nil(t, errorsx.Fields(io.EOF))
+	assert.Nil(t, errorsx.Stack(io.EOF))
+}
+
+func TestStack(t *testing.T) {
+	err := errorsx.Wrap(failure(), "outer")
+	frames := errorsx.Stack(err)
+	require.NotEmpty(t, frames)
+	// The innermost stack should point to the original failure site
+	assert.True(t, strings.HasSuffix(frames[0].Function, "errorsx_test.failure"), frames[0].Function)
+}
+
+func failure() error {
+	return errorsx.New("failure")
+}
+
+func TestLogValue(t *testing.T) {
+	var buf bytes.Buffer
+	w := bufio.NewWriter(&buf)
+	log := slog.New(color.NewLog(&color.LogOptions{
+		HandlerOptions: slog.HandlerOptions{
+			ReplaceAttr: color.SuppressAttrs(slog.TimeKey),
+		},
+		ColorFunc: color.NoColor,
+		Writer:    w,
+	}))
+
+	err := errorsx.Wrap(io.EOF, "while reading", "file", "config.yaml")
+	log.Error("load failed", "err", err)
+	require.NoError(t, w.Flush())
+
+	assert.Contains(t, buf.String(), `ERROR: load failed err.message="while reading: EOF" err.file=config.yaml err.source=`)
+	assert.Contains(t, buf.String(), "errors_test.go:")
+}
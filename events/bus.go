@@ -0,0 +1,214 @@
+// Package events provides an in-process, typed publish/subscribe bus for decoupling components
+// within a service. Each subscriber has its own bounded queue and goroutine, such that a slow
+// subscriber applies back pressure to publishers without blocking other subscribers.
+package events
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"slices"
+	"sync"
+
+	"github.com/kapetan-io/tackle/set"
+	"github.com/kapetan-io/tackle/wait"
+)
+
+// ErrClosed is returned when publishing to a bus which has been closed
+var ErrClosed = errors.New("events: bus is closed")
+
+type BusConfig struct {
+	// (Optional) The number of events which may be queued for each subscriber before
+	// Publish() blocks. Defaults to 100
+	QueueSize int
+}
+
+// Bus routes published events to all subscribers of the event type
+type Bus struct {
+	mutex  sync.RWMutex
+	conf   BusConfig
+	subs   map[reflect.Type][]*subscriber
+	wg     wait.Group
+	closed bool
+}
+
+type subscriber struct {
+	// Held for reading while an event is queued, such that once the subscriber has set stopped,
+	// no further events can be queued after it drains the queue.
+	mutex   sync.RWMutex
+	stopped bool
+	queue   chan any
+	// Closed when the subscriber is removed from the bus
+	stop chan struct{}
+}
+
+// NewBus creates a new Bus using the config provided
+func NewBus(conf BusConfig) *Bus {
+	set.Default(&conf.QueueSize, 100)
+	return &Bus{
+		conf: conf,
+		subs: make(map[reflect.Type][]*subscriber),
+	}
+}
+
+// Subscribe registers fn to be called for every event of type T published to the bus. Events are
+// delivered to fn in the order they were published from a goroutine dedicated to this subscriber.
+// The returned function unsubscribes fn, events already queued are delivered before it returns.
+//
+// fn may call Subscribe() and Publish(), but must not call its own unsubscribe function directly as
+// unsubscribe waits for fn to return. To unsubscribe from within fn, call unsubscribe in a new goroutine.
+//
+//	unsubscribe := events.Subscribe(bus, func(e UserCreated) {
+//		fmt.Printf("Welcome %s\n", e.Name)
+//	})
+//	defer unsubscribe()
+func Subscribe[T any](b *Bus, fn func(T)) (unsubscribe func()) {
+	s := &subscriber{queue: make(chan any, b.conf.QueueSize), stop: make(chan struct{})}
+	done := make(chan struct{})
+
+	b.mutex.Lock()
+	if b.closed {
+		b.mutex.Unlock()
+		return func() {}
+	}
+	key := typeOf[T]()
+	b.subs[key] = append(b.subs[key], s)
+	b.mutex.Unlock()
+
+	b.wg.Go(func() {
+		defer close(done)
+		for {
+			select {
+			case e := <-s.queue:
+				fn(e.(T))
+			case <-s.stop:
+				// Wait for publishers queuing an event to finish, then deliver all the queued events
+				s.mutex.Lock()
+				s.stopped = true
+				s.mutex.Unlock()
+				for {
+					select {
+					case e := <-s.queue:
+						fn(e.(T))
+					default:
+						return
+					}
+				}
+			}
+		}
+	})
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			b.mutex.Lock()
+			if b.remove(key, s) {
+				close(s.stop)
+			}
+			b.mutex.Unlock()
+		})
+		<-done
+	}
+}
+
+// Publish queues the event for every subscriber of type T. If a subscriber queue is full, Publish
+// blocks until there is room, the subscriber unsubscribes or the context is cancelled. If the context
+// is cancelled, ctx.Err() is returned and the event may have been queued for some subscribers but
+// not others. Returns ErrClosed if the bus is closed, including when Close() stops a subscriber
+// before the event could be queued for it. A nil error means the event will be delivered to every
+// subscriber which did not unsubscribe.
+func Publish[T any](ctx context.Context, b *Bus, event T) error {
+	b.mutex.RLock()
+	if b.closed {
+		b.mutex.RUnlock()
+		return ErrClosed
+	}
+	// Copy the subscribers so the mutex is not held while blocked on a full queue, which would
+	// deadlock a subscriber which calls Subscribe() or unsubscribes while Publish() waits on it.
+	subs := slices.Clone(b.subs[typeOf[T]()])
+	b.mutex.RUnlock()
+
+	var skipped bool
+	for _, s := range subs {
+		queued, err := s.publish(ctx, event)
+		if err != nil {
+			return err
+		}
+		skipped = skipped || !queued
+	}
+
+	// A subscriber which stopped before the event was queued either unsubscribed, in which case it
+	// no longer wants the event, or was stopped by Close(), in which case the event was not published.
+	if skipped {
+		b.mutex.RLock()
+		defer b.mutex.RUnlock()
+		if b.closed {
+			return ErrClosed
+		}
+	}
+	return nil
+}
+
+// publish queues the event unless the subscriber has stopped, returning true if the event was queued.
+// An event queued while the subscriber is stopping is delivered, as the subscriber only drains its
+// queue once no event is being queued.
+func (s *subscriber) publish(ctx context.Context, event any) (bool, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	if s.stopped {
+		return false, nil
+	}
+	select {
+	case s.queue <- event:
+		return true, nil
+	case <-s.stop:
+		return false, nil
+	case <-ctx.Done():
+		return false, ctx.Err()
+	}
+}
+
+// Close stops accepting new events and waits for all subscribers to process the events already
+// queued. Returns ctx.Err() if the context is cancelled before all subscribers have finished.
+func (b *Bus) Close(ctx context.Context) error {
+	b.mutex.Lock()
+	if !b.closed {
+		b.closed = true
+		for key, subs := range b.subs {
+			for _, s := range subs {
+				close(s.stop)
+			}
+			delete(b.subs, key)
+		}
+	}
+	b.mutex.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		_ = b.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// remove removes the subscriber, returns false if it was not found. Must be called with the mutex held
+func (b *Bus) remove(key reflect.Type, s *subscriber) bool {
+	subs := b.subs[key]
+	for i := range subs {
+		if subs[i] == s {
+			b.subs[key] = append(subs[:i], subs[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+func typeOf[T any]() reflect.Type {
+	return reflect.TypeOf((*T)(nil)).Elem()
+}
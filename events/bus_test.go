@@ -0,0 +1,201 @@
+package events_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/kapetan-io/tackle/events"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type UserCreated struct {
+	Name string
+}
+
+type UserDeleted struct {
+	Name string
+}
+
+func TestBus(t *testing.T) {
+	bus := events.NewBus(events.BusConfig{})
+	ctx := context.Background()
+
+	var mutex sync.Mutex
+	var created, deleted []string
+	events.Subscribe(bus, func(e UserCreated) {
+		mutex.Lock()
+		created = append(created, e.Name)
+		mutex.Unlock()
+	})
+	events.Subscribe(bus, func(e UserDeleted) {
+		mutex.Lock()
+		deleted = append(deleted, e.Name)
+		mutex.Unlock()
+	})
+	events.Subscribe(bus, func(e *UserCreated) {
+		assert.Fail(t, "pointer types should not receive value events")
+	})
+
+	require.NoError(t, events.Publish(ctx, bus, UserCreated{Name: "alice"}))
+	require.NoError(t, events.Publish(ctx, bus, UserCreated{Name: "bob"}))
+	require.NoError(t, events.Publish(ctx, bus, UserDeleted{Name: "alice"}))
+	// No subscribers is not an error
+	require.NoError(t, events.Publish(ctx, bus, "string"))
+
+	// Close should wait for all queued events to be delivered
+	require.NoError(t, bus.Close(ctx))
+	assert.Equal(t, []string{"alice", "bob"}, created)
+	assert.Equal(t, []string{"alice"}, deleted)
+
+	assert.ErrorIs(t, events.Publish(ctx, bus, UserCreated{Name: "eve"}), events.ErrClosed)
+}
+
+func TestUnsubscribe(t *testing.T) {
+	bus := events.NewBus(events.BusConfig{})
+	defer func() { _ = bus.Close(context.Background()) }()
+
+	var count int
+	unsubscribe := events.Subscribe(bus, func(e int) { count++ })
+
+	require.NoError(t, events.Publish(context.Background(), bus, 1))
+	unsubscribe()
+	// Calling multiple times is safe
+	unsubscribe()
+	assert.Equal(t, 1, count)
+
+	require.NoError(t, events.Publish(context.Background(), bus, 2))
+	assert.Equal(t, 1, count)
+}
+
+func TestQueueFull(t *testing.T) {
+	bus := events.NewBus(events.BusConfig{QueueSize: 1})
+
+	release := make(chan struct{})
+	events.Subscribe(bus, func(e int) { <-release })
+
+	// The first event is being processed, the second fills the queue
+	require.NoError(t, events.Publish(context.Background(), bus, 1))
+	require.NoError(t, events.Publish(context.Background(), bus, 2))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	err := events.Publish(ctx, bus, 3)
+	// It is possible the first event has not been picked up by the subscriber yet
+	if err == nil {
+		err = events.Publish(ctx, bus, 4)
+	}
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	// Close should time out while the subscriber is blocked
+	ctx, cancel = context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, bus.Close(ctx), context.DeadlineExceeded)
+
+	close(release)
+	require.NoError(t, bus.Close(context.Background()))
+}
+
+func TestSubscribeWhilePublishBlocked(t *testing.T) {
+	bus := events.NewBus(events.BusConfig{QueueSize: 1})
+	defer func() { _ = bus.Close(context.Background()) }()
+
+	var mutex sync.Mutex
+	var received []int
+	release := make(chan struct{})
+	events.Subscribe(bus, func(e int) {
+		<-release
+		// Subscribing from within a subscriber must not deadlock with a blocked Publish()
+		events.Subscribe(bus, func(e string) {})
+		mutex.Lock()
+		received = append(received, e)
+		mutex.Unlock()
+	})
+
+	// The first event is being processed, the second fills the queue
+	require.NoError(t, events.Publish(context.Background(), bus, 1))
+	require.NoError(t, events.Publish(context.Background(), bus, 2))
+
+	published := make(chan error)
+	go func() { published <- events.Publish(context.Background(), bus, 3) }()
+
+	close(release)
+	select {
+	case err := <-published:
+		require.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		require.Fail(t, "timed out waiting for Publish() to return")
+	}
+
+	require.Eventually(t, func() bool {
+		mutex.Lock()
+		defer mutex.Unlock()
+		return len(received) == 3
+	}, 5*time.Second, 10*time.Millisecond)
+	assert.Equal(t, []int{1, 2, 3}, received)
+}
+
+func TestUnsubscribeWhilePublishBlocked(t *testing.T) {
+	bus := events.NewBus(events.BusConfig{QueueSize: 1})
+	defer func() { _ = bus.Close(context.Background()) }()
+
+	release := make(chan struct{})
+	unsubscribe := events.Subscribe(bus, func(e int) { <-release })
+	require.NoError(t, events.Publish(context.Background(), bus, 1))
+	require.NoError(t, events.Publish(context.Background(), bus, 2))
+
+	published := make(chan error)
+	go func() { published <- events.Publish(context.Background(), bus, 3) }()
+
+	// Unsubscribing releases a Publish() blocked on the subscriber queue
+	go unsubscribe()
+	select {
+	case err := <-published:
+		require.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		require.Fail(t, "timed out waiting for Publish() to return")
+	}
+	close(release)
+	unsubscribe()
+}
+
+func TestCloseWhilePublishing(t *testing.T) {
+	// Every event for which Publish() returns nil should be delivered before Close() returns
+	for i := 0; i < 50; i++ {
+		bus := events.NewBus(events.BusConfig{QueueSize: 4})
+		var mutex sync.Mutex
+		var delivered int
+		events.Subscribe(bus, func(UserCreated) {
+			mutex.Lock()
+			delivered++
+			mutex.Unlock()
+		})
+
+		var wg sync.WaitGroup
+		var published int
+		for p := 0; p < 4; p++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for {
+					if err := events.Publish(context.Background(), bus, UserCreated{}); err != nil {
+						return
+					}
+					mutex.Lock()
+					published++
+					mutex.Unlock()
+				}
+			}()
+		}
+
+		time.Sleep(time.Millisecond)
+		require.NoError(t, bus.Close(context.Background()))
+		wg.Wait()
+
+		mutex.Lock()
+		assert.Equal(t, published, delivered)
+		mutex.Unlock()
+	}
+}
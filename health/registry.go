@@ -0,0 +1,256 @@
+// Package health provides a registry of liveness and readiness checks along with http handlers
+// suitable for use as `/healthz` and `/readyz` endpoints.
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/kapetan-io/tackle/clock"
+	"github.com/kapetan-io/tackle/set"
+	"github.com/kapetan-io/tackle/wait"
+)
+
+// Kind determines which endpoint a check is reported by
+type Kind int
+
+const (
+	// Readiness checks report if the service is ready to receive traffic
+	Readiness Kind = iota
+	// Liveness checks report if the service is alive, a failed liveness check
+	// usually results in the service being restarted.
+	Liveness
+)
+
+func (k Kind) String() string {
+	switch k {
+	case Readiness:
+		return "readiness"
+	case Liveness:
+		return "liveness"
+	}
+	return "unknown"
+}
+
+// CheckFunc returns nil if the check passed, or an error describing why the check failed
+type CheckFunc func(ctx context.Context) error
+
+type CheckConfig struct {
+	// The unique name of the check
+	Name string
+
+	// The function which performs the check
+	Check CheckFunc
+
+	// (Optional) Determines which endpoint reports this check. Defaults to Readiness
+	Kind Kind
+
+	// (Optional) The maximum amount of time the check is allowed to run. Defaults to 5 seconds
+	Timeout time.Duration
+
+	// (Optional) If set, the result of the check is cached for this duration such that
+	// frequent probes do not overwhelm the dependency being checked.
+	CacheFor time.Duration
+}
+
+// Result is the result of a single check
+type Result struct {
+	Name     string        `json:"name"`
+	Healthy  bool          `json:"healthy"`
+	Error    string        `json:"error,omitempty"`
+	Duration time.Duration `json:"duration"`
+	Cached   bool          `json:"cached,omitempty"`
+}
+
+// Report is the result of all checks of a Kind
+type Report struct {
+	Healthy bool     `json:"healthy"`
+	Checks  []Result `json:"checks"`
+}
+
+// Registry holds all registered checks, it is safe for concurrent use
+type Registry struct {
+	mutex  sync.Mutex
+	checks map[string]*check
+}
+
+type check struct {
+	mutex    sync.Mutex
+	conf     CheckConfig
+	last     Result
+	checked  time.Time
+	hasCache bool
+}
+
+// NewRegistry creates a new empty Registry
+func NewRegistry() *Registry {
+	return &Registry{checks: make(map[string]*check)}
+}
+
+// Register adds a check to the registry
+func (r *Registry) Register(conf CheckConfig) error {
+	if conf.Name == "" {
+		return errors.New("health: CheckConfig.Name cannot be empty")
+	}
+	if conf.Check == nil {
+		return fmt.Errorf("health: CheckConfig.Check cannot be nil for '%s'", conf.Name)
+	}
+	set.Default(&conf.Timeout, 5*time.Second)
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if _, ok := r.checks[conf.Name]; ok {
+		return fmt.Errorf("health: check '%s' is already registered", conf.Name)
+	}
+	r.checks[conf.Name] = &check{conf: conf}
+	return nil
+}
+
+// Unregister removes the named check from the registry
+func (r *Registry) Unregister(name string) {
+	r.mutex.Lock()
+	delete(r.checks, name)
+	r.mutex.Unlock()
+}
+
+// Dependency registers a readiness check whose status is set manually via the returned
+// Dependency. This allows components such as circuit breakers or retry budgets to mark
+// a downstream dependency as unhealthy. The dependency starts healthy.
+func (r *Registry) Dependency(name string) (*Dependency, error) {
+	d := &Dependency{}
+	err := r.Register(CheckConfig{
+		Name:  name,
+		Kind:  Readiness,
+		Check: func(context.Context) error { return d.Err() },
+	})
+	if err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// Check runs all checks of the provided Kind concurrently and returns a report of the results
+// sorted by name. The report is healthy only if all checks passed.
+func (r *Registry) Check(ctx context.Context, kind Kind) Report {
+	r.mutex.Lock()
+	var checks []*check
+	for _, c := range r.checks {
+		if c.conf.Kind == kind {
+			checks = append(checks, c)
+		}
+	}
+	r.mutex.Unlock()
+
+	results := make([]Result, len(checks))
+	var wg wait.Group
+	for i, c := range checks {
+		wg.Go(func() {
+			results[i] = c.run(ctx)
+		})
+	}
+	_ = wg.Wait()
+
+	sort.Slice(results, func(i, j int) bool { return results[i].Name < results[j].Name })
+	report := Report{Healthy: true, Checks: results}
+	for _, res := range results {
+		if !res.Healthy {
+			report.Healthy = false
+		}
+	}
+	return report
+}
+
+func (c *check) run(ctx context.Context) Result {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.hasCache && clock.Since(c.checked) < c.conf.CacheFor {
+		res := c.last
+		res.Cached = true
+		return res
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, c.conf.Timeout)
+	defer cancel()
+
+	start := clock.Now()
+	errCh := make(chan error, 1)
+	go func() { errCh <- c.conf.Check(ctx) }()
+
+	var err error
+	select {
+	case err = <-errCh:
+	case <-ctx.Done():
+		err = fmt.Errorf("check timed out: %w", ctx.Err())
+	}
+
+	res := Result{Name: c.conf.Name, Healthy: err == nil, Duration: clock.Since(start)}
+	if err != nil {
+		res.Error = err.Error()
+	}
+
+	if c.conf.CacheFor > 0 {
+		c.last = res
+		c.checked = clock.Now()
+		c.hasCache = true
+	}
+	return res
+}
+
+// Handler returns a http.Handler which runs all checks of the provided Kind and responds with
+// the Report encoded as JSON. Responds with 200 if healthy, or 503 if any check failed.
+func (r *Registry) Handler(kind Kind) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		report := r.Check(req.Context(), kind)
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-store")
+		if report.Healthy {
+			w.WriteHeader(http.StatusOK)
+		} else {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+		_ = json.NewEncoder(w).Encode(report)
+	})
+}
+
+// RegisterHandlers adds `/healthz` for liveness checks and `/readyz` for readiness checks to the mux
+func (r *Registry) RegisterHandlers(mux *http.ServeMux) {
+	mux.Handle("/healthz", r.Handler(Liveness))
+	mux.Handle("/readyz", r.Handler(Readiness))
+}
+
+// Dependency is a readiness check whose status is set manually
+type Dependency struct {
+	mutex sync.Mutex
+	err   error
+}
+
+// MarkHealthy marks the dependency as healthy
+func (d *Dependency) MarkHealthy() {
+	d.mutex.Lock()
+	d.err = nil
+	d.mutex.Unlock()
+}
+
+// MarkUnhealthy marks the dependency as unhealthy with the reason provided
+func (d *Dependency) MarkUnhealthy(reason error) {
+	if reason == nil {
+		reason = errors.New("marked unhealthy")
+	}
+	d.mutex.Lock()
+	d.err = reason
+	d.mutex.Unlock()
+}
+
+// Err returns nil if the dependency is healthy, else the reason it was marked unhealthy
+func (d *Dependency) Err() error {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	return d.err
+}
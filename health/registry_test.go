@@ -0,0 +1,120 @@
+package health_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/kapetan-io/tackle/clock"
+	"github.com/kapetan-io/tackle/health"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRegistry(t *testing.T) {
+	r := health.NewRegistry()
+	require.NoError(t, r.Register(health.CheckConfig{
+		Name:  "alive",
+		Kind:  health.Liveness,
+		Check: func(context.Context) error { return nil },
+	}))
+	require.NoError(t, r.Register(health.CheckConfig{
+		Name:  "db",
+		Check: func(context.Context) error { return errors.New("connection refused") },
+	}))
+	require.NoError(t, r.Register(health.CheckConfig{
+		Name:    "slow",
+		Timeout: 10 * time.Millisecond,
+		Check: func(ctx context.Context) error {
+			<-ctx.Done()
+			return nil
+		},
+	}))
+
+	live := r.Check(context.Background(), health.Liveness)
+	assert.True(t, live.Healthy)
+	require.Len(t, live.Checks, 1)
+	assert.Equal(t, "alive", live.Checks[0].Name)
+
+	ready := r.Check(context.Background(), health.Readiness)
+	assert.False(t, ready.Healthy)
+	require.Len(t, ready.Checks, 2)
+	assert.Equal(t, "db", ready.Checks[0].Name)
+	assert.Equal(t, "connection refused", ready.Checks[0].Error)
+	assert.Equal(t, "slow", ready.Checks[1].Name)
+	assert.Equal(t, "check timed out: context deadline exceeded", ready.Checks[1].Error)
+
+	r.Unregister("db")
+	r.Unregister("slow")
+	assert.True(t, r.Check(context.Background(), health.Readiness).Healthy)
+
+	assert.EqualError(t, r.Register(health.CheckConfig{Name: "alive", Check: func(context.Context) error { return nil }}),
+		"health: check 'alive' is already registered")
+	assert.EqualError(t, r.Register(health.CheckConfig{Name: "nil"}),
+		"health: CheckConfig.Check cannot be nil for 'nil'")
+}
+
+func TestCacheFor(t *testing.T) {
+	defer clock.Freeze(clock.Now()).UnFreeze()
+
+	var calls int32
+	r := health.NewRegistry()
+	require.NoError(t, r.Register(health.CheckConfig{
+		Name:     "cached",
+		CacheFor: clock.Minute,
+		Check: func(context.Context) error {
+			atomic.AddInt32(&calls, 1)
+			return nil
+		},
+	}))
+
+	assert.False(t, r.Check(context.Background(), health.Readiness).Checks[0].Cached)
+	assert.True(t, r.Check(context.Background(), health.Readiness).Checks[0].Cached)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+
+	clock.Advance(clock.Minute)
+	assert.False(t, r.Check(context.Background(), health.Readiness).Checks[0].Cached)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&calls))
+}
+
+func TestHandlers(t *testing.T) {
+	r := health.NewRegistry()
+	backend, err := r.Dependency("backend")
+	require.NoError(t, err)
+
+	mux := http.NewServeMux()
+	r.RegisterHandlers(mux)
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	get := func(path string) (int, health.Report) {
+		resp, err := http.Get(srv.URL + path)
+		require.NoError(t, err)
+		defer func() { _ = resp.Body.Close() }()
+		var report health.Report
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&report))
+		return resp.StatusCode, report
+	}
+
+	code, report := get("/readyz")
+	assert.Equal(t, http.StatusOK, code)
+	assert.True(t, report.Healthy)
+
+	backend.MarkUnhealthy(errors.New("circuit open"))
+	code, report = get("/readyz")
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	assert.Equal(t, "circuit open", report.Checks[0].Error)
+
+	// Liveness is not affected by readiness checks
+	code, _ = get("/healthz")
+	assert.Equal(t, http.StatusOK, code)
+
+	backend.MarkHealthy()
+	code, _ = get("/readyz")
+	assert.Equal(t, http.StatusOK, code)
+}
@@ -0,0 +1,147 @@
+// Package httpx provides the glue required to run an http.Server with TLS provided by autotls,
+// and gracefully shut it down when a context is cancelled.
+package httpx
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/kapetan-io/tackle/autotls"
+	"github.com/kapetan-io/tackle/set"
+	"github.com/kapetan-io/tackle/wait"
+)
+
+type Config struct {
+	// The address the server will listen on. Use "localhost:0" to pick a random port
+	// which can be retrieved via Server.Addr()
+	Addr string
+
+	// The handler which will handle all requests
+	Handler http.Handler
+
+	// (Optional) If provided, autotls.Setup() is called with this config and the resulting
+	// autotls.Config.ServerTLS is used to serve TLS. If nil, the server will serve plain HTTP.
+	TLS *autotls.Config
+
+	// (Optional) The maximum amount of time the server will wait for in flight requests to
+	// complete during shutdown before forcibly closing connections. Defaults to 30 seconds.
+	DrainTimeout time.Duration
+
+	// (Optional) The amount of time allowed to read request headers. Defaults to 10 seconds.
+	ReadHeaderTimeout time.Duration
+}
+
+// Server is an http.Server which is bound to a listener and ready to be served.
+type Server struct {
+	conf     Config
+	server   *http.Server
+	listener net.Listener
+}
+
+// NewServer calls autotls.Setup() if Config.TLS is provided, then binds to Config.Addr. As the listener
+// is bound before NewServer() returns, clients may connect as soon as Serve() is called without racing
+// the server startup.
+func NewServer(conf Config) (*Server, error) {
+	set.Default(&conf.DrainTimeout, 30*time.Second)
+	set.Default(&conf.ReadHeaderTimeout, 10*time.Second)
+
+	if conf.Handler == nil {
+		return nil, errors.New("httpx: Config.Handler cannot be nil")
+	}
+
+	s := &Server{
+		conf: conf,
+		server: &http.Server{
+			Addr:              conf.Addr,
+			Handler:           conf.Handler,
+			ReadHeaderTimeout: conf.ReadHeaderTimeout,
+		},
+	}
+
+	if conf.TLS != nil {
+		if err := autotls.Setup(conf.TLS); err != nil {
+			return nil, fmt.Errorf("while setting up tls: %w", err)
+		}
+		s.server.TLSConfig = conf.TLS.ServerTLS
+	}
+
+	var err error
+	s.listener, err = net.Listen("tcp", conf.Addr)
+	if err != nil {
+		return nil, fmt.Errorf("while listening on '%s': %w", conf.Addr, err)
+	}
+	return s, nil
+}
+
+// Addr returns the address the server is listening on
+func (s *Server) Addr() net.Addr {
+	return s.listener.Addr()
+}
+
+// Serve serves requests until the context is cancelled, at which point it performs a graceful
+// shutdown waiting up to Config.DrainTimeout for in flight requests to complete. Returns nil if the
+// server shutdown cleanly, else returns the serve or shutdown error.
+func (s *Server) Serve(ctx context.Context) error {
+	var wg wait.Group
+	done := make(chan struct{})
+
+	wg.Run(func() error {
+		defer close(done)
+		var err error
+		if s.server.TLSConfig != nil {
+			// Certificates are provided via the TLSConfig
+			err = s.server.ServeTLS(s.listener, "", "")
+		} else {
+			err = s.server.Serve(s.listener)
+		}
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
+			return fmt.Errorf("while serving on '%s': %w", s.Addr(), err)
+		}
+		return nil
+	})
+
+	// Wait until either the context is cancelled or the server exits on its own
+	select {
+	case <-ctx.Done():
+	case <-done:
+	}
+
+	shutdownErr := s.shutdown()
+	if err := wg.Wait(); err != nil {
+		return err
+	}
+	return shutdownErr
+}
+
+func (s *Server) shutdown() error {
+	ctx, cancel := context.WithTimeout(context.Background(), s.conf.DrainTimeout)
+	defer cancel()
+
+	if err := s.server.Shutdown(ctx); err != nil {
+		_ = s.server.Close()
+		return fmt.Errorf("while draining connections: %w", err)
+	}
+	return nil
+}
+
+// Run creates a new Server using the provided address, handler and optional autotls config, then
+// serves requests until the context is cancelled. See Server.Serve() for details.
+//
+//	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+//	defer cancel()
+//	err := httpx.Run(ctx, "localhost:8080", handler, &autotls.Config{AutoTLS: true})
+func Run(ctx context.Context, addr string, handler http.Handler, tls *autotls.Config) error {
+	s, err := NewServer(Config{
+		Addr:    addr,
+		Handler: handler,
+		TLS:     tls,
+	})
+	if err != nil {
+		return err
+	}
+	return s.Serve(ctx)
+}
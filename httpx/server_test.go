@@ -0,0 +1,104 @@
+package httpx_test
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/kapetan-io/tackle/autotls"
+	"github.com/kapetan-io/tackle/httpx"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServer(t *testing.T) {
+	tests := []struct {
+		tls    *autotls.Config
+		name   string
+		scheme string
+	}{
+		{
+			name:   "plain http",
+			scheme: "http",
+		},
+		{
+			name:   "auto tls",
+			scheme: "https",
+			tls:    &autotls.Config{AutoTLS: true},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv, err := httpx.NewServer(httpx.Config{
+				Addr: "localhost:0",
+				Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					_, _ = fmt.Fprintln(w, "Hello, client")
+				}),
+				TLS: tt.tls,
+			})
+			require.NoError(t, err)
+
+			ctx, cancel := context.WithCancel(context.Background())
+			errCh := make(chan error)
+			go func() { errCh <- srv.Serve(ctx) }()
+
+			c := &http.Client{}
+			if tt.tls != nil {
+				c.Transport = &http.Transport{TLSClientConfig: tt.tls.ClientTLS}
+			}
+
+			resp, err := c.Get(fmt.Sprintf("%s://%s/", tt.scheme, srv.Addr()))
+			require.NoError(t, err)
+			b, err := io.ReadAll(resp.Body)
+			require.NoError(t, err)
+			_ = resp.Body.Close()
+			assert.Equal(t, "Hello, client\n", string(b))
+
+			cancel()
+			assert.NoError(t, <-errCh)
+		})
+	}
+}
+
+func TestServerDrainTimeout(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+
+	srv, err := httpx.NewServer(httpx.Config{
+		Addr: "localhost:0",
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			<-release
+		}),
+		DrainTimeout: 10 * time.Millisecond,
+	})
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	errCh := make(chan error)
+	go func() { errCh <- srv.Serve(ctx) }()
+
+	// Start a request which will not complete before the drain timeout
+	go func() {
+		resp, err := http.Get(fmt.Sprintf("http://%s/", srv.Addr()))
+		if err == nil {
+			_ = resp.Body.Close()
+		}
+	}()
+
+	// Give the request time to reach the handler
+	time.Sleep(50 * time.Millisecond)
+	cancel()
+	err = <-errCh
+	require.Error(t, err)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+func TestRunListenError(t *testing.T) {
+	err := httpx.Run(context.Background(), "256.0.0.1:0", http.NotFoundHandler(), nil)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "while listening on '256.0.0.1:0'")
+}
@@ -0,0 +1,235 @@
+// Package lifecycle provides a Runner which starts and stops the components of a service in
+// dependency order, with per component timeouts and graceful shutdown upon receiving a signal.
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/kapetan-io/tackle/set"
+	"github.com/kapetan-io/tackle/wait"
+)
+
+// Component is a part of a service which must be started and stopped
+type Component interface {
+	// Start starts the component, it should return once the component is ready to be used.
+	Start(ctx context.Context) error
+	// Stop gracefully stops the component, it should return once the component has stopped.
+	Stop(ctx context.Context) error
+}
+
+type RunnerConfig struct {
+	// (Optional) The default amount of time a component is allowed to start. Defaults to 30 seconds
+	StartTimeout time.Duration
+
+	// (Optional) The default amount of time a component is allowed to stop. Defaults to 30 seconds
+	StopTimeout time.Duration
+
+	// (Optional) The signals which cause Run() to stop all components. Defaults to SIGINT and SIGTERM
+	Signals []os.Signal
+}
+
+// Registration describes a component registered with the Runner
+type Registration struct {
+	// The unique name of the component, used in errors and by DependsOn
+	Name string
+
+	// The component to start and stop
+	Component Component
+
+	// (Optional) The names of the components which must be started before and
+	// stopped after this component.
+	DependsOn []string
+
+	// (Optional) Overrides RunnerConfig.StartTimeout for this component
+	StartTimeout time.Duration
+
+	// (Optional) Overrides RunnerConfig.StopTimeout for this component
+	StopTimeout time.Duration
+}
+
+// Runner starts components in dependency order and stops them in reverse order
+type Runner struct {
+	mutex   sync.Mutex
+	conf    RunnerConfig
+	regs    []Registration
+	names   map[string]struct{}
+	started []Registration
+	running bool
+}
+
+// NewRunner creates a new Runner using the config provided
+func NewRunner(conf RunnerConfig) *Runner {
+	set.Default(&conf.StartTimeout, 30*time.Second)
+	set.Default(&conf.StopTimeout, 30*time.Second)
+	set.Default(&conf.Signals, []os.Signal{os.Interrupt, syscall.SIGTERM})
+	return &Runner{
+		conf:  conf,
+		names: make(map[string]struct{}),
+	}
+}
+
+// Register adds a component to the runner. Dependencies do not need to be registered before
+// the components which depend on them, they are resolved when Start() is called.
+func (r *Runner) Register(reg Registration) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if reg.Name == "" {
+		return errors.New("lifecycle: Registration.Name cannot be empty")
+	}
+	if reg.Component == nil {
+		return fmt.Errorf("lifecycle: Registration.Component cannot be nil for '%s'", reg.Name)
+	}
+	if _, ok := r.names[reg.Name]; ok {
+		return fmt.Errorf("lifecycle: component '%s' is already registered", reg.Name)
+	}
+	set.Default(&reg.StartTimeout, r.conf.StartTimeout)
+	set.Default(&reg.StopTimeout, r.conf.StopTimeout)
+
+	r.names[reg.Name] = struct{}{}
+	r.regs = append(r.regs, reg)
+	return nil
+}
+
+// Start starts all registered components in dependency order. If a component fails to start, the
+// components already started are stopped in reverse order, and all errors are returned as a
+// wait.MultiError. Returns an error if the runner was already started and has not been stopped.
+func (r *Runner) Start(ctx context.Context) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if r.running {
+		return errors.New("lifecycle: runner is already started")
+	}
+
+	order, err := r.resolve()
+	if err != nil {
+		return err
+	}
+
+	for _, reg := range order {
+		if err := r.start(ctx, reg); err != nil {
+			errs := wait.MultiError{err}
+			// The start failure may be due to ctx being cancelled, which must not cut short the
+			// StopTimeout of the components already started.
+			if stopErr := r.stop(context.WithoutCancel(ctx)); stopErr != nil {
+				errs = append(errs, stopErr.(wait.MultiError)...)
+			}
+			return errs
+		}
+		r.started = append(r.started, reg)
+	}
+	r.running = true
+	return nil
+}
+
+// Stop stops all started components in the reverse order they were started. All components are
+// stopped even if some return an error, in which case all errors are returned as a wait.MultiError.
+func (r *Runner) Stop(ctx context.Context) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return r.stop(ctx)
+}
+
+// Run starts all components, then blocks until the context is cancelled or one of the
+// RunnerConfig.Signals is received, after which all components are stopped.
+//
+//	r := lifecycle.NewRunner(lifecycle.RunnerConfig{})
+//	_ = r.Register(lifecycle.Registration{Name: "db", Component: db})
+//	_ = r.Register(lifecycle.Registration{Name: "http", Component: srv, DependsOn: []string{"db"}})
+//	if err := r.Run(context.Background()); err != nil {
+//		log.Fatal(err)
+//	}
+func (r *Runner) Run(ctx context.Context) error {
+	sigCtx, cancel := signal.NotifyContext(ctx, r.conf.Signals...)
+	defer cancel()
+
+	if err := r.Start(sigCtx); err != nil {
+		return err
+	}
+	<-sigCtx.Done()
+
+	// Components are stopped with a context which is not cancelled by the signal, such
+	// that each component is given the full StopTimeout to shutdown gracefully.
+	return r.Stop(context.WithoutCancel(ctx))
+}
+
+func (r *Runner) start(ctx context.Context, reg Registration) error {
+	ctx, cancel := context.WithTimeout(ctx, reg.StartTimeout)
+	defer cancel()
+	if err := reg.Component.Start(ctx); err != nil {
+		return fmt.Errorf("while starting '%s': %w", reg.Name, err)
+	}
+	return nil
+}
+
+func (r *Runner) stop(ctx context.Context) error {
+	var errs wait.MultiError
+	for i := len(r.started) - 1; i >= 0; i-- {
+		reg := r.started[i]
+		func() {
+			ctx, cancel := context.WithTimeout(ctx, reg.StopTimeout)
+			defer cancel()
+			if err := reg.Component.Stop(ctx); err != nil {
+				errs = append(errs, fmt.Errorf("while stopping '%s': %w", reg.Name, err))
+			}
+		}()
+	}
+	r.started = nil
+	r.running = false
+	if len(errs) == 0 {
+		return nil
+	}
+	return errs
+}
+
+// resolve returns the registrations in dependency order, preserving registration order where possible
+func (r *Runner) resolve() ([]Registration, error) {
+	for _, reg := range r.regs {
+		for _, dep := range reg.DependsOn {
+			if _, ok := r.names[dep]; !ok {
+				return nil, fmt.Errorf("lifecycle: component '%s' depends on unknown component '%s'", reg.Name, dep)
+			}
+		}
+	}
+
+	resolved := make(map[string]bool, len(r.regs))
+	order := make([]Registration, 0, len(r.regs))
+	for len(order) < len(r.regs) {
+		var progress bool
+		for _, reg := range r.regs {
+			if resolved[reg.Name] {
+				continue
+			}
+			ready := true
+			for _, dep := range reg.DependsOn {
+				if !resolved[dep] {
+					ready = false
+					break
+				}
+			}
+			if ready {
+				resolved[reg.Name] = true
+				order = append(order, reg)
+				progress = true
+			}
+		}
+		if !progress {
+			var names []string
+			for _, reg := range r.regs {
+				if !resolved[reg.Name] {
+					names = append(names, reg.Name)
+				}
+			}
+			return nil, fmt.Errorf("lifecycle: dependency cycle detected between components %q", names)
+		}
+	}
+	return order, nil
+}
@@ -0,0 +1,209 @@
+package lifecycle_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/kapetan-io/tackle/lifecycle"
+	"github.com/kapetan-io/tackle/wait"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type recorder struct {
+	mutex  sync.Mutex
+	events []string
+}
+
+func (r *recorder) add(e string) {
+	r.mutex.Lock()
+	r.events = append(r.events, e)
+	r.mutex.Unlock()
+}
+
+func (r *recorder) get() []string {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return append([]string{}, r.events...)
+}
+
+type component struct {
+	name     string
+	rec      *recorder
+	startErr error
+	stopErr  error
+	block    bool
+	onStart  func()
+	// The error of the context passed to Stop() when it was called
+	stopCtxErr error
+}
+
+func (c *component) Start(ctx context.Context) error {
+	if c.block {
+		<-ctx.Done()
+		return ctx.Err()
+	}
+	c.rec.add("start " + c.name)
+	if c.onStart != nil {
+		c.onStart()
+	}
+	return c.startErr
+}
+
+func (c *component) Stop(ctx context.Context) error {
+	c.rec.add("stop " + c.name)
+	c.stopCtxErr = ctx.Err()
+	return c.stopErr
+}
+
+func TestRunnerOrder(t *testing.T) {
+	rec := &recorder{}
+	r := lifecycle.NewRunner(lifecycle.RunnerConfig{})
+
+	// Register out of order to ensure dependencies are resolved at Start()
+	require.NoError(t, r.Register(lifecycle.Registration{Name: "http",
+		Component: &component{name: "http", rec: rec}, DependsOn: []string{"cache", "db"}}))
+	require.NoError(t, r.Register(lifecycle.Registration{Name: "cache",
+		Component: &component{name: "cache", rec: rec}, DependsOn: []string{"db"}}))
+	require.NoError(t, r.Register(lifecycle.Registration{Name: "db",
+		Component: &component{name: "db", rec: rec}}))
+
+	require.NoError(t, r.Start(context.Background()))
+	require.NoError(t, r.Stop(context.Background()))
+	assert.Equal(t, []string{
+		"start db", "start cache", "start http",
+		"stop http", "stop cache", "stop db",
+	}, rec.get())
+}
+
+func TestRunnerRegisterErrors(t *testing.T) {
+	r := lifecycle.NewRunner(lifecycle.RunnerConfig{})
+	c := &component{rec: &recorder{}}
+
+	assert.EqualError(t, r.Register(lifecycle.Registration{Component: c}),
+		"lifecycle: Registration.Name cannot be empty")
+	assert.EqualError(t, r.Register(lifecycle.Registration{Name: "a"}),
+		"lifecycle: Registration.Component cannot be nil for 'a'")
+	require.NoError(t, r.Register(lifecycle.Registration{Name: "a", Component: c, DependsOn: []string{"b"}}))
+	assert.EqualError(t, r.Register(lifecycle.Registration{Name: "a", Component: c}),
+		"lifecycle: component 'a' is already registered")
+
+	assert.EqualError(t, r.Start(context.Background()),
+		"lifecycle: component 'a' depends on unknown component 'b'")
+
+	require.NoError(t, r.Register(lifecycle.Registration{Name: "b", Component: c, DependsOn: []string{"a"}}))
+	assert.EqualError(t, r.Start(context.Background()),
+		`lifecycle: dependency cycle detected between components ["a" "b"]`)
+}
+
+func TestRunnerStartFailure(t *testing.T) {
+	rec := &recorder{}
+	r := lifecycle.NewRunner(lifecycle.RunnerConfig{})
+	require.NoError(t, r.Register(lifecycle.Registration{Name: "db",
+		Component: &component{name: "db", rec: rec, stopErr: errors.New("stop failed")}}))
+	require.NoError(t, r.Register(lifecycle.Registration{Name: "http",
+		Component: &component{name: "http", rec: rec, startErr: errors.New("bind failed")}}))
+	require.NoError(t, r.Register(lifecycle.Registration{Name: "never",
+		Component: &component{name: "never", rec: rec}}))
+
+	err := r.Start(context.Background())
+	require.Error(t, err)
+
+	var errs wait.MultiError
+	require.True(t, errors.As(err, &errs))
+	require.Len(t, errs, 2)
+	assert.EqualError(t, errs[0], "while starting 'http': bind failed")
+	assert.EqualError(t, errs[1], "while stopping 'db': stop failed")
+	assert.Equal(t, []string{"start db", "start http", "stop db"}, rec.get())
+}
+
+func TestRunnerStartCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	rec := &recorder{}
+	db := &component{name: "db", rec: rec}
+	r := lifecycle.NewRunner(lifecycle.RunnerConfig{})
+	require.NoError(t, r.Register(lifecycle.Registration{Name: "db", Component: db}))
+	require.NoError(t, r.Register(lifecycle.Registration{Name: "http",
+		Component: &component{name: "http", rec: rec, onStart: cancel, startErr: context.Canceled}}))
+
+	require.Error(t, r.Start(ctx))
+	assert.Equal(t, []string{"start db", "start http", "stop db"}, rec.get())
+	// Components already started should be stopped with a context which is still usable
+	assert.NoError(t, db.stopCtxErr)
+}
+
+func TestRunnerStartTwice(t *testing.T) {
+	rec := &recorder{}
+	r := lifecycle.NewRunner(lifecycle.RunnerConfig{})
+	require.NoError(t, r.Register(lifecycle.Registration{Name: "db",
+		Component: &component{name: "db", rec: rec}}))
+
+	require.NoError(t, r.Start(context.Background()))
+	assert.EqualError(t, r.Start(context.Background()), "lifecycle: runner is already started")
+	require.NoError(t, r.Stop(context.Background()))
+	assert.Equal(t, []string{"start db", "stop db"}, rec.get())
+
+	// Once stopped, the runner can be started again
+	require.NoError(t, r.Start(context.Background()))
+	require.NoError(t, r.Stop(context.Background()))
+}
+
+func TestRunnerStartTimeout(t *testing.T) {
+	r := lifecycle.NewRunner(lifecycle.RunnerConfig{})
+	require.NoError(t, r.Register(lifecycle.Registration{
+		Name:         "slow",
+		Component:    &component{name: "slow", rec: &recorder{}, block: true},
+		StartTimeout: 10 * time.Millisecond,
+	}))
+
+	err := r.Start(context.Background())
+	require.Error(t, err)
+	assert.ErrorIs(t, err.(wait.MultiError)[0], context.DeadlineExceeded)
+}
+
+func TestRunnerRun(t *testing.T) {
+	rec := &recorder{}
+	r := lifecycle.NewRunner(lifecycle.RunnerConfig{})
+	require.NoError(t, r.Register(lifecycle.Registration{Name: "db",
+		Component: &component{name: "db", rec: rec}}))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- r.Run(ctx) }()
+
+	require.Eventually(t, func() bool { return len(rec.get()) == 1 }, time.Second, time.Millisecond)
+	cancel()
+	require.NoError(t, <-done)
+	assert.Equal(t, []string{"start db", "stop db"}, rec.get())
+}
+
+func TestRunnerRunSignal(t *testing.T) {
+	rec := &recorder{}
+	r := lifecycle.NewRunner(lifecycle.RunnerConfig{Signals: []os.Signal{os.Interrupt}})
+	require.NoError(t, r.Register(lifecycle.Registration{Name: "db",
+		Component: &component{name: "db", rec: rec}}))
+
+	done := make(chan error)
+	go func() { done <- r.Run(context.Background()) }()
+	require.Eventually(t, func() bool { return len(rec.get()) == 1 }, time.Second, time.Millisecond)
+
+	p, err := os.FindProcess(os.Getpid())
+	require.NoError(t, err)
+	if err := p.Signal(os.Interrupt); err != nil {
+		t.Skipf("sending signals not supported: %s", err)
+	}
+
+	select {
+	case err := <-done:
+		require.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("Run() did not return after receiving a signal")
+	}
+	assert.Equal(t, []string{"start db", "stop db"}, rec.get())
+}
@@ -0,0 +1,193 @@
+package metrics
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+	"sync/atomic"
+)
+
+// Kind is the type of metric
+type Kind int
+
+const (
+	KindCounter Kind = iota
+	KindGauge
+	KindHistogram
+)
+
+func (k Kind) String() string {
+	switch k {
+	case KindCounter:
+		return "counter"
+	case KindGauge:
+		return "gauge"
+	case KindHistogram:
+		return "histogram"
+	}
+	return "unknown"
+}
+
+// Bucket is a histogram bucket. Count is the number of observations less than or equal to UpperBound.
+type Bucket struct {
+	UpperBound float64
+	Count      uint64
+}
+
+// Snapshot is the state of a single metric at the time InMemory.Snapshot() was called
+type Snapshot struct {
+	Name string
+	Help string
+	Kind Kind
+	// Value is the current value of a counter or gauge
+	Value float64
+	// Count is the total number of histogram observations
+	Count uint64
+	// Sum is the sum of all histogram observations
+	Sum float64
+	// Buckets are the cumulative histogram buckets, not including the implicit +Inf bucket
+	Buckets []Bucket
+}
+
+// InMemory is a Registry which holds all metrics in memory, suitable for exposing via
+// an adapter such as the prometheus sub package, or for asserting on metrics in tests.
+type InMemory struct {
+	mutex   sync.RWMutex
+	metrics map[string]*memMetric
+}
+
+// NewInMemory creates a new empty InMemory registry
+func NewInMemory() *InMemory {
+	return &InMemory{metrics: make(map[string]*memMetric)}
+}
+
+// Counter returns the counter with the provided name, creating it if it doesn't exist.
+// Panics if a metric of a different kind has already been registered with this name.
+func (r *InMemory) Counter(name, help string) Counter {
+	return r.get(name, help, KindCounter, nil)
+}
+
+// Gauge returns the gauge with the provided name, creating it if it doesn't exist.
+// Panics if a metric of a different kind has already been registered with this name.
+func (r *InMemory) Gauge(name, help string) Gauge {
+	return r.get(name, help, KindGauge, nil)
+}
+
+// Histogram returns the histogram with the provided name, creating it if it doesn't exist. The buckets
+// are only used when the histogram is created. Panics if a metric of a different kind has already been
+// registered with this name.
+func (r *InMemory) Histogram(name, help string, buckets []float64) Histogram {
+	if buckets == nil {
+		buckets = DefaultBuckets
+	}
+	return r.get(name, help, KindHistogram, buckets)
+}
+
+func (r *InMemory) get(name, help string, kind Kind, buckets []float64) *memMetric {
+	r.mutex.RLock()
+	m, ok := r.metrics[name]
+	r.mutex.RUnlock()
+
+	if !ok {
+		r.mutex.Lock()
+		m, ok = r.metrics[name]
+		if !ok {
+			m = &memMetric{name: name, help: help, kind: kind}
+			if kind == KindHistogram {
+				m.bounds = append([]float64{}, buckets...)
+				sort.Float64s(m.bounds)
+				m.counts = make([]uint64, len(m.bounds))
+			}
+			r.metrics[name] = m
+		}
+		r.mutex.Unlock()
+	}
+
+	if m.kind != kind {
+		panic(fmt.Sprintf("metrics: '%s' already registered as a %s not a %s", name, m.kind, kind))
+	}
+	return m
+}
+
+// Snapshot returns the current state of all metrics sorted by name
+func (r *InMemory) Snapshot() []Snapshot {
+	r.mutex.RLock()
+	result := make([]Snapshot, 0, len(r.metrics))
+	for _, m := range r.metrics {
+		result = append(result, m.snapshot())
+	}
+	r.mutex.RUnlock()
+
+	sort.Slice(result, func(i, j int) bool { return result[i].Name < result[j].Name })
+	return result
+}
+
+// memMetric implements Counter, Gauge and Histogram
+type memMetric struct {
+	name string
+	help string
+	kind Kind
+	// value holds the float64 bits of a counter or gauge
+	value atomic.Uint64
+
+	// histogram fields are protected by mutex
+	mutex  sync.Mutex
+	bounds []float64
+	counts []uint64
+	count  uint64
+	sum    float64
+}
+
+func (m *memMetric) Inc() {
+	m.Add(1)
+}
+
+func (m *memMetric) Add(delta float64) {
+	for {
+		old := m.value.Load()
+		if m.value.CompareAndSwap(old, math.Float64bits(math.Float64frombits(old)+delta)) {
+			return
+		}
+	}
+}
+
+func (m *memMetric) Set(value float64) {
+	m.value.Store(math.Float64bits(value))
+}
+
+func (m *memMetric) Observe(value float64) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	// Buckets are cumulative, so count the observation in every bucket it fits into
+	for i, bound := range m.bounds {
+		if value <= bound {
+			m.counts[i]++
+		}
+	}
+	m.count++
+	m.sum += value
+}
+
+func (m *memMetric) snapshot() Snapshot {
+	s := Snapshot{
+		Name:  m.name,
+		Help:  m.help,
+		Kind:  m.kind,
+		Value: math.Float64frombits(m.value.Load()),
+	}
+	if m.kind != KindHistogram {
+		return s
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	s.Count = m.count
+	s.Sum = m.sum
+	s.Buckets = make([]Bucket, len(m.bounds))
+	for i := range m.bounds {
+		s.Buckets[i] = Bucket{UpperBound: m.bounds[i], Count: m.counts[i]}
+	}
+	return s
+}
@@ -0,0 +1,83 @@
+// Package metrics provides a tiny metrics abstraction which tackle packages emit into. By default, all
+// metrics are discarded via the NoOp registry. Call SetDefault() with a registry such as InMemory, or an
+// adapter to your metrics pipeline of choice, to collect metrics emitted by tackle packages.
+//
+//	reg := metrics.NewInMemory()
+//	metrics.SetDefault(reg)
+//	http.Handle("/metrics", prometheus.Handler(reg))
+package metrics
+
+import (
+	"sync/atomic"
+)
+
+// Counter is a metric which only increases
+type Counter interface {
+	Inc()
+	Add(delta float64)
+}
+
+// Gauge is a metric which can arbitrarily increase or decrease
+type Gauge interface {
+	Set(value float64)
+	Add(delta float64)
+}
+
+// Histogram is a metric which samples observations into buckets
+type Histogram interface {
+	Observe(value float64)
+}
+
+// Registry creates or returns existing metrics by name. Implementations must be safe
+// for concurrent use and should return the same metric when called with the same name.
+type Registry interface {
+	Counter(name, help string) Counter
+	Gauge(name, help string) Gauge
+	// Histogram returns a histogram with the provided upper bounds. If buckets
+	// is nil, DefaultBuckets are used.
+	Histogram(name, help string, buckets []float64) Histogram
+}
+
+// DefaultBuckets are the default histogram bucket upper bounds, which are suitable
+// for measuring latency in seconds.
+var DefaultBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// NoOp is a Registry which discards all metrics
+var NoOp Registry = noOpRegistry{}
+
+type registryHolder struct {
+	r Registry
+}
+
+var defaultRegistry atomic.Pointer[registryHolder]
+
+func init() {
+	defaultRegistry.Store(&registryHolder{r: NoOp})
+}
+
+// Default returns the registry tackle packages emit metrics into
+func Default() Registry {
+	return defaultRegistry.Load().r
+}
+
+// SetDefault sets the registry tackle packages emit metrics into. If nil, the NoOp registry is used.
+// The registry must be comparable, such as a pointer, as packages compare it to detect when it changes.
+func SetDefault(r Registry) {
+	if r == nil {
+		r = NoOp
+	}
+	defaultRegistry.Store(&registryHolder{r: r})
+}
+
+type noOpRegistry struct{}
+
+func (noOpRegistry) Counter(string, string) Counter                { return noOpMetric{} }
+func (noOpRegistry) Gauge(string, string) Gauge                    { return noOpMetric{} }
+func (noOpRegistry) Histogram(string, string, []float64) Histogram { return noOpMetric{} }
+
+type noOpMetric struct{}
+
+func (noOpMetric) Inc()            {}
+func (noOpMetric) Add(float64)     {}
+func (noOpMetric) Set(float64)     {}
+func (noOpMetric) Observe(float64) {}
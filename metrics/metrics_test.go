@@ -0,0 +1,57 @@
+package metrics_test
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/kapetan-io/tackle/metrics"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInMemory(t *testing.T) {
+	r := metrics.NewInMemory()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			r.Counter("requests_total", "Total requests").Inc()
+			r.Gauge("in_flight", "In flight requests").Add(2)
+			r.Gauge("in_flight", "In flight requests").Add(-1)
+		}()
+	}
+	wg.Wait()
+
+	h := r.Histogram("latency_seconds", "Request latency", []float64{1, 0.1})
+	h.Observe(0.05)
+	h.Observe(0.5)
+	h.Observe(5)
+
+	s := r.Snapshot()
+	require.Len(t, s, 3)
+	assert.Equal(t, metrics.Snapshot{Name: "in_flight", Help: "In flight requests",
+		Kind: metrics.KindGauge, Value: 10}, s[0])
+	assert.Equal(t, metrics.Snapshot{Name: "latency_seconds", Help: "Request latency",
+		Kind: metrics.KindHistogram, Count: 3, Sum: 5.55,
+		Buckets: []metrics.Bucket{{UpperBound: 0.1, Count: 1}, {UpperBound: 1, Count: 2}}}, s[1])
+	assert.Equal(t, metrics.Snapshot{Name: "requests_total", Help: "Total requests",
+		Kind: metrics.KindCounter, Value: 10}, s[2])
+
+	assert.Panics(t, func() { r.Gauge("requests_total", "") })
+}
+
+func TestDefault(t *testing.T) {
+	assert.Equal(t, metrics.NoOp, metrics.Default())
+	// The NoOp registry should silently discard all metrics
+	metrics.Default().Counter("discarded", "").Inc()
+
+	r := metrics.NewInMemory()
+	metrics.SetDefault(r)
+	defer metrics.SetDefault(nil)
+	assert.Equal(t, r, metrics.Default())
+
+	metrics.Default().Gauge("set", "").Set(5)
+	assert.Equal(t, float64(5), r.Snapshot()[0].Value)
+}
@@ -0,0 +1,68 @@
+// Package prometheus exposes a metrics.InMemory registry using the Prometheus text exposition format
+// without depending upon the Prometheus client libraries.
+package prometheus
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/kapetan-io/tackle/metrics"
+)
+
+const contentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// Handler returns a http.Handler which writes all metrics in the registry using the
+// Prometheus text exposition format, suitable for scraping by a Prometheus server.
+func Handler(r *metrics.InMemory) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", contentType)
+		if err := Write(w, r); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	})
+}
+
+// Write writes all metrics in the registry to 'w' using the Prometheus text exposition format
+func Write(w io.Writer, r *metrics.InMemory) error {
+	buf := bufio.NewWriter(w)
+	for _, s := range r.Snapshot() {
+		if s.Help != "" {
+			_, _ = fmt.Fprintf(buf, "# HELP %s %s\n", s.Name, escapeHelp(s.Help))
+		}
+		_, _ = fmt.Fprintf(buf, "# TYPE %s %s\n", s.Name, s.Kind)
+
+		if s.Kind != metrics.KindHistogram {
+			_, _ = fmt.Fprintf(buf, "%s %s\n", s.Name, formatFloat(s.Value))
+			continue
+		}
+
+		for _, b := range s.Buckets {
+			_, _ = fmt.Fprintf(buf, "%s_bucket{le=\"%s\"} %d\n", s.Name, formatFloat(b.UpperBound), b.Count)
+		}
+		_, _ = fmt.Fprintf(buf, "%s_bucket{le=\"+Inf\"} %d\n", s.Name, s.Count)
+		_, _ = fmt.Fprintf(buf, "%s_sum %s\n", s.Name, formatFloat(s.Sum))
+		_, _ = fmt.Fprintf(buf, "%s_count %d\n", s.Name, s.Count)
+	}
+	return buf.Flush()
+}
+
+func formatFloat(f float64) string {
+	switch {
+	case math.IsInf(f, 1):
+		return "+Inf"
+	case math.IsInf(f, -1):
+		return "-Inf"
+	case math.IsNaN(f):
+		return "NaN"
+	}
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
+
+func escapeHelp(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "\n", `\n`).Replace(s)
+}
@@ -0,0 +1,46 @@
+package prometheus_test
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/kapetan-io/tackle/metrics"
+	"github.com/kapetan-io/tackle/metrics/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHandler(t *testing.T) {
+	r := metrics.NewInMemory()
+	r.Counter("requests_total", "Total requests").Add(3)
+	r.Gauge("temperature", "").Set(-1.5)
+	h := r.Histogram("latency_seconds", "Request latency\nin seconds", []float64{0.1, 1})
+	h.Observe(0.05)
+	h.Observe(2)
+
+	srv := httptest.NewServer(prometheus.Handler(r))
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL)
+	require.NoError(t, err)
+	defer func() { _ = resp.Body.Close() }()
+	b, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+
+	assert.Equal(t, "text/plain; version=0.0.4; charset=utf-8", resp.Header.Get("Content-Type"))
+	assert.Equal(t, `# HELP latency_seconds Request latency\nin seconds
+# TYPE latency_seconds histogram
+latency_seconds_bucket{le="0.1"} 1
+latency_seconds_bucket{le="1"} 1
+latency_seconds_bucket{le="+Inf"} 2
+latency_seconds_sum 2.05
+latency_seconds_count 2
+# HELP requests_total Total requests
+# TYPE requests_total counter
+requests_total 3
+# TYPE temperature gauge
+temperature -1.5
+`, string(b))
+}
@@ -0,0 +1,235 @@
+// Package rate provides a client side token bucket rate limiter suitable for throttling outbound
+// requests. The limiter uses the clock package, such that tests can use clock.Freeze() and
+// clock.Advance() to control the flow of tokens.
+package rate
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/kapetan-io/tackle/clock"
+)
+
+// Limit is the maximum number of events allowed per second
+type Limit float64
+
+// Inf is an infinite rate limit, which allows all events
+const Inf = Limit(math.MaxFloat64)
+
+// Every converts the minimum interval between events into a Limit
+//
+//	// Allow 10 events per second
+//	l := rate.NewLimiter(rate.Every(100*time.Millisecond), 1)
+func Every(interval time.Duration) Limit {
+	if interval <= 0 {
+		return Inf
+	}
+	return 1 / Limit(interval.Seconds())
+}
+
+// Limiter is a token bucket which fills at a rate of Limit tokens per second up to a maximum of
+// 'burst' tokens. Each event consumes a single token. Limiter is safe for concurrent use.
+type Limiter struct {
+	mutex  sync.Mutex
+	limit  Limit
+	burst  int
+	tokens float64
+	last   time.Time
+}
+
+// NewLimiter returns a new Limiter which allows events up to 'limit' per second and permits
+// bursts of up to 'burst' events. The bucket starts full.
+func NewLimiter(limit Limit, burst int) *Limiter {
+	return &Limiter{
+		limit:  limit,
+		burst:  burst,
+		tokens: float64(burst),
+		last:   clock.Now(),
+	}
+}
+
+// Limit returns the current limit
+func (l *Limiter) Limit() Limit {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	return l.limit
+}
+
+// Burst returns the current burst size
+func (l *Limiter) Burst() int {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	return l.burst
+}
+
+// SetLimit changes the limit, tokens accumulated at the previous limit are retained
+func (l *Limiter) SetLimit(limit Limit) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	l.advance(clock.Now())
+	l.limit = limit
+}
+
+// Tokens returns the number of tokens currently available. The result may be negative if
+// there are outstanding reservations.
+func (l *Limiter) Tokens() float64 {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	l.advance(clock.Now())
+	return l.tokens
+}
+
+// Allow reports whether an event may happen now, consuming a token if it may
+func (l *Limiter) Allow() bool {
+	return l.AllowN(1)
+}
+
+// AllowN reports whether n events may happen now, consuming n tokens if they may
+func (l *Limiter) AllowN(n int) bool {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	if l.limit == Inf {
+		return true
+	}
+	l.advance(clock.Now())
+	if l.tokens < float64(n) {
+		return false
+	}
+	l.tokens -= float64(n)
+	return true
+}
+
+// Reservation holds tokens reserved by the limiter for an event which will happen in the future
+type Reservation struct {
+	l      *Limiter
+	ok     bool
+	tokens int
+	at     time.Time
+}
+
+// OK reports whether the limiter can provide the requested number of tokens. If false,
+// the request exceeds the burst size and will never be satisfied.
+func (r *Reservation) OK() bool {
+	return r.ok
+}
+
+// Delay returns the duration the caller must wait before the reserved event may happen.
+// Returns zero if the event may happen now.
+func (r *Reservation) Delay() time.Duration {
+	if !r.ok {
+		return time.Duration(math.MaxInt64)
+	}
+	d := r.at.Sub(clock.Now())
+	if d < 0 {
+		return 0
+	}
+	return d
+}
+
+// Cancel returns the reserved tokens to the limiter if the reserved time has not yet passed.
+// This should be called if the caller decides not to perform the event.
+func (r *Reservation) Cancel() {
+	if !r.ok {
+		return
+	}
+
+	r.l.mutex.Lock()
+	defer r.l.mutex.Unlock()
+
+	now := clock.Now()
+	if r.tokens == 0 || r.l.limit == Inf || !r.at.After(now) {
+		return
+	}
+	r.l.advance(now)
+	r.l.tokens = math.Min(r.l.tokens+float64(r.tokens), float64(r.l.burst))
+	r.tokens = 0
+}
+
+// Reserve reserves a single token and returns a Reservation indicating how long the caller must
+// wait before the event may happen. Call Cancel() if the event will not happen.
+func (l *Limiter) Reserve() *Reservation {
+	return l.ReserveN(1)
+}
+
+// ReserveN reserves n tokens. See Reserve()
+func (l *Limiter) ReserveN(n int) *Reservation {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	now := clock.Now()
+	if l.limit == Inf {
+		return &Reservation{l: l, ok: true, at: now}
+	}
+	if n > l.burst {
+		return &Reservation{l: l, ok: false}
+	}
+
+	l.advance(now)
+	l.tokens -= float64(n)
+	r := &Reservation{l: l, ok: true, tokens: n, at: now}
+	if l.tokens < 0 {
+		r.at = now.Add(l.durationFor(-l.tokens))
+	}
+	return r
+}
+
+// Wait blocks until a single token is available or the context is cancelled
+func (l *Limiter) Wait(ctx context.Context) error {
+	return l.WaitN(ctx, 1)
+}
+
+// WaitN blocks until n tokens are available or the context is cancelled. Returns an error without
+// waiting if n exceeds the burst size or if the wait would exceed the context deadline.
+func (l *Limiter) WaitN(ctx context.Context, n int) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	r := l.ReserveN(n)
+	if !r.OK() {
+		return fmt.Errorf("rate: WaitN(n=%d) exceeds limiter burst %d", n, l.Burst())
+	}
+
+	delay := r.Delay()
+	if delay == 0 {
+		return nil
+	}
+
+	if deadline, ok := ctx.Deadline(); ok && clock.Now().Add(delay).After(deadline) {
+		r.Cancel()
+		return fmt.Errorf("rate: WaitN(n=%d) would exceed context deadline", n)
+	}
+
+	timer := clock.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C():
+		return nil
+	case <-ctx.Done():
+		r.Cancel()
+		return ctx.Err()
+	}
+}
+
+// advance adds the tokens accumulated since the last call, must be called with the mutex held
+func (l *Limiter) advance(now time.Time) {
+	if now.Before(l.last) {
+		l.last = now
+		return
+	}
+	elapsed := now.Sub(l.last)
+	l.last = now
+	l.tokens = math.Min(l.tokens+elapsed.Seconds()*float64(l.limit), float64(l.burst))
+}
+
+// durationFor returns the time required to accumulate the provided number of tokens
+func (l *Limiter) durationFor(tokens float64) time.Duration {
+	if l.limit <= 0 {
+		return time.Duration(math.MaxInt64)
+	}
+	return time.Duration(tokens / float64(l.limit) * float64(time.Second))
+}
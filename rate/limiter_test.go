@@ -0,0 +1,101 @@
+package rate_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/kapetan-io/tackle/clock"
+	"github.com/kapetan-io/tackle/rate"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAllow(t *testing.T) {
+	defer clock.Freeze(clock.Now()).UnFreeze()
+
+	l := rate.NewLimiter(rate.Every(100*clock.Millisecond), 3)
+
+	// Should allow a burst of 3
+	assert.True(t, l.Allow())
+	assert.True(t, l.Allow())
+	assert.True(t, l.Allow())
+	assert.False(t, l.Allow())
+
+	clock.Advance(100 * clock.Millisecond)
+	assert.True(t, l.Allow())
+	assert.False(t, l.Allow())
+
+	// Tokens should never exceed the burst
+	clock.Advance(clock.Hour)
+	assert.Equal(t, float64(3), l.Tokens())
+	assert.False(t, l.AllowN(4))
+	assert.True(t, l.AllowN(3))
+}
+
+func TestInf(t *testing.T) {
+	l := rate.NewLimiter(rate.Inf, 0)
+	for i := 0; i < 100; i++ {
+		assert.True(t, l.Allow())
+	}
+	assert.Equal(t, rate.Inf, rate.Every(0))
+}
+
+func TestReserve(t *testing.T) {
+	defer clock.Freeze(clock.Now()).UnFreeze()
+
+	l := rate.NewLimiter(10, 1)
+	r := l.Reserve()
+	require.True(t, r.OK())
+	assert.Equal(t, clock.Duration(0), r.Delay())
+
+	r = l.Reserve()
+	require.True(t, r.OK())
+	assert.Equal(t, 100*clock.Millisecond, r.Delay())
+
+	// Cancelling returns the tokens to the bucket
+	r.Cancel()
+	r = l.Reserve()
+	assert.Equal(t, 100*clock.Millisecond, r.Delay())
+
+	clock.Advance(50 * clock.Millisecond)
+	assert.Equal(t, 50*clock.Millisecond, r.Delay())
+
+	assert.False(t, l.ReserveN(2).OK())
+}
+
+func TestWait(t *testing.T) {
+	defer clock.Freeze(clock.Now()).UnFreeze()
+
+	l := rate.NewLimiter(rate.Every(clock.Second), 1)
+	require.NoError(t, l.Wait(context.Background()))
+
+	done := make(chan error)
+	go func() { done <- l.Wait(context.Background()) }()
+
+	// Wait should block until the next token is available
+	require.True(t, clock.Wait4Scheduled(1, clock.Second))
+	select {
+	case <-done:
+		t.Fatal("Wait() returned before a token was available")
+	default:
+	}
+	clock.Advance(clock.Second)
+	require.NoError(t, <-done)
+}
+
+func TestWaitErrors(t *testing.T) {
+	l := rate.NewLimiter(rate.Every(clock.Hour), 1)
+	require.NoError(t, l.Wait(context.Background()))
+
+	err := l.WaitN(context.Background(), 2)
+	require.EqualError(t, err, "rate: WaitN(n=2) exceeds limiter burst 1")
+
+	ctx, cancel := context.WithTimeout(context.Background(), clock.Second)
+	defer cancel()
+	err = l.Wait(ctx)
+	require.EqualError(t, err, "rate: WaitN(n=1) would exceed context deadline")
+
+	ctx, cancel = context.WithCancel(context.Background())
+	cancel()
+	require.ErrorIs(t, l.Wait(ctx), context.Canceled)
+}
@@ -0,0 +1,102 @@
+// Package syncx provides small, type safe synchronization primitives which complement the standard sync package.
+package syncx
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// Value is a type safe wrapper around atomic.Pointer which stores values of type T. The zero
+// value is ready to use and Load() returns the zero value of T until Store() is called.
+type Value[T any] struct {
+	p atomic.Pointer[T]
+}
+
+// Load returns the value most recently stored, or the zero value of T if nothing has been stored
+func (v *Value[T]) Load() T {
+	if p := v.p.Load(); p != nil {
+		return *p
+	}
+	var zero T
+	return zero
+}
+
+// Store atomically stores the value provided
+func (v *Value[T]) Store(value T) {
+	v.p.Store(&value)
+}
+
+// Swap atomically stores the new value and returns the previous value, or
+// the zero value of T if nothing has been stored
+func (v *Value[T]) Swap(value T) T {
+	if p := v.p.Swap(&value); p != nil {
+		return *p
+	}
+	var zero T
+	return zero
+}
+
+// OnceValue returns a function which calls fn only once and returns the memoized value and error
+// on every subsequent call, similar to sync.OnceValues. If fn panics, the returned function will
+// panic with the same value on every call.
+func OnceValue[T any](fn func() (T, error)) func() (T, error) {
+	return sync.OnceValues(fn)
+}
+
+// OnceValueRetry returns a function which calls fn until it succeeds. Once fn returns a nil error
+// the value is memoized and returned on every subsequent call without calling fn. If fn returns an
+// error, the error is returned to the caller and the next call will call fn again. Concurrent calls
+// are serialized such that only one call to fn is in flight at a time.
+//
+//	getConn := syncx.OnceValueRetry(func() (*sql.DB, error) {
+//		return sql.Open("postgres", dsn)
+//	})
+func OnceValueRetry[T any](fn func() (T, error)) func() (T, error) {
+	var (
+		mutex sync.Mutex
+		done  atomic.Bool
+		value T
+	)
+	return func() (T, error) {
+		if done.Load() {
+			return value, nil
+		}
+
+		mutex.Lock()
+		defer mutex.Unlock()
+		if done.Load() {
+			return value, nil
+		}
+
+		v, err := fn()
+		if err != nil {
+			var zero T
+			return zero, err
+		}
+		value = v
+		done.Store(true)
+		return value, nil
+	}
+}
+
+// Lazy is a value of type T which is initialized on first use
+type Lazy[T any] struct {
+	once  sync.Once
+	fn    func() T
+	value T
+}
+
+// NewLazy returns a Lazy which calls fn once upon the first call to Get()
+func NewLazy[T any](fn func() T) *Lazy[T] {
+	return &Lazy[T]{fn: fn}
+}
+
+// Get returns the value, calling the initialization function if this is the first call to Get().
+// Concurrent calls block until initialization has completed.
+func (l *Lazy[T]) Get() T {
+	l.once.Do(func() {
+		l.value = l.fn()
+		l.fn = nil
+	})
+	return l.value
+}
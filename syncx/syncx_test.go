@@ -0,0 +1,105 @@
+package syncx_test
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/kapetan-io/tackle/syncx"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValue(t *testing.T) {
+	type config struct {
+		Name string
+	}
+	var v syncx.Value[config]
+	assert.Equal(t, config{}, v.Load())
+
+	v.Store(config{Name: "first"})
+	assert.Equal(t, config{Name: "first"}, v.Load())
+	assert.Equal(t, config{Name: "first"}, v.Swap(config{Name: "second"}))
+	assert.Equal(t, config{Name: "second"}, v.Load())
+
+	var e syncx.Value[error]
+	assert.Nil(t, e.Swap(errors.New("error")))
+	assert.EqualError(t, e.Load(), "error")
+
+	var wg sync.WaitGroup
+	var counter syncx.Value[int]
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			counter.Store(i)
+			_ = counter.Load()
+		}()
+	}
+	wg.Wait()
+}
+
+func TestOnceValue(t *testing.T) {
+	var calls int32
+	get := syncx.OnceValue(func() (int, error) {
+		atomic.AddInt32(&calls, 1)
+		return 0, errors.New("failed")
+	})
+
+	for i := 0; i < 3; i++ {
+		_, err := get()
+		assert.EqualError(t, err, "failed")
+	}
+	// Errors are memoized
+	assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+}
+
+func TestOnceValueRetry(t *testing.T) {
+	var calls int32
+	get := syncx.OnceValueRetry(func() (string, error) {
+		if atomic.AddInt32(&calls, 1) < 3 {
+			return "", errors.New("not yet")
+		}
+		return "connected", nil
+	})
+
+	_, err := get()
+	assert.EqualError(t, err, "not yet")
+	_, err = get()
+	assert.EqualError(t, err, "not yet")
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			v, err := get()
+			assert.NoError(t, err)
+			assert.Equal(t, "connected", v)
+		}()
+	}
+	wg.Wait()
+	// Once successful, the value is memoized
+	assert.Equal(t, int32(3), atomic.LoadInt32(&calls))
+}
+
+func TestLazy(t *testing.T) {
+	var calls int32
+	l := syncx.NewLazy(func() []string {
+		atomic.AddInt32(&calls, 1)
+		return []string{"a", "b"}
+	})
+	assert.Equal(t, int32(0), atomic.LoadInt32(&calls))
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			assert.Equal(t, []string{"a", "b"}, l.Get())
+		}()
+	}
+	wg.Wait()
+	require.Equal(t, int32(1), atomic.LoadInt32(&calls))
+}
@@ -0,0 +1,177 @@
+// Package trace provides lightweight timing spans which are emitted as slog records when they end. It is
+// a zero dependency alternative for when full OpenTelemetry is overkill. Durations are measured using the
+// clock package, such that tests can use clock.Freeze() and clock.Advance() for deterministic output.
+//
+//	ctx, span := trace.Start(ctx, "fetch-user", "user_id", id)
+//	defer span.End()
+package trace
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kapetan-io/tackle/clock"
+)
+
+// Options control how spans are emitted
+type Options struct {
+	// (Optional) The logger spans are emitted to. Defaults to slog.Default()
+	Logger *slog.Logger
+
+	// (Optional) The level spans are emitted at. Defaults to slog.LevelInfo
+	Level slog.Level
+
+	// (Optional) If true, child spans are not emitted when they end. Instead, when the root span
+	// ends, it and all its descendants are emitted in parent/child order with the span names
+	// indented to render the hierarchy.
+	Tree bool
+}
+
+type optionsKey struct{}
+type spanKey struct{}
+
+// WithOptions returns a context with the provided options, all spans started from the
+// returned context or its children will use these options.
+func WithOptions(ctx context.Context, opts Options) context.Context {
+	return context.WithValue(ctx, optionsKey{}, opts)
+}
+
+// Span records the time taken to perform an operation
+type Span struct {
+	mutex sync.Mutex
+	// The context returned by Start(), which is passed to the logger such that handlers can
+	// read values from the context.
+	ctx      context.Context
+	name     string
+	opts     Options
+	parent   *Span
+	depth    int
+	attrs    []slog.Attr
+	start    time.Time
+	elapsed  time.Duration
+	ended    bool
+	children []*Span
+}
+
+// Start starts a new span with the provided name and attributes, which are provided as alternating
+// key/value pairs or slog.Attr using the same rules as slog.Logger.Info(). If the context contains
+// a span, the new span is a child of that span. The returned context contains the new span.
+func Start(ctx context.Context, name string, kv ...any) (context.Context, *Span) {
+	opts, _ := ctx.Value(optionsKey{}).(Options)
+	if opts.Logger == nil {
+		opts.Logger = slog.Default()
+	}
+
+	s := &Span{
+		name:  name,
+		opts:  opts,
+		attrs: slog.Group("", kv...).Value.Group(),
+		start: clock.Now(),
+	}
+
+	if parent := FromContext(ctx); parent != nil {
+		s.parent = parent
+		s.depth = parent.depth + 1
+		// Children are only kept in tree mode, such that a long lived root span does not keep
+		// every child reachable after it has been emitted.
+		if opts.Tree {
+			parent.mutex.Lock()
+			parent.children = append(parent.children, s)
+			parent.mutex.Unlock()
+		}
+	}
+	s.ctx = context.WithValue(ctx, spanKey{}, s)
+	return s.ctx, s
+}
+
+// FromContext returns the current span from the context, or nil if there is none
+func FromContext(ctx context.Context) *Span {
+	s, _ := ctx.Value(spanKey{}).(*Span)
+	return s
+}
+
+// SetAttrs adds attributes to the span which are emitted when the span ends
+func (s *Span) SetAttrs(kv ...any) {
+	s.mutex.Lock()
+	s.attrs = append(s.attrs, slog.Group("", kv...).Value.Group()...)
+	s.mutex.Unlock()
+}
+
+// Name returns the name of the span
+func (s *Span) Name() string {
+	return s.name
+}
+
+// Path returns the names of all the parent spans and this span joined by '/'
+func (s *Span) Path() string {
+	if s.parent == nil {
+		return s.name
+	}
+	return s.parent.Path() + "/" + s.name
+}
+
+// Elapsed returns the duration of the span if it has ended, else the time elapsed since it started
+func (s *Span) Elapsed() time.Duration {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.ended {
+		return s.elapsed
+	}
+	return clock.Since(s.start)
+}
+
+// End records the duration of the span and emits it as a slog record with the attributes 'elapsed'
+// and 'span', which is the path of the span. Calling End() more than once has no effect.
+func (s *Span) End() {
+	s.mutex.Lock()
+	if s.ended {
+		s.mutex.Unlock()
+		return
+	}
+	s.ended = true
+	s.elapsed = clock.Since(s.start)
+	s.mutex.Unlock()
+
+	if !s.opts.Tree {
+		s.emit(s.name, slog.String("span", s.Path()))
+		return
+	}
+
+	// In tree mode only the root span emits, children are emitted with their root
+	if s.parent == nil {
+		s.emitTree()
+	}
+}
+
+func (s *Span) emitTree() {
+	name := s.name
+	if s.depth > 0 {
+		name = strings.Repeat("  ", s.depth-1) + "└─ " + s.name
+	}
+	s.emit(name)
+
+	s.mutex.Lock()
+	children := append([]*Span{}, s.children...)
+	s.mutex.Unlock()
+	for _, c := range children {
+		c.emitTree()
+	}
+}
+
+func (s *Span) emit(msg string, extra ...slog.Attr) {
+	s.mutex.Lock()
+	attrs := make([]slog.Attr, 0, len(s.attrs)+len(extra)+2)
+	if s.ended {
+		attrs = append(attrs, slog.Duration("elapsed", s.elapsed))
+	} else {
+		attrs = append(attrs, slog.Duration("elapsed", clock.Since(s.start)), slog.Bool("unfinished", true))
+	}
+	attrs = append(attrs, extra...)
+	attrs = append(attrs, s.attrs...)
+	s.mutex.Unlock()
+
+	s.opts.Logger.LogAttrs(s.ctx, s.opts.Level, msg, attrs...)
+}
@@ -0,0 +1,127 @@
+package trace_test
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"log/slog"
+	"testing"
+
+	"github.com/kapetan-io/tackle/clock"
+	"github.com/kapetan-io/tackle/color"
+	"github.com/kapetan-io/tackle/trace"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newLogger() (*slog.Logger, *bufio.Writer, *bytes.Buffer) {
+	var buf bytes.Buffer
+	w := bufio.NewWriter(&buf)
+	return slog.New(color.NewLog(&color.LogOptions{
+		HandlerOptions: slog.HandlerOptions{
+			ReplaceAttr: color.SuppressAttrs(slog.TimeKey),
+		},
+		ColorFunc: color.NoColor,
+		Writer:    w,
+	})), w, &buf
+}
+
+func TestSpan(t *testing.T) {
+	defer clock.Freeze(clock.Now()).UnFreeze()
+	log, w, buf := newLogger()
+
+	ctx := trace.WithOptions(context.Background(), trace.Options{Logger: log})
+	ctx, parent := trace.Start(ctx, "request", "method", "GET")
+	assert.Equal(t, parent, trace.FromContext(ctx))
+
+	_, child := trace.Start(ctx, "db-query")
+	child.SetAttrs("rows", 10)
+	clock.Advance(25 * clock.Millisecond)
+	assert.Equal(t, 25*clock.Millisecond, child.Elapsed())
+	child.End()
+	// Calling End() more than once should not emit again
+	child.End()
+
+	clock.Advance(5 * clock.Millisecond)
+	parent.End()
+	assert.Equal(t, 30*clock.Millisecond, parent.Elapsed())
+	assert.Equal(t, "request/db-query", child.Path())
+
+	require.NoError(t, w.Flush())
+	assert.Equal(t, "INFO: db-query elapsed=25ms span=request/db-query rows=10\n"+
+		"INFO: request elapsed=30ms span=request method=GET\n", buf.String())
+}
+
+func TestSpanTree(t *testing.T) {
+	defer clock.Freeze(clock.Now()).UnFreeze()
+	log, w, buf := newLogger()
+
+	ctx := trace.WithOptions(context.Background(), trace.Options{Logger: log, Level: slog.LevelDebug, Tree: true})
+	ctx, root := trace.Start(ctx, "request")
+
+	childCtx, child := trace.Start(ctx, "auth")
+	_, grandChild := trace.Start(childCtx, "lookup-token")
+	clock.Advance(10 * clock.Millisecond)
+	grandChild.End()
+	child.End()
+
+	_, unfinished := trace.Start(ctx, "render")
+	clock.Advance(5 * clock.Millisecond)
+	root.End()
+	unfinished.End()
+
+	require.NoError(t, w.Flush())
+	// Debug level is not enabled by the logger
+	assert.Equal(t, "", buf.String())
+
+	log, w, buf = newLogger()
+	ctx = trace.WithOptions(context.Background(), trace.Options{Logger: log, Tree: true})
+	ctx, root = trace.Start(ctx, "request")
+	childCtx, child = trace.Start(ctx, "auth")
+	_, grandChild = trace.Start(childCtx, "lookup-token")
+	clock.Advance(10 * clock.Millisecond)
+	grandChild.End()
+	child.End()
+	_, unfinished = trace.Start(ctx, "render")
+	clock.Advance(5 * clock.Millisecond)
+	root.End()
+
+	require.NoError(t, w.Flush())
+	assert.Equal(t, "INFO: request elapsed=15ms\n"+
+		"INFO: └─ auth elapsed=10ms\n"+
+		"INFO:   └─ lookup-token elapsed=10ms\n"+
+		"INFO: └─ render elapsed=5ms unfinished=true\n", buf.String())
+	unfinished.End()
+}
+
+type requestIDKey struct{}
+
+// contextHandler records the request id from the context of each record it handles
+type contextHandler struct {
+	slog.Handler
+	ids *[]string
+}
+
+func (h contextHandler) Handle(ctx context.Context, r slog.Record) error {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	*h.ids = append(*h.ids, id)
+	return nil
+}
+
+func TestSpanContext(t *testing.T) {
+	var ids []string
+	log := slog.New(contextHandler{Handler: slog.NewTextHandler(&bytes.Buffer{}, nil), ids: &ids})
+
+	for _, tree := range []bool{false, true} {
+		ids = nil
+		ctx := context.WithValue(context.Background(), requestIDKey{}, "req-1")
+		ctx = trace.WithOptions(ctx, trace.Options{Logger: log, Tree: tree})
+		ctx, root := trace.Start(ctx, "request")
+		_, child := trace.Start(ctx, "db-query")
+		child.End()
+		root.End()
+
+		// Handlers should receive the context the span was started with
+		assert.Equal(t, []string{"req-1", "req-1"}, ids, "tree=%v", tree)
+	}
+}
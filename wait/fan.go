@@ -44,12 +44,14 @@ func (p *FanOut) Run(cb func() error) {
 	}
 
 	p.size <- true
+	active := activeGoroutines()
+	active.Add(1)
 	go func() {
-		err := cb()
-		if err != nil {
+		defer func() { <-p.size }()
+		defer active.Add(-1)
+		if err := cb(); err != nil {
 			p.errChan <- err
 		}
-		<-p.size
 	}()
 }
 
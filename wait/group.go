@@ -12,9 +12,12 @@ type Group struct {
 // Go runs the provided routine in a function until it returns
 func (wg *Group) Go(cb func()) {
 	wg.wg.Add(1)
+	active := activeGoroutines()
+	active.Add(1)
 	go func() {
+		defer wg.wg.Done()
+		defer active.Add(-1)
 		cb()
-		wg.wg.Done()
 	}()
 }
 
@@ -24,28 +27,28 @@ func (wg *Group) Go(cb func()) {
 // clean and predictable error handling in concurrent operations.
 func (wg *Group) Run(callBack func() error) {
 	wg.wg.Add(1)
+	active := activeGoroutines()
+	active.Add(1)
 	go func() {
-		err := callBack()
-		if err == nil {
-			wg.wg.Done()
-			return
+		defer wg.wg.Done()
+		defer active.Add(-1)
+		if err := callBack(); err != nil {
+			wg.mutex.Lock()
+			wg.errs = append(wg.errs, err)
+			wg.mutex.Unlock()
 		}
-		wg.mutex.Lock()
-		wg.errs = append(wg.errs, err)
-		wg.wg.Done()
-		wg.mutex.Unlock()
 	}()
 }
 
 // Loop runs a goroutine in a loop continuously, if the callBack returns false the loop is broken
 func (wg *Group) Loop(callBack func() bool) {
 	wg.wg.Add(1)
+	active := activeGoroutines()
+	active.Add(1)
 	go func() {
-		for {
-			if !callBack() {
-				wg.wg.Done()
-				break
-			}
+		defer wg.wg.Done()
+		defer active.Add(-1)
+		for callBack() {
 		}
 	}()
 }
@@ -62,12 +65,12 @@ func (wg *Group) Until(cb func(done chan struct{}) bool) {
 	wg.mutex.Unlock()
 
 	wg.wg.Add(1)
+	active := activeGoroutines()
+	active.Add(1)
 	go func() {
-		for {
-			if !cb(wg.done) {
-				wg.wg.Done()
-				break
-			}
+		defer wg.wg.Done()
+		defer active.Add(-1)
+		for cb(wg.done) {
 		}
 	}()
 }
@@ -0,0 +1,31 @@
+package wait
+
+import (
+	"sync/atomic"
+
+	"github.com/kapetan-io/tackle/metrics"
+)
+
+type activeGauge struct {
+	registry metrics.Registry
+	gauge    metrics.Gauge
+}
+
+var active atomic.Pointer[activeGauge]
+
+// activeGoroutines returns the gauge which tracks the number of goroutines currently running via
+// Group and FanOut in the default metrics registry. The gauge is looked up only when the default
+// registry changes, such that starting a goroutine does not pay for a registry lookup.
+func activeGoroutines() metrics.Gauge {
+	r := metrics.Default()
+	if a := active.Load(); a != nil && a.registry == r {
+		return a.gauge
+	}
+	a := &activeGauge{
+		registry: r,
+		gauge: r.Gauge("tackle_wait_active_goroutines",
+			"The number of goroutines currently running via wait.Group and wait.FanOut"),
+	}
+	active.Store(a)
+	return a.gauge
+}
@@ -0,0 +1,72 @@
+package wait_test
+
+import (
+	"sync/atomic"
+	"testing"
+
+	"github.com/kapetan-io/tackle/metrics"
+	"github.com/kapetan-io/tackle/wait"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestActiveGoroutinesMetric(t *testing.T) {
+	r := metrics.NewInMemory()
+	metrics.SetDefault(r)
+	defer metrics.SetDefault(nil)
+
+	active := func() float64 {
+		for _, s := range r.Snapshot() {
+			if s.Name == "tackle_wait_active_goroutines" {
+				return s.Value
+			}
+		}
+		return -1
+	}
+
+	var wg wait.Group
+	release := make(chan struct{})
+	started := make(chan struct{}, 2)
+	for i := 0; i < 2; i++ {
+		wg.Go(func() {
+			started <- struct{}{}
+			<-release
+		})
+	}
+	<-started
+	<-started
+	assert.Equal(t, float64(2), active())
+
+	close(release)
+	require.NoError(t, wg.Wait())
+
+	f := wait.NewFanOut(2)
+	f.Run(func() error { return nil })
+	require.NoError(t, f.Wait())
+	assert.Equal(t, float64(0), active())
+}
+
+type countingRegistry struct {
+	metrics.Registry
+	gauges atomic.Int32
+}
+
+func (r *countingRegistry) Gauge(name, help string) metrics.Gauge {
+	r.gauges.Add(1)
+	return r.Registry.Gauge(name, help)
+}
+
+func TestActiveGoroutinesLookup(t *testing.T) {
+	r := &countingRegistry{Registry: metrics.NewInMemory()}
+	metrics.SetDefault(r)
+	defer metrics.SetDefault(nil)
+
+	// The gauge is looked up once, not every time a goroutine is started
+	var wg wait.Group
+	for i := 0; i < 10; i++ {
+		wg.Go(func() {})
+		wg.Run(func() error { return nil })
+	}
+	require.NoError(t, wg.Wait())
+	assert.Equal(t, int32(1), r.gauges.Load())
+}